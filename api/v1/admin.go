@@ -0,0 +1,6 @@
+package v1
+
+type ReloadResponse struct {
+	Status   string `json:"status"`
+	Duration string `json:"duration"`
+}
@@ -0,0 +1,12 @@
+package v1
+
+import "time"
+
+type DatasetResponse struct {
+	Path        string    `json:"path"`
+	SHA256      string    `json:"sha256"`
+	Version     string    `json:"version,omitempty"`
+	Rows        int       `json:"rows"`
+	SkippedRows int       `json:"skippedRows"`
+	LoadedAt    time.Time `json:"loadedAt"`
+}
@@ -3,6 +3,18 @@ package v1
 import "time"
 
 type HealthResponse struct {
-	Status    string    `json:"status"`
-	Timestamp time.Time `json:"timestamp"`
+	Status    string           `json:"status"`
+	Timestamp time.Time        `json:"timestamp"`
+	Dataset   *DatasetResponse `json:"dataset,omitempty"`
+	Updater   *UpdaterResponse `json:"updater,omitempty"`
+}
+
+// UpdaterResponse reports the dataset updater. Times are zero until the
+// first check, success or installed update.
+type UpdaterResponse struct {
+	LastCheck           time.Time `json:"lastCheck"`
+	LastSuccess         time.Time `json:"lastSuccess"`
+	LastUpdate          time.Time `json:"lastUpdate"`
+	LastError           string    `json:"lastError,omitempty"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
 }
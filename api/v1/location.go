@@ -1,7 +1,88 @@
 package v1
 
+import "math/big"
+
 type LocationResponse struct {
-	Country     string `json:"country"`
-	CountryCode string `json:"countryCode"`
-	City        string `json:"city"`
+	Country     string          `json:"country"`
+	CountryCode string          `json:"countryCode"`
+	Region      string          `json:"region"`
+	City        string          `json:"city"`
+	Latitude    float64         `json:"latitude"`
+	Longitude   float64         `json:"longitude"`
+	ZipCode     string          `json:"zipCode"`
+	TimeZone    string          `json:"timeZone"`
+	Source      string          `json:"source,omitempty"`
+	ASN         *ASNResponse    `json:"asn,omitempty"`
+	Threat      *ThreatResponse `json:"threat,omitempty"`
+}
+
+type ASNResponse struct {
+	Number  uint32 `json:"number"`
+	Name    string `json:"name"`
+	Network string `json:"network,omitempty"`
+}
+
+type ThreatResponse struct {
+	IsProxy   bool   `json:"isProxy"`
+	IsVPN     bool   `json:"isVpn"`
+	IsTor     bool   `json:"isTor"`
+	IsHosting bool   `json:"isHosting"`
+	ProxyType string `json:"proxyType,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+	UsageType string `json:"usageType,omitempty"`
+}
+
+// NetworkResponse describes the dataset ranges overlapping a CIDR block.
+// Address counts are JSON integers and can exceed 64 bits for IPv6 blocks.
+type NetworkResponse struct {
+	CIDR      string   `json:"cidr"`
+	Addresses *big.Int `json:"addresses"`
+	Covered   *big.Int `json:"covered"`
+	// TotalRanges counts every overlapping range; Ranges stops at 1000.
+	TotalRanges int                    `json:"totalRanges"`
+	Truncated   bool                   `json:"truncated"`
+	Ranges      []NetworkRangeResponse `json:"ranges"`
+	Countries   []CountryShareResponse `json:"countries"`
+}
+
+type NetworkRangeResponse struct {
+	Start     string           `json:"start"`
+	End       string           `json:"end"`
+	Addresses *big.Int         `json:"addresses"`
+	Location  LocationResponse `json:"location"`
+}
+
+type CountryShareResponse struct {
+	CountryCode string   `json:"countryCode"`
+	Country     string   `json:"country"`
+	Addresses   *big.Int `json:"addresses"`
+	Share       float64  `json:"share"`
+}
+
+// Statuses of a LookupResult.
+const (
+	BatchStatusFound    = "found"
+	BatchStatusNotFound = "not_found"
+	BatchStatusInvalid  = "invalid"
+	BatchStatusError    = "error"
+)
+
+// BatchLocationResponse answers a batch lookup with one result per address,
+// in request order.
+type BatchLocationResponse struct {
+	Results []BatchLocationResult `json:"results"`
+}
+
+type BatchLocationResult struct {
+	IP string `json:"ip"`
+	LookupResult
+}
+
+// LookupResult is the outcome of looking up one address of a batch or
+// stream.
+type LookupResult struct {
+	Status string `json:"status"`
+	// Reason explains an invalid or error status.
+	Reason   string            `json:"reason,omitempty"`
+	Location *LocationResponse `json:"location,omitempty"`
 }
@@ -0,0 +1,20 @@
+package v1
+
+type RangesResponse struct {
+	CountryCode string          `json:"countryCode"`
+	City        string          `json:"city,omitempty"`
+	Total       int             `json:"total"`
+	Offset      int             `json:"offset"`
+	Limit       int             `json:"limit"`
+	Ranges      []RangeResponse `json:"ranges"`
+}
+
+// RangeResponse is one dataset range: its first and last address and the
+// smallest set of CIDR blocks covering exactly that span.
+type RangeResponse struct {
+	Start  string   `json:"start"`
+	End    string   `json:"end"`
+	CIDRs  []string `json:"cidrs"`
+	Region string   `json:"region"`
+	City   string   `json:"city"`
+}
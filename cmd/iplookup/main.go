@@ -0,0 +1,26 @@
+// Command iplookup geolocates IP addresses against a local copy of the
+// dataset, without running the HTTP server.
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/cli"
+)
+
+func main() {
+	// A .env next to the binary is optional; flags and the environment
+	// work without it.
+	_ = config.LoadEnvFile(".env")
+
+	if err := cli.Lookup(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("Lookup failed: %v", err)
+	}
+}
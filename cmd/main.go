@@ -2,9 +2,11 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"arena-backend-challenge/config"
 	server "arena-backend-challenge/internal"
+	"arena-backend-challenge/internal/cli"
 )
 
 // @title IP Location API
@@ -22,11 +24,23 @@ import (
 // @BasePath /
 // @schemes http https
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Admin token as "Bearer <ADMIN_TOKEN>"
+
 func main() {
 	if err := config.LoadEnvFile(".env"); err != nil {
 		log.Println("Warning: .env file not found, using defaults")
 	}
 
+	if len(os.Args) > 1 {
+		if err := cli.Run(os.Args[1:]); err != nil {
+			log.Fatalf("Command failed: %v", err)
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
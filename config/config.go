@@ -1,19 +1,160 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"fmt"
+	"net/netip"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"arena-backend-challenge/internal/repository"
+)
+
+// LookupIndexes lists the IPv4 index structures accepted by LOOKUP_INDEX; see
+// repository.IndexKind.
+var LookupIndexes = []string{"binary", "eytzinger", "prefix", "trie"}
+
+// Dataset sources accepted by DATA_SOURCE.
+const (
+	DataSourceCSV      = "csv"
+	DataSourceSnapshot = "snapshot"
+	DataSourceMMDB     = "mmdb"
 )
 
 type Config struct {
 	HTTPServerAddress string
+	DataSource        string
 	CSVFilePath       string
+	// LookupIndex selects the structure answering IPv4 lookups in the CSV
+	// dataset, one of LookupIndexes.
+	LookupIndex string
+	// ASNCSVFilePath is the IP2Location ASN CSV used to add the autonomous
+	// system to lookups. Empty disables ASN enrichment.
+	ASNCSVFilePath string
+	// ProxyCSVFilePath (an IP2Proxy CSV) and TorExitListPath classify
+	// lookups as anonymizers. Either may be empty.
+	ProxyCSVFilePath string
+	TorExitListPath  string
+	SnapshotFilePath string
+	// SnapshotVerify checks the whole snapshot (checksum and table
+	// references) on every open instead of only its header.
+	SnapshotVerify bool
+	MMDBFilePath   string
+	// OverrideFiles are CSV/YAML override layers served on top of the
+	// dataset, highest priority first.
+	OverrideFiles    []string
+	CSVWatchInterval time.Duration
+	CSVMaxMemoryMB   int
+	// CSVStrictValidation refuses to load a CSV with more than
+	// CSVValidationMaxIssues issues of any kind.
+	CSVStrictValidation    bool
+	CSVValidationMaxIssues int
+	// DatasetPublicKey, a base64 Ed25519 key, makes the CSV dataset load
+	// only with a valid detached signature at CSV_FILE_PATH + ".sig".
+	DatasetPublicKey ed25519.PublicKey
+	AdminToken       string
+	// UpdateURL is a mirror polled every UpdateInterval for a new release
+	// of the dataset file. Empty disables the updater. UpdateChecksumURL
+	// defaults to UpdateURL + ".sha256"; UpdatePublicKey, a base64 Ed25519
+	// key, additionally requires a signature at UpdateURL + ".sig".
+	UpdateURL         string
+	UpdateChecksumURL string
+	UpdatePublicKey   ed25519.PublicKey
+	UpdateInterval    time.Duration
+	// BatchMaxSize and BatchMaxBodyKB cap the addresses and the request
+	// body of a batch lookup.
+	BatchMaxSize   int
+	BatchMaxBodyKB int
+	// TrustedProxies are the networks whose forwarding headers are believed
+	// when working out the client address of a request.
+	TrustedProxies []netip.Prefix
 }
 
 func Load() (*Config, error) {
+	csvWatchInterval, err := getEnvDuration("CSV_WATCH_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	csvMaxMemoryMB, err := getEnvInt("CSV_MAX_MEMORY_MB", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	csvStrictValidation, err := getEnvBool("CSV_STRICT_VALIDATION", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	snapshotVerify, err := getEnvBool("SNAPSHOT_VERIFY", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	csvValidationMaxIssues, err := getEnvInt("CSV_VALIDATION_MAX_ISSUES", 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	updateInterval, err := getEnvDuration("UPDATE_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	datasetPublicKey, err := getEnvPublicKey("DATASET_PUBLIC_KEY")
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	updatePublicKey, err := getEnvPublicKey("UPDATE_PUBLIC_KEY")
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	batchMaxSize, err := getEnvInt("BATCH_MAX_SIZE", 1000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	batchMaxBodyKB, err := getEnvInt("BATCH_MAX_BODY_KB", 1024)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	trustedProxies, err := getEnvPrefixes("TRUSTED_PROXIES")
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	cfg := &Config{
-		HTTPServerAddress: getEnv("HTTP_SERVER_ADDRESS", "0.0.0.0:8080"),
-		CSVFilePath:       getEnv("CSV_FILE_PATH", "data/sample.csv"),
+		HTTPServerAddress:      getEnv("HTTP_SERVER_ADDRESS", "0.0.0.0:8080"),
+		DataSource:             getEnv("DATA_SOURCE", DataSourceCSV),
+		CSVFilePath:            getEnv("CSV_FILE_PATH", "data/sample.csv"),
+		LookupIndex:            getEnv("LOOKUP_INDEX", LookupIndexes[0]),
+		ASNCSVFilePath:         getEnv("ASN_CSV_FILE_PATH", ""),
+		ProxyCSVFilePath:       getEnv("PROXY_CSV_FILE_PATH", ""),
+		TorExitListPath:        getEnv("TOR_EXIT_LIST_PATH", ""),
+		SnapshotFilePath:       getEnv("SNAPSHOT_FILE_PATH", "data/ip-locations.snap"),
+		SnapshotVerify:         snapshotVerify,
+		MMDBFilePath:           getEnv("MMDB_FILE_PATH", "data/GeoLite2-City.mmdb"),
+		OverrideFiles:          getEnvList("OVERRIDE_FILES"),
+		CSVWatchInterval:       csvWatchInterval,
+		CSVMaxMemoryMB:         csvMaxMemoryMB,
+		CSVStrictValidation:    csvStrictValidation,
+		CSVValidationMaxIssues: csvValidationMaxIssues,
+		DatasetPublicKey:       datasetPublicKey,
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+		UpdateURL:              getEnv("UPDATE_URL", ""),
+		UpdateChecksumURL:      getEnv("UPDATE_CHECKSUM_URL", ""),
+		UpdatePublicKey:        updatePublicKey,
+		UpdateInterval:         updateInterval,
+		BatchMaxSize:           batchMaxSize,
+		BatchMaxBodyKB:         batchMaxBodyKB,
+		TrustedProxies:         trustedProxies,
 	}
 
 	if err := cfg.validate(); err != nil {
@@ -27,8 +168,84 @@ func (c *Config) validate() error {
 	if c.HTTPServerAddress == "" {
 		return fmt.Errorf("HTTP_SERVER_ADDRESS cannot be empty")
 	}
-	if c.CSVFilePath == "" {
-		return fmt.Errorf("CSV_FILE_PATH cannot be empty")
+	switch c.DataSource {
+	case DataSourceCSV:
+		if c.CSVFilePath == "" {
+			return fmt.Errorf("CSV_FILE_PATH cannot be empty")
+		}
+	case DataSourceSnapshot:
+		if c.SnapshotFilePath == "" {
+			return fmt.Errorf("SNAPSHOT_FILE_PATH cannot be empty")
+		}
+	case DataSourceMMDB:
+		if c.MMDBFilePath == "" {
+			return fmt.Errorf("MMDB_FILE_PATH cannot be empty")
+		}
+	default:
+		return fmt.Errorf("DATA_SOURCE must be %q, %q or %q, got %q",
+			DataSourceCSV, DataSourceSnapshot, DataSourceMMDB, c.DataSource)
+	}
+	if !slices.Contains(LookupIndexes, c.LookupIndex) {
+		return fmt.Errorf("LOOKUP_INDEX must be one of %s, got %q", strings.Join(LookupIndexes, ", "), c.LookupIndex)
+	}
+	if c.LookupIndex != LookupIndexes[0] && c.DataSource != DataSourceCSV {
+		return fmt.Errorf("LOOKUP_INDEX requires DATA_SOURCE=%s", DataSourceCSV)
+	}
+	if c.CSVWatchInterval < 0 {
+		return fmt.Errorf("CSV_WATCH_INTERVAL cannot be negative")
+	}
+	if c.CSVMaxMemoryMB < 0 {
+		return fmt.Errorf("CSV_MAX_MEMORY_MB cannot be negative")
+	}
+	if c.CSVValidationMaxIssues < 0 {
+		return fmt.Errorf("CSV_VALIDATION_MAX_ISSUES cannot be negative")
+	}
+	if c.DatasetPublicKey != nil && c.DataSource != DataSourceCSV {
+		return fmt.Errorf("DATASET_PUBLIC_KEY requires DATA_SOURCE=%s", DataSourceCSV)
+	}
+	if c.UpdateURL != "" && c.UpdateInterval <= 0 {
+		return fmt.Errorf("UPDATE_INTERVAL must be positive")
+	}
+	if c.BatchMaxSize <= 0 {
+		return fmt.Errorf("BATCH_MAX_SIZE must be positive")
+	}
+	if c.BatchMaxBodyKB <= 0 {
+		return fmt.Errorf("BATCH_MAX_BODY_KB must be positive")
+	}
+	return nil
+}
+
+// DataFilePath returns the file backing the configured DATA_SOURCE.
+func (c *Config) DataFilePath() string {
+	switch c.DataSource {
+	case DataSourceSnapshot:
+		return c.SnapshotFilePath
+	case DataSourceMMDB:
+		return c.MMDBFilePath
+	}
+	return c.CSVFilePath
+}
+
+// CSVOptions returns how the CSV dataset is loaded wherever it is read, by
+// the server or a command: memory ceiling, lookup index and, with
+// DATASET_PUBLIC_KEY, signature verification. Strict validation is left to
+// the caller, since the validate command reports instead of refusing.
+func (c *Config) CSVOptions() []repository.Option {
+	opts := []repository.Option{
+		repository.WithMaxMemory(int64(c.CSVMaxMemoryMB) << 20),
+		repository.WithIndex(repository.IndexKind(c.LookupIndex)),
+	}
+	if c.DatasetPublicKey != nil {
+		opts = append(opts, repository.WithPublicKey(c.DatasetPublicKey))
+	}
+	return opts
+}
+
+// SnapshotOptions returns how a snapshot is opened, by the server or a
+// command.
+func (c *Config) SnapshotOptions() []repository.Option {
+	if c.SnapshotVerify {
+		return []repository.Option{repository.WithSnapshotVerification()}
 	}
 	return nil
 }
@@ -39,3 +256,88 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList splits a comma-separated value, dropping empty items.
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvPrefixes parses a comma-separated list of CIDRs; a bare address is
+// a single-address prefix.
+func getEnvPrefixes(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range getEnvList(key) {
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s must list CIDRs or addresses: %w", key, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration (e.g. 30s): %w", key, err)
+	}
+	return duration, nil
+}
+
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return n, nil
+}
+
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean: %w", key, err)
+	}
+	return b, nil
+}
+
+// getEnvPublicKey decodes a base64 Ed25519 public key.
+func getEnvPublicKey(key string) (ed25519.PublicKey, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be base64: %w", key, err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s must be a %d-byte Ed25519 public key, got %d bytes", key, ed25519.PublicKeySize, len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
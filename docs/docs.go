@@ -23,9 +23,164 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/reload": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Loads the configured dataset file again, validates it and atomically swaps it in. Lookups keep using the previous dataset until the swap.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reload IP dataset",
+                "responses": {
+                    "200": {
+                        "description": "Dataset reloaded",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ReloadResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin API is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "A reload is already in progress",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "New dataset failed to load; previous dataset is still served",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/blocklist": {
+            "get": {
+                "description": "Renders every range of the given countries, aggregated into the fewest CIDR blocks, as firewall or proxy configuration: an ipset restore script, an nftables table of interval sets, an nginx geo block, a HAProxy ACL file or an Apache RequireAll block.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Location"
+                ],
+                "summary": "Export a country blocklist",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated ISO 3166 country codes (e.g., CN,RU)",
+                        "name": "countries",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "ipset",
+                            "nftables",
+                            "nginx",
+                            "haproxy",
+                            "apache"
+                        ],
+                        "type": "string",
+                        "description": "Output format",
+                        "name": "format",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "IP version, 4 or 6; both when omitted",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "geoblock",
+                        "description": "Name of the generated sets, variable or ACL",
+                        "name": "name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Configuration snippet",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter or unknown country",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "The configured data source does not support reverse lookups",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/dataset": {
+            "get": {
+                "description": "Describes the dataset currently served: source file, SHA-256, vendor version, row counts and load time. Use it to confirm a rollout picked up a new release.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Dataset"
+                ],
+                "summary": "Get dataset metadata",
+                "responses": {
+                    "200": {
+                        "description": "Dataset metadata",
+                        "schema": {
+                            "$ref": "#/definitions/v1.DatasetResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/health": {
             "get": {
-                "description": "Returns the health status of the API",
+                "description": "Returns the health status of the API, the dataset it serves and the dataset updater. The status is degraded while the last update check failed.",
                 "produces": [
                     "application/json"
                 ],
@@ -45,7 +200,7 @@ const docTemplate = `{
         },
         "/ip/location": {
             "get": {
-                "description": "Get geographic location information for a given IP address",
+                "description": "Get geographic location information for a given IP address. With cidr instead of ip, returns a v1.NetworkResponse: every range overlapping the block (at most 1000 listed), each with its location and the number of block addresses it covers, and the share of the block per country. Blocks must be /8 or longer for IPv4 and /16 or longer for IPv6.",
                 "consumes": [
                     "application/json"
                 ],
@@ -59,10 +214,15 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "IPv4 address (e.g., 8.8.8.8)",
+                        "description": "IPv4 or IPv6 address (e.g., 8.8.8.8 or 2001:db8::1)",
                         "name": "ip",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Network block, instead of ip (e.g., 1.0.0.0/16)",
+                        "name": "cidr",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -73,7 +233,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid IP address format",
+                        "description": "Invalid IP address or CIDR format",
                         "schema": {
                             "$ref": "#/definitions/v1.ErrorResponse"
                         }
@@ -83,12 +243,321 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/v1.ErrorResponse"
                         }
+                    },
+                    "500": {
+                        "description": "The dataset or an enrichment repository failed",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "CIDR queries are not supported by the configured data source",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ip/locations": {
+            "post": {
+                "description": "Looks up a JSON array of IPv4 and IPv6 addresses in one request. The response has one result per address, in request order, with status found (and the location), not_found, invalid or error (with a reason). An address that fails does not fail the batch. The number of addresses and the body size are capped by BATCH_MAX_SIZE and BATCH_MAX_BODY_KB.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Location"
+                ],
+                "summary": "Get the locations of many IPs",
+                "parameters": [
+                    {
+                        "description": "Addresses to look up (e.g., [\"8.8.8.8\", \"2001:db8::1\"])",
+                        "name": "ips",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "One result per address",
+                        "schema": {
+                            "$ref": "#/definitions/v1.BatchLocationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Body is not a JSON array of strings",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Too many addresses or body too large",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ip/locations/stream": {
+            "post": {
+                "description": "Reads newline-delimited JSON from the body and streams NDJSON back as lines arrive. A line holding a JSON object gets a member, named by into, with the lookup of the address in its field member; every other member is passed through as is. A line holding a bare IP, as text or a JSON string, is answered with an object like the results of POST /ip/locations. Blank lines are skipped and a line that cannot be read is answered with an error object naming its line number. Lines are limited to 64 KiB; the body is not.",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Location"
+                ],
+                "summary": "Enrich a stream of IPs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "ip",
+                        "description": "Member of each object holding the IP",
+                        "name": "field",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "geo",
+                        "description": "Member added to each object with the lookup",
+                        "name": "into",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Newline-delimited JSON objects or IPs",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "One line per input line, as NDJSON",
+                        "schema": {
+                            "$ref": "#/definitions/v1.LookupResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ip/me": {
+            "get": {
+                "description": "Looks up the address of the client making the request. Behind proxies listed in TRUSTED_PROXIES, the client address is taken from the Forwarded, X-Forwarded-For or X-Real-IP header they set; otherwise it is the address the request came from. The result is never cached.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Location"
+                ],
+                "summary": "Get the caller's location",
+                "responses": {
+                    "200": {
+                        "description": "The client address, with its location when found",
+                        "schema": {
+                            "$ref": "#/definitions/v1.BatchLocationResult"
+                        }
+                    },
+                    "400": {
+                        "description": "The client address could not be determined",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ranges": {
+            "get": {
+                "description": "Reverse lookup: lists the dataset ranges assigned to a country, or to a city in it, in address order with IPv4 first. Each range comes with the minimal set of CIDR blocks covering it. Results are paginated with offset and limit.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Location"
+                ],
+                "summary": "List IP ranges of a country or city",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ISO 3166 country code (e.g., BR)",
+                        "name": "country",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "City name, case-insensitive (e.g., Sao Paulo)",
+                        "name": "city",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "IP version, 4 or 6; both when omitted",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Ranges to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Maximum ranges returned (at most 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ranges found",
+                        "schema": {
+                            "$ref": "#/definitions/v1.RangesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameter",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "Method not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "The configured data source does not support reverse lookups",
+                        "schema": {
+                            "$ref": "#/definitions/v1.ErrorResponse"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "v1.ASNResponse": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "network": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.BatchLocationResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.BatchLocationResult"
+                    }
+                }
+            }
+        },
+        "v1.BatchLocationResult": {
+            "type": "object",
+            "properties": {
+                "ip": {
+                    "type": "string"
+                },
+                "location": {
+                    "$ref": "#/definitions/v1.LocationResponse"
+                },
+                "reason": {
+                    "description": "Reason explains an invalid or error status.",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.CountryShareResponse": {
+            "type": "object",
+            "properties": {
+                "addresses": {
+                    "type": "integer"
+                },
+                "country": {
+                    "type": "string"
+                },
+                "countryCode": {
+                    "type": "string"
+                },
+                "share": {
+                    "type": "number"
+                }
+            }
+        },
+        "v1.DatasetResponse": {
+            "type": "object",
+            "properties": {
+                "loadedAt": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "rows": {
+                    "type": "integer"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "skippedRows": {
+                    "type": "integer"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
         "v1.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -100,17 +569,26 @@ const docTemplate = `{
         "v1.HealthResponse": {
             "type": "object",
             "properties": {
+                "dataset": {
+                    "$ref": "#/definitions/v1.DatasetResponse"
+                },
                 "status": {
                     "type": "string"
                 },
                 "timestamp": {
                     "type": "string"
+                },
+                "updater": {
+                    "$ref": "#/definitions/v1.UpdaterResponse"
                 }
             }
         },
         "v1.LocationResponse": {
             "type": "object",
             "properties": {
+                "asn": {
+                    "$ref": "#/definitions/v1.ASNResponse"
+                },
                 "city": {
                     "type": "string"
                 },
@@ -119,9 +597,209 @@ const docTemplate = `{
                 },
                 "countryCode": {
                     "type": "string"
+                },
+                "latitude": {
+                    "type": "number"
+                },
+                "longitude": {
+                    "type": "number"
+                },
+                "region": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "threat": {
+                    "$ref": "#/definitions/v1.ThreatResponse"
+                },
+                "timeZone": {
+                    "type": "string"
+                },
+                "zipCode": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.LookupResult": {
+            "type": "object",
+            "properties": {
+                "location": {
+                    "$ref": "#/definitions/v1.LocationResponse"
+                },
+                "reason": {
+                    "description": "Reason explains an invalid or error status.",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.NetworkRangeResponse": {
+            "type": "object",
+            "properties": {
+                "addresses": {
+                    "type": "integer"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "location": {
+                    "$ref": "#/definitions/v1.LocationResponse"
+                },
+                "start": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.NetworkResponse": {
+            "type": "object",
+            "properties": {
+                "addresses": {
+                    "type": "integer"
+                },
+                "cidr": {
+                    "type": "string"
+                },
+                "countries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.CountryShareResponse"
+                    }
+                },
+                "covered": {
+                    "type": "integer"
+                },
+                "ranges": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.NetworkRangeResponse"
+                    }
+                },
+                "totalRanges": {
+                    "description": "TotalRanges counts every overlapping range; Ranges stops at 1000.",
+                    "type": "integer"
+                },
+                "truncated": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "v1.RangeResponse": {
+            "type": "object",
+            "properties": {
+                "cidrs": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "city": {
+                    "type": "string"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "region": {
+                    "type": "string"
+                },
+                "start": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.RangesResponse": {
+            "type": "object",
+            "properties": {
+                "city": {
+                    "type": "string"
+                },
+                "countryCode": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "ranges": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/v1.RangeResponse"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "v1.ReloadResponse": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.ThreatResponse": {
+            "type": "object",
+            "properties": {
+                "isHosting": {
+                    "type": "boolean"
+                },
+                "isProxy": {
+                    "type": "boolean"
+                },
+                "isTor": {
+                    "type": "boolean"
+                },
+                "isVpn": {
+                    "type": "boolean"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "proxyType": {
+                    "type": "string"
+                },
+                "usageType": {
+                    "type": "string"
+                }
+            }
+        },
+        "v1.UpdaterResponse": {
+            "type": "object",
+            "properties": {
+                "consecutiveFailures": {
+                    "type": "integer"
+                },
+                "lastCheck": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "lastSuccess": {
+                    "type": "string"
+                },
+                "lastUpdate": {
+                    "type": "string"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "Admin token as \"Bearer \u003cADMIN_TOKEN\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
@@ -0,0 +1,246 @@
+// Package blocklist renders the ranges of a set of countries as firewall and
+// proxy configuration, so geo-blocking rules come from the same dataset the
+// API serves.
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/pkg/iputil"
+)
+
+// Format is a configuration syntax Write can render.
+type Format string
+
+const (
+	// FormatIPSet is an `ipset restore` script with one hash:net set per
+	// IP family, for iptables and ip6tables --match-set rules.
+	FormatIPSet Format = "ipset"
+	// FormatNFTables is an nftables table holding one interval set per
+	// family, loaded with `nft -f`.
+	FormatNFTables Format = "nftables"
+	// FormatNginx is a geo block setting a variable to 1 for listed clients.
+	FormatNginx Format = "nginx"
+	// FormatHAProxy is an ACL file, one prefix per line, for `src -f`.
+	FormatHAProxy Format = "haproxy"
+	// FormatApache is a RequireAll block denying the listed prefixes.
+	FormatApache Format = "apache"
+)
+
+// Formats lists the supported formats.
+func Formats() []Format {
+	return []Format{FormatIPSet, FormatNFTables, FormatNginx, FormatHAProxy, FormatApache}
+}
+
+// DefaultName names the generated sets, variable or ACL when Options.Name is
+// empty.
+const DefaultName = "geoblock"
+
+// Options tunes the rendered configuration.
+type Options struct {
+	// Name is used for the sets, table, nginx variable and ACL; IP sets get
+	// a _v4 or _v6 suffix. It defaults to DefaultName.
+	Name string
+	// Comment is written at the top of the output as # comment lines.
+	Comment string
+}
+
+// validName keeps names usable in every format; ipset caps set names at 31
+// bytes, which leaves 28 before the family suffix.
+var validName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,27}$`)
+
+// ParseCountries splits a comma-separated list of country codes, upper-cases
+// them and drops duplicates.
+func ParseCountries(list string) ([]string, error) {
+	var countries []string
+	for _, code := range strings.Split(list, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if len(code) != 2 {
+			return nil, fmt.Errorf("invalid country code %q", code)
+		}
+		if !slices.Contains(countries, code) {
+			countries = append(countries, code)
+		}
+	}
+	if len(countries) == 0 {
+		return nil, fmt.Errorf("no country codes given")
+	}
+	return countries, nil
+}
+
+// Comment describes a blocklist for Options.Comment: the countries and the
+// dataset the ranges came from.
+func Comment(countries []string, info domain.DatasetInfo) string {
+	comment := "Blocklist for " + strings.Join(countries, ", ")
+	if info.Path == "" {
+		return comment
+	}
+
+	source := filepath.Base(info.Path)
+	if info.Version != "" {
+		source += ", version " + info.Version
+	}
+	if info.SHA256 != "" {
+		source += ", sha256 " + info.SHA256
+	}
+	return comment + "\nGenerated from " + source
+}
+
+// Collect returns the fewest CIDR prefixes covering every range of the given
+// countries, IPv4 first. A version of 4 or 6 keeps that family only. A
+// country without any range is an error, since it is most likely a typo.
+func Collect(lister domain.RangeLister, countries []string, version int) ([]netip.Prefix, error) {
+	var ranges []iputil.Range
+	for _, code := range countries {
+		page := lister.ListRanges(domain.RangeQuery{CountryCode: code, IPVersion: version})
+		if page.Total == 0 {
+			return nil, fmt.Errorf("no ranges for country %q", code)
+		}
+		for _, r := range page.Ranges {
+			ranges = append(ranges, iputil.Range{First: r.First, Last: r.Last})
+		}
+	}
+
+	return iputil.AggregateRanges(ranges)
+}
+
+// Write renders prefixes in format.
+func Write(w io.Writer, format Format, prefixes []netip.Prefix, opts Options) error {
+	if !slices.Contains(Formats(), format) {
+		return fmt.Errorf("unknown format %q", format)
+	}
+	if opts.Name == "" {
+		opts.Name = DefaultName
+	}
+	if !validName.MatchString(opts.Name) {
+		return fmt.Errorf("invalid name %q: use up to 28 letters, digits and underscores, starting with a letter", opts.Name)
+	}
+
+	var v4, v6 []netip.Prefix
+	for _, p := range prefixes {
+		if p.Addr().Is4() {
+			v4 = append(v4, p)
+		} else {
+			v6 = append(v6, p)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, line := range strings.Split(opts.Comment, "\n") {
+		if line != "" {
+			fmt.Fprintf(bw, "# %s\n", line)
+		}
+	}
+
+	switch format {
+	case FormatIPSet:
+		writeIPSet(bw, opts.Name, v4, v6)
+	case FormatNFTables:
+		writeNFTables(bw, opts.Name, v4, v6)
+	case FormatNginx:
+		writeNginx(bw, opts.Name, prefixes)
+	case FormatHAProxy:
+		writeHAProxy(bw, opts.Name, prefixes)
+	case FormatApache:
+		writeApache(bw, prefixes)
+	}
+
+	return bw.Flush()
+}
+
+// The writers below write to a bufio.Writer, which keeps the first error
+// for Flush to report.
+
+func writeIPSet(w *bufio.Writer, name string, v4, v6 []netip.Prefix) {
+	fmt.Fprintln(w, "# Load with: ipset restore < this-file")
+	for _, set := range []struct {
+		suffix, family, iptables string
+		prefixes                 []netip.Prefix
+	}{
+		{"_v4", "inet", "iptables", v4},
+		{"_v6", "inet6", "ip6tables", v6},
+	} {
+		if len(set.prefixes) == 0 {
+			continue
+		}
+		setName := name + set.suffix
+		fmt.Fprintf(w, "# Then: %s -I INPUT -m set --match-set %s src -j DROP\n", set.iptables, setName)
+		// hash:net sets hold 65536 entries unless told otherwise.
+		fmt.Fprintf(w, "create %s hash:net family %s maxelem %d -exist\n",
+			setName, set.family, max(65536, len(set.prefixes)))
+		fmt.Fprintf(w, "flush %s\n", setName)
+		for _, p := range set.prefixes {
+			fmt.Fprintf(w, "add %s %s\n", setName, p)
+		}
+	}
+}
+
+func writeNFTables(w *bufio.Writer, name string, v4, v6 []netip.Prefix) {
+	fmt.Fprintln(w, "# Load with: nft -f this-file")
+	fmt.Fprintf(w, "# Then match with: ip saddr @%s_v4 drop / ip6 saddr @%s_v6 drop\n", name, name)
+	fmt.Fprintf(w, "table inet %s {\n", name)
+	for _, set := range []struct {
+		suffix, typ string
+		prefixes    []netip.Prefix
+	}{
+		{"_v4", "ipv4_addr", v4},
+		{"_v6", "ipv6_addr", v6},
+	} {
+		if len(set.prefixes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\tset %s%s {\n", name, set.suffix)
+		fmt.Fprintf(w, "\t\ttype %s\n", set.typ)
+		fmt.Fprintln(w, "\t\tflags interval")
+		fmt.Fprintln(w, "\t\telements = {")
+		for i, p := range set.prefixes {
+			sep := ","
+			if i == len(set.prefixes)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(w, "\t\t\t%s%s\n", p, sep)
+		}
+		fmt.Fprintln(w, "\t\t}")
+		fmt.Fprintln(w, "\t}")
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeNginx(w *bufio.Writer, name string, prefixes []netip.Prefix) {
+	fmt.Fprintf(w, "# Include in the http block, then: if ($%s) { return 403; }\n", name)
+	fmt.Fprintf(w, "geo $%s {\n", name)
+	fmt.Fprintln(w, "    default 0;")
+	for _, p := range prefixes {
+		fmt.Fprintf(w, "    %s 1;\n", p)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeHAProxy(w *bufio.Writer, name string, prefixes []netip.Prefix) {
+	fmt.Fprintf(w, "# Use with: acl %s src -f /path/to/this-file\n", name)
+	fmt.Fprintf(w, "#           http-request deny if %s\n", name)
+	for _, p := range prefixes {
+		fmt.Fprintln(w, p)
+	}
+}
+
+func writeApache(w *bufio.Writer, prefixes []netip.Prefix) {
+	fmt.Fprintln(w, "# Include in a <Directory> or <Location> section.")
+	fmt.Fprintln(w, "<RequireAll>")
+	fmt.Fprintln(w, "    Require all granted")
+	for _, p := range prefixes {
+		fmt.Fprintf(w, "    Require not ip %s\n", p)
+	}
+	fmt.Fprintln(w, "</RequireAll>")
+}
@@ -0,0 +1,190 @@
+package blocklist
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// fakeLister serves fixed ranges per country, honouring IPVersion.
+type fakeLister map[string][][2]string
+
+func (f fakeLister) ListRanges(q domain.RangeQuery) domain.RangePage {
+	var page domain.RangePage
+	for _, r := range f[q.CountryCode] {
+		first := netip.MustParseAddr(r[0])
+		if (q.IPVersion == 4 && !first.Is4()) || (q.IPVersion == 6 && first.Is4()) {
+			continue
+		}
+		page.Ranges = append(page.Ranges, domain.IPRange{First: first, Last: netip.MustParseAddr(r[1])})
+	}
+	page.Total = len(page.Ranges)
+	return page
+}
+
+var testLister = fakeLister{
+	"BR": {{"10.0.0.0", "10.0.0.255"}, {"10.0.2.0", "10.0.2.255"}, {"2001:db8::", "2001:db8::ffff"}},
+	// Adjacent to both BR ranges around it, so the union is one /22.
+	"AR": {{"10.0.1.0", "10.0.1.255"}, {"10.0.3.0", "10.0.3.255"}},
+}
+
+func TestParseCountries(t *testing.T) {
+	got, err := ParseCountries(" br,AR, ,br ")
+	if err != nil {
+		t.Fatalf("ParseCountries() error = %v", err)
+	}
+	if want := []string{"BR", "AR"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCountries() = %v, want %v", got, want)
+	}
+
+	for _, list := range []string{"", " , ", "BRA", "BR,X"} {
+		if _, err := ParseCountries(list); err == nil {
+			t.Errorf("ParseCountries(%q) should fail", list)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	tests := []struct {
+		name      string
+		countries []string
+		version   int
+		want      []string
+		wantErr   bool
+	}{
+		{"one country", []string{"BR"}, 0, []string{"10.0.0.0/24", "10.0.2.0/24", "2001:db8::/112"}, false},
+		{"adjacent countries aggregate", []string{"BR", "AR"}, 0, []string{"10.0.0.0/22", "2001:db8::/112"}, false},
+		{"IPv4 only", []string{"BR", "AR"}, 4, []string{"10.0.0.0/22"}, false},
+		{"IPv6 only", []string{"BR"}, 6, []string{"2001:db8::/112"}, false},
+		{"unknown country", []string{"BR", "ZZ"}, 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := Collect(testLister, tt.countries, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Collect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, p := range prefixes {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Collect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	prefixes := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/22"),
+		netip.MustParsePrefix("10.1.0.0/24"),
+		netip.MustParsePrefix("2001:db8::/112"),
+	}
+
+	tests := map[Format]string{
+		FormatIPSet: `# BR, AR
+# Load with: ipset restore < this-file
+# Then: iptables -I INPUT -m set --match-set geo_v4 src -j DROP
+create geo_v4 hash:net family inet maxelem 65536 -exist
+flush geo_v4
+add geo_v4 10.0.0.0/22
+add geo_v4 10.1.0.0/24
+# Then: ip6tables -I INPUT -m set --match-set geo_v6 src -j DROP
+create geo_v6 hash:net family inet6 maxelem 65536 -exist
+flush geo_v6
+add geo_v6 2001:db8::/112
+`,
+		FormatNFTables: `# BR, AR
+# Load with: nft -f this-file
+# Then match with: ip saddr @geo_v4 drop / ip6 saddr @geo_v6 drop
+table inet geo {
+	set geo_v4 {
+		type ipv4_addr
+		flags interval
+		elements = {
+			10.0.0.0/22,
+			10.1.0.0/24
+		}
+	}
+	set geo_v6 {
+		type ipv6_addr
+		flags interval
+		elements = {
+			2001:db8::/112
+		}
+	}
+}
+`,
+		FormatNginx: `# BR, AR
+# Include in the http block, then: if ($geo) { return 403; }
+geo $geo {
+    default 0;
+    10.0.0.0/22 1;
+    10.1.0.0/24 1;
+    2001:db8::/112 1;
+}
+`,
+		FormatHAProxy: `# BR, AR
+# Use with: acl geo src -f /path/to/this-file
+#           http-request deny if geo
+10.0.0.0/22
+10.1.0.0/24
+2001:db8::/112
+`,
+		FormatApache: `# BR, AR
+# Include in a <Directory> or <Location> section.
+<RequireAll>
+    Require all granted
+    Require not ip 10.0.0.0/22
+    Require not ip 10.1.0.0/24
+    Require not ip 2001:db8::/112
+</RequireAll>
+`,
+	}
+
+	for _, format := range Formats() {
+		t.Run(string(format), func(t *testing.T) {
+			var sb strings.Builder
+			if err := Write(&sb, format, prefixes, Options{Name: "geo", Comment: "BR, AR"}); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if got := sb.String(); got != tests[format] {
+				t.Errorf("Write() =\n%s\nwant\n%s", got, tests[format])
+			}
+		})
+	}
+}
+
+func TestWrite_Errors(t *testing.T) {
+	var sb strings.Builder
+	if err := Write(&sb, "iptables", nil, Options{}); err == nil {
+		t.Error("Write() with an unknown format should fail")
+	}
+	for _, name := range []string{"1geo", "geo-block", "a_name_that_is_far_too_long_for_ipset"} {
+		if err := Write(&sb, FormatNginx, nil, Options{Name: name}); err == nil {
+			t.Errorf("Write() with name %q should fail", name)
+		}
+	}
+}
+
+func TestComment(t *testing.T) {
+	got := Comment([]string{"BR", "AR"}, domain.DatasetInfo{
+		Path:    "data/IP2LOCATION-LITE-DB11.CSV",
+		Version: "2024-10",
+		SHA256:  "9f86d081",
+	})
+	want := "Blocklist for BR, AR\nGenerated from IP2LOCATION-LITE-DB11.CSV, version 2024-10, sha256 9f86d081"
+	if got != want {
+		t.Errorf("Comment() = %q, want %q", got, want)
+	}
+
+	if got := Comment([]string{"BR"}, domain.DatasetInfo{}); got != "Blocklist for BR" {
+		t.Errorf("Comment() without dataset info = %q", got)
+	}
+}
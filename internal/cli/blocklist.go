@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/blocklist"
+	"arena-backend-challenge/internal/repository"
+	"arena-backend-challenge/pkg/logger"
+)
+
+func runBlocklist(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	formats := make([]string, 0, len(blocklist.Formats()))
+	for _, f := range blocklist.Formats() {
+		formats = append(formats, string(f))
+	}
+
+	fs := flag.NewFlagSet("blocklist", flag.ContinueOnError)
+	csvPath := fs.String("csv", cfg.CSVFilePath, "IP2Location DB11 CSV to read the ranges from")
+	countryList := fs.String("countries", "", "comma-separated country codes to block (e.g. CN,RU)")
+	format := fs.String("format", string(blocklist.FormatNFTables), "output format: "+strings.Join(formats, ", "))
+	version := fs.Int("version", 0, "IP version to include, 4 or 6 (default both)")
+	name := fs.String("name", blocklist.DefaultName, "name of the generated sets, variable or ACL")
+	outPath := fs.String("out", "", "file to write (default standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	countries, err := blocklist.ParseCountries(*countryList)
+	if err != nil {
+		return fmt.Errorf("-countries: %w", err)
+	}
+	if *version != 0 && *version != 4 && *version != 6 {
+		return fmt.Errorf("-version must be 4 or 6")
+	}
+
+	start := time.Now()
+
+	repo, err := repository.NewMemoryRepository(*csvPath, cfg.CSVOptions()...)
+	if err != nil {
+		return err
+	}
+
+	prefixes, err := blocklist.Collect(repo, countries, *version)
+	if err != nil {
+		return err
+	}
+
+	options := blocklist.Options{
+		Name:    *name,
+		Comment: blocklist.Comment(countries, repo.DatasetInfo()),
+	}
+	write := func(w io.Writer) error {
+		return blocklist.Write(w, blocklist.Format(*format), prefixes, options)
+	}
+	if *outPath == "" {
+		return write(os.Stdout)
+	}
+	if err := writeFileAtomic(*outPath, write); err != nil {
+		return fmt.Errorf("write %s: %w", *outPath, err)
+	}
+
+	logger.Infof("Blocklist written - Path: %s - Countries: %s - Prefixes: %d - Duration: %v",
+		*outPath, strings.Join(countries, ","), len(prefixes), time.Since(start).Round(time.Millisecond))
+	return nil
+}
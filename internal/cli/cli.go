@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/repository"
+)
+
+// command is a one-shot subcommand of the server binary.
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"blocklist": {
+		summary: "render the ranges of countries as firewall or proxy config",
+		run:     runBlocklist,
+	},
+	"enrich": {
+		summary: "add the country and city of the client to access logs, JSON logs or CSV",
+		run:     runEnrich,
+	},
+	"mmdb": {
+		summary: "export the CSV dataset as a MaxMind DB file",
+		run:     runMMDB,
+	},
+	"sign": {
+		summary: "sign a dataset file for DATASET_PUBLIC_KEY verification",
+		run:     runSign,
+	},
+	"snapshot": {
+		summary: "compile the CSV dataset into a binary snapshot",
+		run:     runSnapshot,
+	},
+	"validate": {
+		summary: "check the CSV dataset and print a data-quality report",
+		run:     runValidate,
+	},
+}
+
+// Run executes the subcommand named by args[0] with the remaining arguments.
+func Run(args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(os.Stdout)
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	if err := cmd.run(args[1:]); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\n", filepath.Base(os.Args[0]))
+	fmt.Fprintln(w, "Without a command the HTTP server is started.")
+	fmt.Fprintln(w, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", name, commands[name].summary)
+	}
+}
+
+// writeFileAtomic writes to a temporary file next to path and renames it into
+// place, so a server watching path never sees a partial file.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("chmod temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename into place: %w", err)
+	}
+	return nil
+}
+
+// openDataset loads the snapshot at snapPath or, without one, the CSV at
+// csvPath, both the way cfg says. closeRepo releases the snapshot's mapping.
+func openDataset(cfg *config.Config, csvPath, snapPath string) (repo domain.Repository, closeRepo func(), err error) {
+	if snapPath == "" {
+		memory, err := repository.NewMemoryRepository(csvPath, cfg.CSVOptions()...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return memory, func() {}, nil
+	}
+
+	snapshot, err := repository.NewSnapshotRepository(snapPath, cfg.SnapshotOptions()...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return snapshot, func() { _ = snapshot.Close() }, nil
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"arena-backend-challenge/internal/repository"
+)
+
+func TestRun_UnknownCommand(t *testing.T) {
+	if err := Run([]string{"nope"}); err == nil {
+		t.Error("Run() with an unknown command should fail")
+	}
+}
+
+func TestRun_Snapshot(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	snapPath := filepath.Join(dir, "data.snap")
+
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	if err := Run([]string{"snapshot", "-csv", csvPath, "-out", snapPath}); err != nil {
+		t.Fatalf("Run(snapshot) error = %v", err)
+	}
+
+	repo, err := repository.NewSnapshotRepository(snapPath)
+	if err != nil {
+		t.Fatalf("NewSnapshotRepository() error = %v", err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	got, err := repo.FindByIPID(134744072)
+	if err != nil {
+		t.Fatalf("FindByIPID() error = %v", err)
+	}
+	if got.City != "Mountain View" {
+		t.Errorf("FindByIPID() City = %v, want Mountain View", got.City)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("output directory has %d entries, want 2 (no leftover temp files)", len(entries))
+	}
+}
+
+func TestRun_MMDB(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	mmdbPath := filepath.Join(dir, "data.mmdb")
+
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	if err := Run([]string{"mmdb", "-csv", csvPath, "-out", mmdbPath}); err != nil {
+		t.Fatalf("Run(mmdb) error = %v", err)
+	}
+
+	repo, err := repository.NewMMDBRepository(mmdbPath)
+	if err != nil {
+		t.Fatalf("NewMMDBRepository() error = %v", err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	got, err := repo.FindByIPID(134744072)
+	if err != nil {
+		t.Fatalf("FindByIPID() error = %v", err)
+	}
+	if got.City != "Mountain View" {
+		t.Errorf("FindByIPID() City = %v, want Mountain View", got.City)
+	}
+}
+
+func TestRun_ValidateStrict(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "data.csv")
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"
+"broken","134744073","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	if err := Run([]string{"validate", "-csv", csvPath}); err != nil {
+		t.Errorf("Run(validate) error = %v", err)
+	}
+	if err := Run([]string{"validate", "-csv", csvPath, "-strict"}); !errors.Is(err, repository.ErrValidationFailed) {
+		t.Errorf("Run(validate -strict) error = %v, want ErrValidationFailed", err)
+	}
+	if err := Run([]string{"validate", "-csv", csvPath, "-strict", "-max-issues", "1"}); err != nil {
+		t.Errorf("Run(validate -strict -max-issues 1) error = %v", err)
+	}
+}
+
+func TestRun_Sign(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	keyPath := filepath.Join(dir, "dataset.key")
+
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	if err := Run([]string{"sign", "-generate", "-key", keyPath}); err != nil {
+		t.Fatalf("Run(sign -generate) error = %v", err)
+	}
+	if err := Run([]string{"sign", "-generate", "-key", keyPath}); err == nil {
+		t.Error("Run(sign -generate) overwrote an existing key")
+	}
+	if err := Run([]string{"sign", "-key", keyPath, "-in", csvPath}); err != nil {
+		t.Fatalf("Run(sign) error = %v", err)
+	}
+
+	key, err := readPrivateKey(keyPath)
+	if err != nil {
+		t.Fatalf("readPrivateKey() error = %v", err)
+	}
+	publicKey := key.Public().(ed25519.PublicKey)
+
+	if _, err := repository.NewMemoryRepository(csvPath, repository.WithPublicKey(publicKey)); err != nil {
+		t.Errorf("NewMemoryRepository() of the signed CSV error = %v", err)
+	}
+}
+
+func TestRun_SnapshotVerifiesSignature(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	keyPath := filepath.Join(dir, "dataset.key")
+	outPath := filepath.Join(dir, "data.snap")
+
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if err := Run([]string{"sign", "-generate", "-key", keyPath}); err != nil {
+		t.Fatalf("Run(sign -generate) error = %v", err)
+	}
+	key, err := readPrivateKey(keyPath)
+	if err != nil {
+		t.Fatalf("readPrivateKey() error = %v", err)
+	}
+	t.Setenv("DATASET_PUBLIC_KEY", base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+
+	if err := Run([]string{"snapshot", "-csv", csvPath, "-out", outPath}); !errors.Is(err, repository.ErrSignatureInvalid) {
+		t.Fatalf("Run(snapshot) of an unsigned CSV error = %v, want ErrSignatureInvalid", err)
+	}
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("Run(snapshot) of an unsigned CSV wrote %s", outPath)
+	}
+
+	if err := Run([]string{"sign", "-key", keyPath, "-in", csvPath}); err != nil {
+		t.Fatalf("Run(sign) error = %v", err)
+	}
+	if err := Run([]string{"snapshot", "-csv", csvPath, "-out", outPath}); err != nil {
+		t.Errorf("Run(snapshot) of a signed CSV error = %v", err)
+	}
+}
+
+func TestRun_Blocklist(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	outPath := filepath.Join(dir, "geoblock.acl")
+
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","CN","China","Fujian","Fuzhou","26.061390","119.306110","350004","+08:00"
+"16777472","16777727","CN","China","Beijing","Beijing","39.907500","116.397230","100006","+08:00"
+"16777728","16778239","US","United States","California","Los Angeles","34.052230","-118.243680","90001","-07:00"`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	if err := Run([]string{"blocklist", "-csv", csvPath, "-countries", "cn", "-format", "haproxy", "-out", outPath}); err != nil {
+		t.Fatalf("Run(blocklist) error = %v", err)
+	}
+
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	// The two adjacent CN ranges aggregate into one /23.
+	if !strings.HasSuffix(string(got), "\n1.0.0.0/23\n") || !strings.HasPrefix(string(got), "# Blocklist for CN\n") {
+		t.Errorf("blocklist output =\n%s", got)
+	}
+
+	if err := Run([]string{"blocklist", "-csv", csvPath, "-countries", "ZZ", "-out", outPath}); err == nil {
+		t.Error("Run(blocklist) with an unknown country should fail")
+	}
+	if err := Run([]string{"blocklist", "-csv", csvPath, "-countries", "CN", "-format", "pf", "-out", outPath}); err == nil {
+		t.Error("Run(blocklist) with an unknown format should fail")
+	}
+}
@@ -0,0 +1,555 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/service"
+	"arena-backend-challenge/pkg/logger"
+)
+
+// Log formats read by the enrich command.
+const (
+	logFormatCLF  = "clf"
+	logFormatJSON = "json"
+	logFormatCSV  = "csv"
+)
+
+// enrichBatchLines is how many records a worker enriches at a time; the
+// records in flight are bounded by it times a few batches per worker, so
+// memory does not depend on the size of the log.
+const enrichBatchLines = 1024
+
+// maxLogLineBytes is the longest CLF or JSON line accepted.
+const maxLogLineBytes = 4 << 20
+
+func runEnrich(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("enrich", flag.ContinueOnError)
+	csvPath := fs.String("csv", cfg.CSVFilePath, "IP2Location DB11 CSV to look the addresses up in")
+	snapPath := fs.String("snapshot", "", "binary snapshot to load instead of the CSV")
+	inPath := fs.String("in", "", "log file to read (default standard input)")
+	outPath := fs.String("out", "", "file to write (default standard output)")
+	format := fs.String("format", logFormatCLF, "log format: clf (Common or Combined Log Format), json (one object per line) or csv")
+	field := fs.String("field", "", "JSON member or CSV header holding the client IP (default remote_addr for json)")
+	pattern := fs.String("regex", "", "regular expression finding the client IP in a CLF or JSON line, from its \"ip\" or first group")
+	column := fs.Int("column", 0, "1-based column holding the client IP: space-separated field for clf, column for csv (default 1)")
+	prefix := fs.String("prefix", "geo_", "prefix of the country and city JSON members and CSV columns added")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "number of lookup workers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := enrichOptions{
+		format:  *format,
+		field:   *field,
+		column:  *column,
+		prefix:  *prefix,
+		workers: *workers,
+	}
+	if *pattern != "" {
+		if opts.regex, err = regexp.Compile(*pattern); err != nil {
+			return fmt.Errorf("-regex: %w", err)
+		}
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	// Standard output may carry the log, so progress goes to standard error.
+	logger.SetDefault(logger.New(os.Stderr, logger.INFO))
+
+	var in io.Reader = os.Stdin
+	if *inPath != "" {
+		f, err := os.Open(*inPath)
+		if err != nil {
+			return fmt.Errorf("open log: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+
+	repo, closeRepo, err := openDataset(cfg, *csvPath, *snapPath)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+	locationService := service.NewLocationService(repo)
+
+	start := time.Now()
+	var summary *enrichSummary
+	write := func(w io.Writer) error {
+		out := bufio.NewWriter(w)
+		var err error
+		if summary, err = enrichLog(in, out, locationService, opts); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+	if *outPath == "" {
+		err = write(os.Stdout)
+	} else {
+		err = writeFileAtomic(*outPath, write)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The summary goes to standard error, which never carries the log.
+	return summary.print(os.Stderr, time.Since(start))
+}
+
+type enrichOptions struct {
+	format string
+	// The client IP is found by exactly one of field, regex or column.
+	field   string
+	regex   *regexp.Regexp
+	column  int
+	prefix  string
+	workers int
+}
+
+// validate checks the options against the format and fills in the default
+// way of finding the client IP.
+func (o *enrichOptions) validate() error {
+	set := 0
+	for _, given := range []bool{o.field != "", o.regex != nil, o.column != 0} {
+		if given {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("-field, -regex and -column are mutually exclusive")
+	}
+	if o.column < 0 {
+		return fmt.Errorf("-column must be positive")
+	}
+	if o.workers <= 0 {
+		return fmt.Errorf("-workers must be positive")
+	}
+
+	switch o.format {
+	case logFormatCLF:
+		if o.field != "" {
+			return fmt.Errorf("-field needs -format json or csv")
+		}
+		if set == 0 {
+			o.column = 1
+		}
+	case logFormatJSON:
+		if o.column != 0 {
+			return fmt.Errorf("-column needs -format clf or csv")
+		}
+		if set == 0 {
+			o.field = "remote_addr"
+		}
+	case logFormatCSV:
+		if o.regex != nil {
+			return fmt.Errorf("-regex needs -format clf or json")
+		}
+		if set == 0 {
+			o.column = 1
+		}
+	default:
+		return fmt.Errorf("-format must be %s, %s or %s", logFormatCLF, logFormatJSON, logFormatCSV)
+	}
+	return nil
+}
+
+// logRecord is one line of a CLF or JSON log, or one row of a CSV.
+type logRecord struct {
+	line []byte
+	row  []string
+}
+
+// enrichBatch is a run of consecutive records enriched by one worker. done
+// is closed once out and the counts are ready.
+type enrichBatch struct {
+	records []logRecord
+	out     bytes.Buffer
+	counts  enrichCounts
+	err     error
+	done    chan struct{}
+}
+
+// enrichLog copies the log in to out with the country and city of each
+// record's client IP appended, and counts the hits per country. Batches of
+// records are enriched by a pool of workers and written in input order.
+func enrichLog(in io.Reader, out io.Writer, s *service.LocationService, opts enrichOptions) (*enrichSummary, error) {
+	enricher := &logEnricher{service: s, opts: opts, ipColumn: opts.column - 1}
+
+	var next func() (logRecord, error)
+	if opts.format == logFormatCSV {
+		reader := csv.NewReader(in)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return newEnrichSummary(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV header: %w", err)
+		}
+		if opts.field != "" {
+			if enricher.ipColumn = slices.Index(header, opts.field); enricher.ipColumn < 0 {
+				return nil, fmt.Errorf("CSV header has no %q column", opts.field)
+			}
+		}
+		writer := csv.NewWriter(out)
+		if err := writer.Write(append(header, opts.prefix+"country", opts.prefix+"city")); err != nil {
+			return nil, err
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+		next = func() (logRecord, error) {
+			row, err := reader.Read()
+			return logRecord{row: row}, err
+		}
+	} else {
+		scanner := bufio.NewScanner(in)
+		scanner.Buffer(make([]byte, 0, 64<<10), maxLogLineBytes)
+		next = func() (logRecord, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return logRecord{}, err
+				}
+				return logRecord{}, io.EOF
+			}
+			return logRecord{line: bytes.Clone(scanner.Bytes())}, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jobs := make(chan *enrichBatch, opts.workers)
+	// order hands the batches to the writer in input order; its capacity
+	// bounds how far the reader gets ahead.
+	order := make(chan *enrichBatch, 2*opts.workers)
+	var readErr error
+	go func() {
+		defer close(jobs)
+		defer close(order)
+		for line := 1; ; {
+			batch := &enrichBatch{done: make(chan struct{})}
+			for len(batch.records) < enrichBatchLines {
+				record, err := next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					readErr = fmt.Errorf("read record %d: %w", line, err)
+					break
+				}
+				batch.records = append(batch.records, record)
+				line++
+			}
+			if len(batch.records) == 0 {
+				return
+			}
+			select {
+			case order <- batch:
+			case <-ctx.Done():
+				return
+			}
+			jobs <- batch
+			if ctx.Err() != nil {
+				return
+			}
+			if readErr != nil || len(batch.records) < enrichBatchLines {
+				return
+			}
+		}
+	}()
+
+	for range opts.workers {
+		go func() {
+			for batch := range jobs {
+				enricher.enrich(batch)
+				close(batch.done)
+			}
+		}()
+	}
+
+	summary := newEnrichSummary()
+	for batch := range order {
+		<-batch.done
+		if batch.err == nil {
+			_, batch.err = out.Write(batch.out.Bytes())
+		}
+		if batch.err != nil {
+			cancel()
+			// Let the workers finish what was queued.
+			for range order {
+			}
+			return nil, fmt.Errorf("write enriched log: %w", batch.err)
+		}
+		summary.add(&batch.counts)
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	return summary, nil
+}
+
+// logEnricher finds the client IP of records and renders them with its
+// location appended.
+type logEnricher struct {
+	service *service.LocationService
+	opts    enrichOptions
+	// ipColumn is the 0-based column of the client IP in CLF and CSV
+	// records.
+	ipColumn int
+}
+
+func (e *logEnricher) enrich(batch *enrichBatch) {
+	batch.counts = enrichCounts{countries: make(map[string]*countryHits)}
+
+	// members holds each JSON record's members; nil for a line that is not
+	// an object, which is copied unchanged.
+	var members []map[string]json.RawMessage
+	if e.opts.format == logFormatJSON {
+		members = make([]map[string]json.RawMessage, len(batch.records))
+	}
+
+	ips := make([]string, 0, len(batch.records))
+	found := make([]bool, len(batch.records))
+	for i, record := range batch.records {
+		var ip string
+		var ok bool
+		switch {
+		case e.opts.format == logFormatJSON:
+			if err := json.Unmarshal(record.line, &members[i]); err != nil {
+				members[i] = nil
+				break
+			}
+			if e.opts.regex != nil {
+				ip, ok = e.match(record.line)
+			} else {
+				ok = json.Unmarshal(members[i][e.opts.field], &ip) == nil && ip != ""
+			}
+		case e.opts.regex != nil:
+			ip, ok = e.match(record.line)
+		case record.row != nil:
+			if ok = e.ipColumn < len(record.row); ok {
+				ip = record.row[e.ipColumn]
+			}
+		default:
+			fields := strings.Fields(string(record.line))
+			if ok = e.ipColumn < len(fields); ok {
+				ip = fields[e.ipColumn]
+			}
+		}
+		if ok {
+			ips = append(ips, stripPort(ip))
+			found[i] = true
+		}
+	}
+
+	results := e.service.GetLocationsByIPs(ips)
+	var csvOut *csv.Writer
+	if e.opts.format == logFormatCSV {
+		csvOut = csv.NewWriter(&batch.out)
+	}
+	for i, record := range batch.records {
+		var location *domain.Location
+		if found[i] {
+			result := results[0]
+			results = results[1:]
+			location = result.Location
+			switch {
+			case result.Err == nil:
+			case errors.Is(result.Err, domain.ErrLocationNotFound):
+				batch.counts.notFound++
+			default:
+				batch.counts.invalid++
+			}
+		} else {
+			batch.counts.invalid++
+		}
+		batch.counts.addHit(location)
+
+		switch {
+		case csvOut != nil:
+			country, city := "", ""
+			if location != nil {
+				country, city = location.CountryCode, location.City
+			}
+			if err := csvOut.Write(append(record.row, country, city)); err != nil {
+				batch.err = err
+				return
+			}
+		case e.opts.format == logFormatJSON:
+			e.writeJSON(&batch.out, record.line, members[i] != nil, location)
+		default:
+			country, city := "-", "-"
+			if location != nil {
+				country, city = location.CountryCode, location.City
+			}
+			batch.out.Write(record.line)
+			batch.out.WriteString(" " + strconv.Quote(country) + " " + strconv.Quote(city) + "\n")
+		}
+	}
+	if csvOut != nil {
+		csvOut.Flush()
+		batch.err = csvOut.Error()
+	}
+}
+
+// match returns the client IP found by the regular expression: its "ip"
+// group, else its first group, else the whole match.
+func (e *logEnricher) match(line []byte) (string, bool) {
+	m := e.opts.regex.FindSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	group := e.opts.regex.SubexpIndex("ip")
+	if group < 0 {
+		group = min(1, len(m)-1)
+	}
+	return string(m[group]), len(m[group]) > 0
+}
+
+// writeJSON writes line with the country and city members added before its
+// closing brace, so the others keep their order and bytes. A line that is
+// not an object is copied unchanged.
+func (e *logEnricher) writeJSON(out *bytes.Buffer, line []byte, isObject bool, location *domain.Location) {
+	line = bytes.TrimSpace(line)
+	if !isObject || len(line) == 0 || line[0] != '{' {
+		out.Write(line)
+		out.WriteByte('\n')
+		return
+	}
+
+	var country, city any
+	if location != nil {
+		country, city = location.CountryCode, location.City
+	}
+	countryKey, _ := json.Marshal(e.opts.prefix + "country")
+	cityKey, _ := json.Marshal(e.opts.prefix + "city")
+	countryValue, _ := json.Marshal(country)
+	cityValue, _ := json.Marshal(city)
+
+	body := bytes.TrimSpace(line[1 : len(line)-1])
+	out.WriteByte('{')
+	if len(body) > 0 {
+		out.Write(body)
+		out.WriteByte(',')
+	}
+	fmt.Fprintf(out, "%s:%s,%s:%s}\n", countryKey, countryValue, cityKey, cityValue)
+}
+
+// stripPort drops the port of an address written as host:port or
+// [host]:port.
+func stripPort(ip string) string {
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+}
+
+// enrichCounts tallies the records of a batch.
+type enrichCounts struct {
+	records   int
+	notFound  int
+	invalid   int
+	countries map[string]*countryHits
+}
+
+type countryHits struct {
+	code string
+	name string
+	hits int
+}
+
+func (c *enrichCounts) addHit(location *domain.Location) {
+	c.records++
+	if location == nil {
+		return
+	}
+	hits, ok := c.countries[location.CountryCode]
+	if !ok {
+		hits = &countryHits{code: location.CountryCode, name: location.Country}
+		c.countries[location.CountryCode] = hits
+	}
+	hits.hits++
+}
+
+// enrichSummary totals the counts of every batch.
+type enrichSummary struct {
+	enrichCounts
+}
+
+func newEnrichSummary() *enrichSummary {
+	return &enrichSummary{enrichCounts{countries: make(map[string]*countryHits)}}
+}
+
+func (s *enrichSummary) add(c *enrichCounts) {
+	s.records += c.records
+	s.notFound += c.notFound
+	s.invalid += c.invalid
+	for code, hits := range c.countries {
+		total, ok := s.countries[code]
+		if !ok {
+			total = &countryHits{code: hits.code, name: hits.name}
+			s.countries[code] = total
+		}
+		total.hits += hits.hits
+	}
+}
+
+// print writes the totals and the hits per country, most first.
+func (s *enrichSummary) print(w io.Writer, duration time.Duration) error {
+	located := s.records - s.notFound - s.invalid
+	fmt.Fprintf(w, "Enriched %d records in %v - located %d, not found %d, no valid IP %d\n",
+		s.records, duration.Round(time.Millisecond), located, s.notFound, s.invalid)
+	if s.records == 0 {
+		return nil
+	}
+
+	countries := make([]*countryHits, 0, len(s.countries))
+	for _, hits := range s.countries {
+		countries = append(countries, hits)
+	}
+	slices.SortFunc(countries, func(a, b *countryHits) int {
+		if c := cmp.Compare(b.hits, a.hits); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.code, b.code)
+	})
+	if unlocated := s.notFound + s.invalid; unlocated > 0 {
+		countries = append(countries, &countryHits{code: "-", name: "(not located)", hits: unlocated})
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w)
+	fmt.Fprintln(tw, "COUNTRY\tNAME\tHITS\tSHARE")
+	for _, hits := range countries {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f%%\n", hits.code, hits.name, hits.hits, 100*float64(hits.hits)/float64(s.records))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/repository"
+	"arena-backend-challenge/internal/service"
+)
+
+// newEnrichTestService locates 10.0.0.0/24 in Rio, odd last octets in
+// São Paulo, and nothing else.
+func newEnrichTestService() *service.LocationService {
+	return service.NewLocationService(&repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			if ipID>>8 != 0x0a0000 {
+				return nil, domain.ErrLocationNotFound
+			}
+			city := "Rio de Janeiro"
+			if ipID%2 == 1 {
+				city = "São Paulo"
+			}
+			return &domain.Location{LowerIPID: ipID, UpperIPID: ipID, CountryCode: "BR", Country: "Brazil", City: city}, nil
+		},
+	})
+}
+
+func TestEnrichLog(t *testing.T) {
+	tests := []struct {
+		name string
+		opts enrichOptions
+		in   string
+		want string
+	}{
+		{
+			name: "combined log",
+			opts: enrichOptions{format: logFormatCLF},
+			in: `10.0.0.2 - - [10/Oct/2025:13:55:36 +0000] "GET / HTTP/1.1" 200 2326 "-" "curl/8.0"
+10.0.0.3 - frank [10/Oct/2025:13:55:37 +0000] "GET /a HTTP/1.1" 404 0
+192.0.2.1 - - [10/Oct/2025:13:55:38 +0000] "GET / HTTP/1.1" 200 2326
+`,
+			want: `10.0.0.2 - - [10/Oct/2025:13:55:36 +0000] "GET / HTTP/1.1" 200 2326 "-" "curl/8.0" "BR" "Rio de Janeiro"
+10.0.0.3 - frank [10/Oct/2025:13:55:37 +0000] "GET /a HTTP/1.1" 404 0 "BR" "São Paulo"
+192.0.2.1 - - [10/Oct/2025:13:55:38 +0000] "GET / HTTP/1.1" 200 2326 "-" "-"
+`,
+		},
+		{
+			name: "regex with a port",
+			opts: enrichOptions{format: logFormatCLF, regex: regexp.MustCompile(`client=(?P<ip>\S+)`)},
+			in:   "ts=1 client=10.0.0.2:5555 path=/\nts=2 path=/\n",
+			want: "ts=1 client=10.0.0.2:5555 path=/ \"BR\" \"Rio de Janeiro\"\nts=2 path=/ \"-\" \"-\"\n",
+		},
+		{
+			name: "JSON log",
+			opts: enrichOptions{format: logFormatJSON, field: "remote_addr", prefix: "geo_"},
+			in:   "{\"remote_addr\": \"10.0.0.3\", \"status\": 200}\n{\"remote_addr\":\"bogus\"}\nnot json\n{}\n",
+			want: `{"remote_addr": "10.0.0.3", "status": 200,"geo_country":"BR","geo_city":"São Paulo"}
+{"remote_addr":"bogus","geo_country":null,"geo_city":null}
+not json
+{"geo_country":null,"geo_city":null}
+`,
+		},
+		{
+			name: "CSV by header",
+			opts: enrichOptions{format: logFormatCSV, field: "client", prefix: "geo_"},
+			in:   "time,client,path\n1,10.0.0.2,\"/a,b\"\n2,,/\n",
+			want: "time,client,path,geo_country,geo_city\n1,10.0.0.2,\"/a,b\",BR,Rio de Janeiro\n2,,/,,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.workers = 2
+			if err := opts.validate(); err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+
+			var out bytes.Buffer
+			if _, err := enrichLog(strings.NewReader(tt.in), &out, newEnrichTestService(), opts); err != nil {
+				t.Fatalf("enrichLog() error = %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("enrichLog() output =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Batches enriched by different workers are still written in input order.
+func TestEnrichLog_Order(t *testing.T) {
+	var in, want strings.Builder
+	for i := range 3*enrichBatchLines + 17 {
+		ip := fmt.Sprintf("10.0.%d.%d", i%3, i%256)
+		fmt.Fprintf(&in, "%s line %d\n", ip, i)
+		location := `"-" "-"`
+		if i%3 == 0 {
+			location = `"BR" "Rio de Janeiro"`
+			if i%2 == 1 {
+				location = `"BR" "São Paulo"`
+			}
+		}
+		fmt.Fprintf(&want, "%s line %d %s\n", ip, i, location)
+	}
+
+	opts := enrichOptions{format: logFormatCLF, workers: 4}
+	if err := opts.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	var out bytes.Buffer
+	summary, err := enrichLog(strings.NewReader(in.String()), &out, newEnrichTestService(), opts)
+	if err != nil {
+		t.Fatalf("enrichLog() error = %v", err)
+	}
+	if out.String() != want.String() {
+		t.Error("enrichLog() output is not in input order")
+	}
+
+	records := 3*enrichBatchLines + 17
+	located := (records + 2) / 3
+	if summary.records != records || summary.notFound != records-located || summary.countries["BR"].hits != located {
+		t.Errorf("summary = %d records, %d not found, %d BR, want %d, %d, %d",
+			summary.records, summary.notFound, summary.countries["BR"].hits, records, records-located, located)
+	}
+
+	var report bytes.Buffer
+	if err := summary.print(&report, 0); err != nil {
+		t.Fatalf("print() error = %v", err)
+	}
+	if !strings.Contains(report.String(), "BR       Brazil         1030  33.3%") {
+		t.Errorf("summary report =\n%s", report.String())
+	}
+}
+
+func TestEnrichOptions_Validate(t *testing.T) {
+	tests := []enrichOptions{
+		{format: "w3c", workers: 1},
+		{format: logFormatCLF, field: "ip", workers: 1},
+		{format: logFormatJSON, column: 2, workers: 1},
+		{format: logFormatCSV, regex: regexp.MustCompile(`(.*)`), workers: 1},
+		{format: logFormatCSV, field: "ip", column: 2, workers: 1},
+		{format: logFormatCLF, workers: 0},
+	}
+	for _, opts := range tests {
+		if err := opts.validate(); err == nil {
+			t.Errorf("validate(%+v) should fail", opts)
+		}
+	}
+}
+
+func TestRun_Enrich(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	logPath := filepath.Join(dir, "access.log")
+	outPath := filepath.Join(dir, "access.geo.log")
+
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if err := os.WriteFile(logPath, []byte("8.8.8.8 - - [10/Oct/2025:13:55:36 +0000] \"GET / HTTP/1.1\" 200 5\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+
+	if err := Run([]string{"enrich", "-csv", csvPath, "-in", logPath, "-out", outPath}); err != nil {
+		t.Fatalf("Run(enrich) error = %v", err)
+	}
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := "8.8.8.8 - - [10/Oct/2025:13:55:36 +0000] \"GET / HTTP/1.1\" 200 5 \"US\" \"Mountain View\"\n"; string(got) != want {
+		t.Errorf("enriched log = %q, want %q", got, want)
+	}
+
+	if err := Run([]string{"enrich", "-csv", csvPath, "-in", logPath, "-format", "json", "-column", "2"}); err == nil {
+		t.Error("Run(enrich) with -column for JSON should fail")
+	}
+}
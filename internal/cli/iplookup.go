@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/handler"
+	"arena-backend-challenge/internal/repository"
+	"arena-backend-challenge/internal/service"
+	"arena-backend-challenge/pkg/logger"
+)
+
+// lookupChunkLines bounds the addresses read from standard input before
+// they are looked up and written, so any number can be piped through.
+const lookupChunkLines = 1024
+
+// Output formats of Lookup.
+const (
+	lookupFormatTable = "table"
+	lookupFormatJSON  = "json"
+	lookupFormatCSV   = "csv"
+)
+
+// Lookup is the iplookup binary: it resolves the addresses given as
+// arguments, or else one per line of stdin, against a local CSV dataset or
+// snapshot and writes them to stdout as a table, JSON or CSV. Blank lines
+// and lines starting with # are skipped.
+func Lookup(args []string, stdin io.Reader, stdout io.Writer) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("iplookup", flag.ContinueOnError)
+	csvPath := fs.String("csv", cfg.CSVFilePath, "IP2Location DB11 CSV to load")
+	snapPath := fs.String("snapshot", "", "binary snapshot to load instead of the CSV")
+	asnPath := fs.String("asn", cfg.ASNCSVFilePath, "IP2Location ASN CSV to add the autonomous system (optional)")
+	format := fs.String("format", lookupFormatTable, "output format: table, json or csv")
+	verbose := fs.Bool("v", false, "log dataset loading to standard error")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: iplookup [flags] [address ...]")
+		fmt.Fprintln(fs.Output(), "\nWithout addresses, one address per line is read from standard input.")
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Standard output is for results only.
+	level := logger.WARNING
+	if *verbose {
+		level = logger.INFO
+	}
+	logger.SetDefault(logger.New(os.Stderr, level))
+
+	out := bufio.NewWriter(stdout)
+	var results resultWriter
+	switch *format {
+	case lookupFormatTable:
+		results = newTableResultWriter(out, *asnPath != "")
+	case lookupFormatJSON:
+		results = &jsonResultWriter{w: out}
+	case lookupFormatCSV:
+		results = &csvResultWriter{w: csv.NewWriter(out)}
+	default:
+		return fmt.Errorf("-format must be %s, %s or %s", lookupFormatTable, lookupFormatJSON, lookupFormatCSV)
+	}
+
+	repo, closeRepo, err := openDataset(cfg, *csvPath, *snapPath)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	var opts []service.Option
+	if *asnPath != "" {
+		asnRepo, err := repository.NewASNRepository(*asnPath)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, service.WithASN(asnRepo))
+	}
+	locationService := service.NewLocationService(repo, opts...)
+
+	lookup := func(ips []string) error {
+		batch := make([]v1.BatchLocationResult, len(ips))
+		for i, result := range locationService.GetLocationsByIPs(ips) {
+			batch[i] = v1.BatchLocationResult{IP: ips[i], LookupResult: handler.NewLookupResult(ips[i], result)}
+		}
+		if err := results.write(batch); err != nil {
+			return fmt.Errorf("write results: %w", err)
+		}
+		return out.Flush()
+	}
+
+	if fs.NArg() > 0 {
+		if err := lookup(fs.Args()); err != nil {
+			return err
+		}
+	} else {
+		scanner := bufio.NewScanner(stdin)
+		ips := make([]string, 0, lookupChunkLines)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if ips = append(ips, line); len(ips) == lookupChunkLines {
+				if err := lookup(ips); err != nil {
+					return err
+				}
+				ips = ips[:0]
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("read standard input: %w", err)
+		}
+		if err := lookup(ips); err != nil {
+			return err
+		}
+	}
+
+	if err := results.close(); err != nil {
+		return fmt.Errorf("write results: %w", err)
+	}
+	return out.Flush()
+}
+
+// resultWriter renders lookup results in one output format. write is called
+// once per chunk of addresses, close after the last one.
+type resultWriter interface {
+	write(results []v1.BatchLocationResult) error
+	close() error
+}
+
+// tableResultWriter aligns the columns of each chunk; the reason of an
+// invalid address or failed lookup goes last, outside the alignment.
+type tableResultWriter struct {
+	w       *tabwriter.Writer
+	withASN bool
+	header  bool
+}
+
+func newTableResultWriter(w io.Writer, withASN bool) *tableResultWriter {
+	return &tableResultWriter{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0), withASN: withASN}
+}
+
+func (t *tableResultWriter) write(results []v1.BatchLocationResult) error {
+	if !t.header {
+		columns := []string{"IP", "STATUS", "COUNTRY", "REGION", "CITY", "LATITUDE", "LONGITUDE", "TIME ZONE"}
+		if t.withASN {
+			columns = append(columns, "ASN", "AS NAME")
+		}
+		fmt.Fprintln(t.w, strings.Join(append(columns, "REASON"), "\t"))
+		t.header = true
+	}
+
+	for _, result := range results {
+		cells := make([]string, 0, 11)
+		cells = append(cells, result.IP, result.Status)
+		if location := result.Location; location != nil {
+			cells = append(cells, location.CountryCode, location.Region, location.City,
+				formatCoordinate(location.Latitude), formatCoordinate(location.Longitude), location.TimeZone)
+		} else {
+			cells = append(cells, "", "", "", "", "", "")
+		}
+		if t.withASN {
+			if location := result.Location; location != nil && location.ASN != nil {
+				cells = append(cells, "AS"+strconv.FormatUint(uint64(location.ASN.Number), 10), location.ASN.Name)
+			} else {
+				cells = append(cells, "", "")
+			}
+		}
+		fmt.Fprintln(t.w, strings.Join(append(cells, result.Reason), "\t"))
+	}
+	return t.w.Flush()
+}
+
+func (t *tableResultWriter) close() error {
+	return nil
+}
+
+// jsonResultWriter writes a single JSON array of results, one per line, as
+// the chunks come.
+type jsonResultWriter struct {
+	w       io.Writer
+	written int
+}
+
+func (j *jsonResultWriter) write(results []v1.BatchLocationResult) error {
+	for _, result := range results {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		separator := ",\n  "
+		if j.written == 0 {
+			separator = "[\n  "
+		}
+		if _, err := io.WriteString(j.w, separator); err != nil {
+			return err
+		}
+		if _, err := j.w.Write(b); err != nil {
+			return err
+		}
+		j.written++
+	}
+	return nil
+}
+
+func (j *jsonResultWriter) close() error {
+	closing := "\n]\n"
+	if j.written == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
+
+// csvResultWriter writes one row per result under a header row.
+type csvResultWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (c *csvResultWriter) write(results []v1.BatchLocationResult) error {
+	if !c.header {
+		if err := c.w.Write([]string{"ip", "status", "reason", "country_code", "country", "region", "city",
+			"latitude", "longitude", "zip_code", "time_zone", "asn", "as_name"}); err != nil {
+			return err
+		}
+		c.header = true
+	}
+
+	for _, result := range results {
+		row := make([]string, 13)
+		row[0], row[1], row[2] = result.IP, result.Status, result.Reason
+		if location := result.Location; location != nil {
+			row[3], row[4], row[5], row[6] = location.CountryCode, location.Country, location.Region, location.City
+			row[7], row[8] = formatCoordinate(location.Latitude), formatCoordinate(location.Longitude)
+			row[9], row[10] = location.ZipCode, location.TimeZone
+			if location.ASN != nil {
+				row[11], row[12] = strconv.FormatUint(uint64(location.ASN.Number), 10), location.ASN.Name
+			}
+		}
+		if err := c.w.Write(row); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvResultWriter) close() error {
+	return nil
+}
+
+func formatCoordinate(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
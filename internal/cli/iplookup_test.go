@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	snapPath := filepath.Join(dir, "data.snap")
+
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+	if err := os.WriteFile(csvPath, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if err := Run([]string{"snapshot", "-csv", csvPath, "-out", snapPath}); err != nil {
+		t.Fatalf("Run(snapshot) error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		want  string
+	}{
+		{
+			name: "CSV from arguments",
+			args: []string{"-csv", csvPath, "-format", "csv", "8.8.8.8", "10.0.0.1", "bogus"},
+			want: `ip,status,reason,country_code,country,region,city,latitude,longitude,zip_code,time_zone,asn,as_name
+8.8.8.8,found,,US,United States,California,Mountain View,37.405992,-122.078515,94035,-07:00,,
+10.0.0.1,not_found,,,,,,,,,,,
+bogus,invalid,"invalid IP address: invalid IP format 'bogus': expected 4 octets, got 1",,,,,,,,,,
+`,
+		},
+		{
+			name:  "JSON from stdin and a snapshot",
+			args:  []string{"-snapshot", snapPath, "-format", "json"},
+			stdin: "# addresses\n8.8.8.8\n\n  10.0.0.1  \n",
+			want: `[
+  {"ip":"8.8.8.8","status":"found","location":{"country":"United States","countryCode":"US","region":"California","city":"Mountain View","latitude":37.405992,"longitude":-122.078515,"zipCode":"94035","timeZone":"-07:00"}},
+  {"ip":"10.0.0.1","status":"not_found"}
+]
+`,
+		},
+		{
+			name:  "empty JSON",
+			args:  []string{"-csv", csvPath, "-format", "json"},
+			stdin: "",
+			want:  "[]\n",
+		},
+		{
+			name: "table",
+			args: []string{"-csv", csvPath, "8.8.8.8"},
+			want: "IP       STATUS  COUNTRY  REGION      CITY           LATITUDE   LONGITUDE    TIME ZONE  REASON\n" +
+				"8.8.8.8  found   US       California  Mountain View  37.405992  -122.078515  -07:00     \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			if err := Lookup(tt.args, strings.NewReader(tt.stdin), &stdout); err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("Lookup() output =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if err := Lookup([]string{"-csv", csvPath, "-format", "xml", "8.8.8.8"}, nil, &bytes.Buffer{}); err == nil {
+		t.Error("Lookup() with an unknown format should fail")
+	}
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/repository"
+	"arena-backend-challenge/pkg/logger"
+	"arena-backend-challenge/pkg/mmdb"
+)
+
+func runMMDB(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("mmdb", flag.ContinueOnError)
+	csvPath := fs.String("csv", cfg.CSVFilePath, "IP2Location DB11 CSV to export")
+	outPath := fs.String("out", "data/ip-locations.mmdb", "MaxMind DB file to write")
+	databaseType := fs.String("type", "IP2Location-DB11", "database_type recorded in the metadata")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	repo, err := repository.NewMemoryRepository(*csvPath, cfg.CSVOptions()...)
+	if err != nil {
+		return err
+	}
+
+	options := mmdb.WriterOptions{
+		DatabaseType: *databaseType,
+		Description:  map[string]string{"en": "Exported from " + *csvPath},
+		Languages:    []string{"en"},
+	}
+	if err := writeFileAtomic(*outPath, func(w io.Writer) error {
+		return repository.WriteMMDB(w, repo, options)
+	}); err != nil {
+		return fmt.Errorf("write %s: %w", *outPath, err)
+	}
+
+	info, err := os.Stat(*outPath)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("MMDB written - Path: %s - Ranges: %d - Size: %d bytes - Duration: %v",
+		*outPath, repo.Len(), info.Size(), time.Since(start).Round(time.Millisecond))
+	return nil
+}
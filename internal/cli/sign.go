@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/repository"
+	"arena-backend-challenge/pkg/logger"
+)
+
+func runSign(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	keyPath := fs.String("key", "", "base64 Ed25519 private key file")
+	generate := fs.Bool("generate", false, "write a new private key to -key and print its public key instead of signing")
+	inPath := fs.String("in", cfg.CSVFilePath, "dataset file to sign")
+	outPath := fs.String("out", "", "signature file to write (default <in>"+repository.SignatureSuffix+")")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *keyPath == "" {
+		return fmt.Errorf("-key is required")
+	}
+
+	if *generate {
+		return generateKey(*keyPath)
+	}
+
+	key, err := readPrivateKey(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	signature, err := repository.SignDataset(*inPath, key)
+	if err != nil {
+		return err
+	}
+
+	if *outPath == "" {
+		*outPath = *inPath + repository.SignatureSuffix
+	}
+	if err := writeFileAtomic(*outPath, func(w io.Writer) error {
+		_, err := w.Write(signature)
+		return err
+	}); err != nil {
+		return fmt.Errorf("write %s: %w", *outPath, err)
+	}
+
+	logger.Infof("Signature written - Dataset: %s - Signature: %s", *inPath, *outPath)
+	return nil
+}
+
+// generateKey writes a new private key seed to path, refusing to overwrite
+// one, and prints the public key in the form DATASET_PUBLIC_KEY expects.
+func generateKey(path string) error {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("create key file: %w", err)
+	}
+	_, err = fmt.Fprintln(file, base64.StdEncoding.EncodeToString(privateKey.Seed()))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	fmt.Println(base64.StdEncoding.EncodeToString(publicKey))
+	return nil
+}
+
+// readPrivateKey reads a base64 Ed25519 seed (as written by -generate) or
+// full private key.
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decode key %s: %w", path, err)
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	}
+	return nil, fmt.Errorf("decode key %s: want a %d-byte seed or %d-byte private key, got %d bytes",
+		path, ed25519.SeedSize, ed25519.PrivateKeySize, len(key))
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/repository"
+	"arena-backend-challenge/pkg/logger"
+)
+
+func runSnapshot(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	csvPath := fs.String("csv", cfg.CSVFilePath, "IP2Location DB11 CSV to compile")
+	outPath := fs.String("out", cfg.SnapshotFilePath, "snapshot file to write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	repo, err := repository.NewMemoryRepository(*csvPath, cfg.CSVOptions()...)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(*outPath, func(w io.Writer) error {
+		return repository.WriteSnapshot(w, repo)
+	}); err != nil {
+		return fmt.Errorf("write %s: %w", *outPath, err)
+	}
+
+	info, err := os.Stat(*outPath)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Snapshot written - Path: %s - Ranges: %d - Size: %d bytes - Duration: %v",
+		*outPath, repo.Len(), info.Size(), time.Since(start).Round(time.Millisecond))
+	return nil
+}
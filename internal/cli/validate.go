@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"arena-backend-challenge/config"
+	"arena-backend-challenge/internal/repository"
+)
+
+func runValidate(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	csvPath := fs.String("csv", cfg.CSVFilePath, "IP2Location DB11 CSV to check")
+	strict := fs.Bool("strict", cfg.CSVStrictValidation, "fail when an issue count exceeds -max-issues")
+	maxIssues := fs.Int("max-issues", cfg.CSVValidationMaxIssues, "issues of each kind allowed in strict mode")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Load leniently so the report is printed even when strict mode fails.
+	repo, err := repository.NewMemoryRepository(*csvPath, cfg.CSVOptions()...)
+	if err != nil {
+		return err
+	}
+	report := repo.ValidationReport()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+
+	if *strict {
+		return report.Check(repository.UniformValidationLimits(*maxIssues))
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "errors"
+
+// ASN is the autonomous system announcing an address and the network (CIDR)
+// the address belongs to.
+type ASN struct {
+	Number  uint32
+	Name    string
+	Network string
+}
+
+// ASNRepository resolves IP numbers to autonomous systems.
+type ASNRepository interface {
+	FindASNByIPID(ipID uint32) (*ASN, error)
+	FindASNByIPv6ID(ipID IPv6ID) (*ASN, error)
+}
+
+var ErrASNNotFound = errors.New("ASN not found for the given IP")
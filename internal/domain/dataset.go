@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// DatasetInfo identifies the dataset a repository is serving, so a rollout
+// can be checked against the file that was shipped.
+type DatasetInfo struct {
+	Path   string
+	SHA256 string
+	// Version is the vendor release date ("2024-10" or "2024-10-01") taken
+	// from a sidecar file or the file name; empty when unknown.
+	Version string
+	// Rows is the number of ranges served; SkippedRows counts source rows
+	// that were not loaded. Both are zero when the format doesn't tell.
+	Rows        int
+	SkippedRows int
+	LoadedAt    time.Time
+}
+
+// DatasetInfoProvider is implemented by repositories that can describe the
+// dataset they serve.
+type DatasetInfoProvider interface {
+	DatasetInfo() DatasetInfo
+}
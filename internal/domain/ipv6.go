@@ -0,0 +1,64 @@
+package domain
+
+// IPv6ID is the 128-bit IP number used by the IP2Location IPv6 datasets,
+// split into its high and low 64-bit halves.
+type IPv6ID struct {
+	Hi uint64
+	Lo uint64
+}
+
+// ipv4MappedPrefix is the high 32 bits of the low half of ::ffff:0:0/96.
+const ipv4MappedPrefix = 0xffff
+
+// IPv4ToIPv6ID returns the IPv4-mapped (::ffff:a.b.c.d) form of an IPv4 ID.
+func IPv4ToIPv6ID(ipID uint32) IPv6ID {
+	return IPv6ID{Lo: ipv4MappedPrefix<<32 | uint64(ipID)}
+}
+
+// IPv4 returns the embedded IPv4 ID when id lies inside ::ffff:0:0/96.
+func (id IPv6ID) IPv4() (uint32, bool) {
+	if id.Hi != 0 || id.Lo>>32 != ipv4MappedPrefix {
+		return 0, false
+	}
+	return uint32(id.Lo), true
+}
+
+func (id IPv6ID) IsZero() bool {
+	return id.Hi == 0 && id.Lo == 0
+}
+
+// Compare returns -1, 0 or +1 depending on whether id is less than, equal to
+// or greater than other.
+func (id IPv6ID) Compare(other IPv6ID) int {
+	switch {
+	case id.Hi < other.Hi:
+		return -1
+	case id.Hi > other.Hi:
+		return 1
+	case id.Lo < other.Lo:
+		return -1
+	case id.Lo > other.Lo:
+		return 1
+	}
+	return 0
+}
+
+func (id IPv6ID) Less(other IPv6ID) bool {
+	return id.Compare(other) < 0
+}
+
+// Next returns id+1, wrapping to zero after the last address.
+func (id IPv6ID) Next() IPv6ID {
+	if id.Lo == ^uint64(0) {
+		return IPv6ID{Hi: id.Hi + 1}
+	}
+	return IPv6ID{Hi: id.Hi, Lo: id.Lo + 1}
+}
+
+// Prev returns id-1, wrapping to the last address before zero.
+func (id IPv6ID) Prev() IPv6ID {
+	if id.Lo == 0 {
+		return IPv6ID{Hi: id.Hi - 1, Lo: ^uint64(0)}
+	}
+	return IPv6ID{Hi: id.Hi, Lo: id.Lo - 1}
+}
@@ -5,15 +5,52 @@ import "errors"
 type Location struct {
 	LowerIPID   uint32
 	UpperIPID   uint32
+	LowerIPv6ID IPv6ID
+	UpperIPv6ID IPv6ID
 	Country     string
 	CountryCode string
+	Region      string
 	City        string
+	Latitude    float64
+	Longitude   float64
+	ZipCode     string
+	TimeZone    string
+	// Source names the dataset layer that answered. It is only set when
+	// override layers are configured.
+	Source string
+	// ASN is filled in by the service when an ASN dataset is configured.
+	ASN *ASN
+	// Threat is filled in by the service when a proxy dataset or Tor exit
+	// list is configured.
+	Threat *Threat
+}
+
+// IsIPv6 reports whether the location was loaded from a native IPv6 range.
+func (l *Location) IsIPv6() bool {
+	return !l.UpperIPv6ID.IsZero()
+}
+
+// LookupResult is the outcome of one address of a batch lookup: Location,
+// or an Err wrapping ErrInvalidIP, ErrLocationNotFound or a repository
+// failure.
+type LookupResult struct {
+	Location *Location
+	Err      error
 }
 
 type Repository interface {
 	FindByIPID(ipID uint32) (*Location, error)
+	FindByIPv6ID(ipID IPv6ID) (*Location, error)
+}
+
+// Reloader is implemented by repositories that can replace their dataset
+// while serving traffic. An empty path reloads the current source.
+type Reloader interface {
+	Reload(path string) error
 }
 
 var (
 	ErrLocationNotFound = errors.New("location not found for the given IP")
+	ErrInvalidIP        = errors.New("invalid IP address")
+	ErrReloadInProgress = errors.New("dataset reload already in progress")
 )
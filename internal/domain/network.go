@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+)
+
+// NetworkSearcher is implemented by repositories that can list every range
+// overlapping a block of addresses. Both methods call yield with each
+// overlapping range in address order until it returns false.
+type NetworkSearcher interface {
+	FindByIPIDRange(first, last uint32, yield func(*Location) bool)
+	FindByIPv6IDRange(first, last IPv6ID, yield func(*Location) bool)
+}
+
+// NetworkReport describes what a block of addresses maps to.
+type NetworkReport struct {
+	Prefix netip.Prefix
+	// Size is the number of addresses in Prefix; Covered counts those that
+	// fall in some range.
+	Size    *big.Int
+	Covered *big.Int
+	// Ranges lists the overlapping ranges in address order, capped by the
+	// service; TotalRanges counts all of them.
+	Ranges      []NetworkRange
+	TotalRanges int
+	// Countries splits Covered by country, largest share first.
+	Countries []CountryShare
+}
+
+// NetworkRange is a dataset range overlapping the queried block.
+type NetworkRange struct {
+	First    netip.Addr
+	Last     netip.Addr
+	Location *Location
+	// Addresses is how many addresses of the block the range covers.
+	Addresses *big.Int
+}
+
+// CountryShare is the part of a block that maps to one country.
+type CountryShare struct {
+	CountryCode string
+	Country     string
+	Addresses   *big.Int
+	// Share is Addresses as a fraction of the whole block.
+	Share float64
+}
+
+var ErrNetworkSearchUnsupported = errors.New("CIDR queries are not supported by the configured data source")
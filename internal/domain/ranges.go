@@ -0,0 +1,38 @@
+package domain
+
+import "net/netip"
+
+// RangeQuery selects the ranges of a country, or of one city in it, for a
+// reverse lookup.
+type RangeQuery struct {
+	// CountryCode is the ISO 3166 code; City, when set, narrows the result
+	// to that city. Both match case-insensitively.
+	CountryCode string
+	City        string
+	// IPVersion is 4 or 6 to return one family only, 0 for both.
+	IPVersion int
+	// Offset skips that many ranges; Limit caps the page, 0 meaning no cap.
+	Offset int
+	Limit  int
+}
+
+// IPRange is one dataset range returned by a reverse lookup.
+type IPRange struct {
+	First  netip.Addr
+	Last   netip.Addr
+	Region string
+	City   string
+}
+
+// RangePage is one page of a reverse lookup. Ranges are in address order,
+// IPv4 before IPv6; Total counts every match, not only this page.
+type RangePage struct {
+	Ranges []IPRange
+	Total  int
+}
+
+// RangeLister is implemented by repositories that can list the ranges
+// assigned to a place.
+type RangeLister interface {
+	ListRanges(q RangeQuery) RangePage
+}
@@ -0,0 +1,22 @@
+package domain
+
+// Threat classifies an address as an anonymizer. The zero value means the
+// address is not listed by any configured threat dataset.
+type Threat struct {
+	IsProxy   bool
+	IsVPN     bool
+	IsTor     bool
+	IsHosting bool
+	// ProxyType, Provider and UsageType are the IP2Proxy columns of the
+	// matching range, empty when the dataset doesn't carry them.
+	ProxyType string
+	Provider  string
+	UsageType string
+}
+
+// ThreatRepository classifies IP numbers. Unlisted addresses yield a zero
+// Threat rather than an error.
+type ThreatRepository interface {
+	FindThreatByIPID(ipID uint32) (*Threat, error)
+	FindThreatByIPv6ID(ipID IPv6ID) (*Threat, error)
+}
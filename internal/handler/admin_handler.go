@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/middleware"
+	"arena-backend-challenge/pkg/logger"
+)
+
+type AdminHandler struct {
+	reloader domain.Reloader
+	token    string
+}
+
+// NewAdminHandler creates the admin API. An empty token disables every admin
+// endpoint.
+func NewAdminHandler(reloader domain.Reloader, token string) *AdminHandler {
+	return &AdminHandler{
+		reloader: reloader,
+		token:    token,
+	}
+}
+
+// ReloadDataset godoc
+// @Summary Reload IP dataset
+// @Description Loads the configured dataset file again, validates it and atomically swaps it in. Lookups keep using the previous dataset until the swap.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} v1.ReloadResponse "Dataset reloaded"
+// @Failure 401 {object} v1.ErrorResponse "Missing or invalid admin token"
+// @Failure 403 {object} v1.ErrorResponse "Admin API is disabled"
+// @Failure 405 {object} v1.ErrorResponse "Method not allowed"
+// @Failure 409 {object} v1.ErrorResponse "A reload is already in progress"
+// @Failure 500 {object} v1.ErrorResponse "New dataset failed to load; previous dataset is still served"
+// @Router /admin/reload [post]
+func (h *AdminHandler) ReloadDataset(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorize(w, r) {
+		return
+	}
+
+	if err := h.reloader.Reload(""); err != nil {
+		duration := time.Since(start)
+
+		if errors.Is(err, domain.ErrReloadInProgress) {
+			sendError(w, err.Error(), http.StatusConflict)
+			logger.Warningf("Dataset reload rejected - Status: 409 - Duration: %v", duration)
+			return
+		}
+
+		sendError(w, err.Error(), http.StatusInternalServerError)
+		logger.Errorf("Dataset reload failed - Status: 500 - Duration: %v - Error chain: %v", duration, err)
+		return
+	}
+
+	duration := time.Since(start)
+	sendJSON(w, v1.ReloadResponse{Status: "reloaded", Duration: duration.String()}, http.StatusOK)
+	logger.Infof("Dataset reloaded via admin API - Status: 200 - Duration: %v", duration)
+}
+
+func (h *AdminHandler) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if h.token == "" {
+		sendError(w, "Admin API is disabled", http.StatusForbidden)
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		sendError(w, "Missing or invalid admin token", http.StatusUnauthorized)
+		client, _ := middleware.ClientIP(r)
+		logger.Warningf("Unauthorized admin request from %s", client)
+		return false
+	}
+
+	return true
+}
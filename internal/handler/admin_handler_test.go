@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/internal/domain"
+)
+
+type mockReloader struct {
+	calls int
+	err   error
+}
+
+func (m *mockReloader) Reload(path string) error {
+	m.calls++
+	return m.err
+}
+
+func TestAdminHandler_ReloadDataset(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		method        string
+		authorization string
+		reloadErr     error
+		wantStatus    int
+		wantCalls     int
+	}{
+		{
+			name:          "valid token - reloaded",
+			token:         "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer secret",
+			wantStatus:    http.StatusOK,
+			wantCalls:     1,
+		},
+		{
+			name:          "wrong token",
+			token:         "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer nope",
+			wantStatus:    http.StatusUnauthorized,
+		},
+		{
+			name:       "missing token",
+			token:      "secret",
+			method:     http.MethodPost,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "admin API disabled",
+			token:         "",
+			method:        http.MethodPost,
+			authorization: "Bearer ",
+			wantStatus:    http.StatusForbidden,
+		},
+		{
+			name:          "GET not allowed",
+			token:         "secret",
+			method:        http.MethodGet,
+			authorization: "Bearer secret",
+			wantStatus:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:          "reload already running",
+			token:         "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer secret",
+			reloadErr:     domain.ErrReloadInProgress,
+			wantStatus:    http.StatusConflict,
+			wantCalls:     1,
+		},
+		{
+			name:          "new dataset invalid",
+			token:         "secret",
+			method:        http.MethodPost,
+			authorization: "Bearer secret",
+			reloadErr:     errors.New("dataset has no valid rows"),
+			wantStatus:    http.StatusInternalServerError,
+			wantCalls:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloader := &mockReloader{err: tt.reloadErr}
+			handler := NewAdminHandler(reloader, tt.token)
+
+			req := httptest.NewRequest(tt.method, "/admin/reload", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ReloadDataset(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("ReloadDataset() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+			if reloader.calls != tt.wantCalls {
+				t.Errorf("ReloadDataset() called Reload %d times, want %d", reloader.calls, tt.wantCalls)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var resp v1.ReloadResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("Failed to decode reload response: %v", err)
+				}
+				if resp.Status != "reloaded" {
+					t.Errorf("ReloadDataset() status field = %v, want reloaded", resp.Status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/pkg/logger"
+)
+
+// GetLocations godoc
+// @Summary Get the locations of many IPs
+// @Description Looks up a JSON array of IPv4 and IPv6 addresses in one request. The response has one result per address, in request order, with status found (and the location), not_found, invalid or error (with a reason). An address that fails does not fail the batch. The number of addresses and the body size are capped by BATCH_MAX_SIZE and BATCH_MAX_BODY_KB.
+// @Tags Location
+// @Accept json
+// @Produce json
+// @Param ips body []string true "Addresses to look up (e.g., [\"8.8.8.8\", \"2001:db8::1\"])"
+// @Success 200 {object} v1.BatchLocationResponse "One result per address"
+// @Failure 400 {object} v1.ErrorResponse "Body is not a JSON array of strings"
+// @Failure 405 {object} v1.ErrorResponse "Method not allowed"
+// @Failure 413 {object} v1.ErrorResponse "Too many addresses or body too large"
+// @Router /ip/locations [post]
+func (h *LocationHandler) GetLocations(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ips []string
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.batchMaxBodyBytes))
+	if err := decoder.Decode(&ips); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			sendError(w, fmt.Sprintf("Request body exceeds %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		sendError(w, "Request body must be a JSON array of IP addresses: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(ips) > h.batchMaxSize {
+		sendError(w, fmt.Sprintf("Batch has %d addresses, at most %d are allowed", len(ips), h.batchMaxSize),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	response := v1.BatchLocationResponse{Results: make([]v1.BatchLocationResult, len(ips))}
+	var found int
+	for i, result := range h.service.GetLocationsByIPs(ips) {
+		response.Results[i] = v1.BatchLocationResult{IP: ips[i], LookupResult: NewLookupResult(ips[i], result)}
+		if result.Err == nil {
+			found++
+		}
+	}
+
+	sendJSON(w, response, http.StatusOK)
+
+	logger.Infof("Batch lookup - IPs: %d - Found: %d - Status: 200 - Duration: %v",
+		len(ips), found, time.Since(start))
+}
+
+// NewLookupResult maps the outcome of looking up ip onto its API
+// representation.
+func NewLookupResult(ip string, result domain.LookupResult) v1.LookupResult {
+	switch {
+	case result.Err == nil:
+		location := newLocationResponse(result.Location)
+		return v1.LookupResult{Status: v1.BatchStatusFound, Location: &location}
+	case errors.Is(result.Err, domain.ErrLocationNotFound):
+		return v1.LookupResult{Status: v1.BatchStatusNotFound}
+	case errors.Is(result.Err, domain.ErrInvalidIP):
+		return v1.LookupResult{Status: v1.BatchStatusInvalid, Reason: result.Err.Error()}
+	default:
+		logger.Warningf("IP lookup failed - IP: %s - Error chain: %v", ip, result.Err)
+		return v1.LookupResult{Status: v1.BatchStatusError, Reason: result.Err.Error()}
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/repository"
+	"arena-backend-challenge/internal/service"
+)
+
+func TestLocationHandler_GetLocations(t *testing.T) {
+	mockRepo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			switch ipID {
+			case 134744072: // 8.8.8.8
+				return &domain.Location{LowerIPID: ipID, UpperIPID: ipID, CountryCode: "US", City: "Mountain View"}, nil
+			case 16843009: // 1.1.1.1
+				return nil, errors.New("disk on fire")
+			}
+			return nil, domain.ErrLocationNotFound
+		},
+	}
+	handler := NewLocationHandler(service.NewLocationService(mockRepo))
+
+	body := `["8.8.8.8", "10.0.0.1", "bogus", "1.1.1.1", "8.8.8.8"]`
+	req := httptest.NewRequest(http.MethodPost, "/ip/locations", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.GetLocations(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetLocations() status = %v, want %v: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var response v1.BatchLocationResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	want := []struct{ ip, status string }{
+		{"8.8.8.8", v1.BatchStatusFound},
+		{"10.0.0.1", v1.BatchStatusNotFound},
+		{"bogus", v1.BatchStatusInvalid},
+		{"1.1.1.1", v1.BatchStatusError},
+		{"8.8.8.8", v1.BatchStatusFound},
+	}
+	if len(response.Results) != len(want) {
+		t.Fatalf("GetLocations() results = %+v, want %d", response.Results, len(want))
+	}
+	for i, result := range response.Results {
+		if result.IP != want[i].ip || result.Status != want[i].status {
+			t.Errorf("result %d = %s %s, want %s %s", i, result.IP, result.Status, want[i].ip, want[i].status)
+		}
+		if (result.Location != nil) != (want[i].status == v1.BatchStatusFound) {
+			t.Errorf("result %d location = %+v", i, result.Location)
+		}
+		hasReason := want[i].status == v1.BatchStatusInvalid || want[i].status == v1.BatchStatusError
+		if (result.Reason != "") != hasReason {
+			t.Errorf("result %d reason = %q", i, result.Reason)
+		}
+	}
+	if got := response.Results[0].Location; got != nil && got.City != "Mountain View" {
+		t.Errorf("result 0 city = %q, want Mountain View", got.City)
+	}
+}
+
+func TestLocationHandler_GetLocations_Errors(t *testing.T) {
+	handler := NewLocationHandler(service.NewLocationService(&repository.MockRepository{}), WithBatchLimits(2, 64))
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"GET", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"not JSON", http.MethodPost, "8.8.8.8", http.StatusBadRequest},
+		{"not an array of strings", http.MethodPost, `{"ips": ["8.8.8.8"]}`, http.StatusBadRequest},
+		{"too many addresses", http.MethodPost, `["1.1.1.1", "2.2.2.2", "3.3.3.3"]`, http.StatusRequestEntityTooLarge},
+		{"body too large", http.MethodPost, `["` + strings.Repeat("1", 100) + `"]`, http.StatusRequestEntityTooLarge},
+		{"empty batch", http.MethodPost, `[]`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/ip/locations", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.GetLocations(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("GetLocations() status = %v, want %v: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"arena-backend-challenge/internal/blocklist"
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/pkg/logger"
+)
+
+type BlocklistHandler struct {
+	lister   domain.RangeLister
+	provider domain.DatasetInfoProvider
+}
+
+// NewBlocklistHandler creates the blocklist export API. A nil lister, for
+// data sources that cannot list ranges, answers 501.
+func NewBlocklistHandler(lister domain.RangeLister, provider domain.DatasetInfoProvider) *BlocklistHandler {
+	return &BlocklistHandler{
+		lister:   lister,
+		provider: provider,
+	}
+}
+
+// GetBlocklist godoc
+// @Summary Export a country blocklist
+// @Description Renders every range of the given countries, aggregated into the fewest CIDR blocks, as firewall or proxy configuration: an ipset restore script, an nftables table of interval sets, an nginx geo block, a HAProxy ACL file or an Apache RequireAll block.
+// @Tags Location
+// @Produce plain
+// @Param countries query string true "Comma-separated ISO 3166 country codes (e.g., CN,RU)"
+// @Param format query string true "Output format" Enums(ipset, nftables, nginx, haproxy, apache)
+// @Param version query int false "IP version, 4 or 6; both when omitted"
+// @Param name query string false "Name of the generated sets, variable or ACL" default(geoblock)
+// @Success 200 {string} string "Configuration snippet"
+// @Failure 400 {object} v1.ErrorResponse "Invalid query parameter or unknown country"
+// @Failure 405 {object} v1.ErrorResponse "Method not allowed"
+// @Failure 501 {object} v1.ErrorResponse "The configured data source does not support reverse lookups"
+// @Router /blocklist [get]
+func (h *BlocklistHandler) GetBlocklist(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.lister == nil {
+		sendError(w, "Reverse lookup is not supported by the configured data source", http.StatusNotImplemented)
+		return
+	}
+
+	params := r.URL.Query()
+	countries, err := blocklist.ParseCountries(params.Get("countries"))
+	if err != nil {
+		sendError(w, "countries: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := blocklist.Format(params.Get("format"))
+	if !slices.Contains(blocklist.Formats(), format) {
+		sendError(w, fmt.Sprintf("format must be one of %v", blocklist.Formats()), http.StatusBadRequest)
+		return
+	}
+	version, err := intParam(params.Get("version"), 0)
+	if err != nil || (version != 0 && version != 4 && version != 6) {
+		sendError(w, "version must be 4 or 6", http.StatusBadRequest)
+		return
+	}
+
+	prefixes, err := blocklist.Collect(h.lister, countries, version)
+	if err != nil {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Render before writing the header, so a bad name is still a 400.
+	var buf bytes.Buffer
+	options := blocklist.Options{
+		Name:    params.Get("name"),
+		Comment: blocklist.Comment(countries, h.provider.DatasetInfo()),
+	}
+	if err := blocklist.Write(&buf, format, prefixes, options); err != nil {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		logger.Errorf("Error writing blocklist response: %v", err)
+		return
+	}
+
+	logger.Infof("Blocklist exported - Countries: %s - Format: %s - Prefixes: %d - Duration: %v",
+		strings.Join(countries, ","), format, len(prefixes), time.Since(start))
+}
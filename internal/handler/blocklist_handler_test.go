@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+func TestBlocklistHandler_GetBlocklist(t *testing.T) {
+	lister := &mockRangeLister{page: domain.RangePage{
+		Total: 2,
+		Ranges: []domain.IPRange{
+			{First: netip.MustParseAddr("10.0.0.0"), Last: netip.MustParseAddr("10.0.0.255")},
+			{First: netip.MustParseAddr("10.0.1.0"), Last: netip.MustParseAddr("10.0.1.255")},
+		},
+	}}
+	provider := &mockDatasetInfoProvider{info: domain.DatasetInfo{Path: "data/db11.csv", Version: "2024-10"}}
+	handler := NewBlocklistHandler(lister, provider)
+
+	req := httptest.NewRequest(http.MethodGet, "/blocklist?countries=cn&format=nginx&version=4&name=blocked", nil)
+	w := httptest.NewRecorder()
+	handler.GetBlocklist(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetBlocklist() status = %v, want %v: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("GetBlocklist() Content-Type = %q", got)
+	}
+	if want := (domain.RangeQuery{CountryCode: "CN", IPVersion: 4}); lister.query != want {
+		t.Errorf("ListRanges() query = %+v, want %+v", lister.query, want)
+	}
+
+	want := `# Blocklist for CN
+# Generated from db11.csv, version 2024-10
+# Include in the http block, then: if ($blocked) { return 403; }
+geo $blocked {
+    default 0;
+    10.0.0.0/23 1;
+}
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("GetBlocklist() body =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestBlocklistHandler_GetBlocklist_Errors(t *testing.T) {
+	found := &mockRangeLister{page: domain.RangePage{
+		Total:  1,
+		Ranges: []domain.IPRange{{First: netip.MustParseAddr("10.0.0.0"), Last: netip.MustParseAddr("10.0.0.255")}},
+	}}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		lister     domain.RangeLister
+		wantStatus int
+	}{
+		{"missing countries", http.MethodGet, "/blocklist?format=nginx", found, http.StatusBadRequest},
+		{"bad country", http.MethodGet, "/blocklist?countries=CHN&format=nginx", found, http.StatusBadRequest},
+		{"unknown country", http.MethodGet, "/blocklist?countries=ZZ&format=nginx", &mockRangeLister{}, http.StatusBadRequest},
+		{"missing format", http.MethodGet, "/blocklist?countries=CN", found, http.StatusBadRequest},
+		{"bad version", http.MethodGet, "/blocklist?countries=CN&format=nginx&version=5", found, http.StatusBadRequest},
+		{"bad name", http.MethodGet, "/blocklist?countries=CN&format=nginx&name=a-b", found, http.StatusBadRequest},
+		{"POST", http.MethodPost, "/blocklist?countries=CN&format=nginx", found, http.StatusMethodNotAllowed},
+		{"unsupported data source", http.MethodGet, "/blocklist?countries=CN&format=nginx", nil, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewBlocklistHandler(tt.lister, &mockDatasetInfoProvider{})
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			w := httptest.NewRecorder()
+			handler.GetBlocklist(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("GetBlocklist() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
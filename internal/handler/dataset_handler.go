@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/internal/domain"
+)
+
+type DatasetHandler struct {
+	provider domain.DatasetInfoProvider
+}
+
+func NewDatasetHandler(provider domain.DatasetInfoProvider) *DatasetHandler {
+	return &DatasetHandler{
+		provider: provider,
+	}
+}
+
+// GetDataset godoc
+// @Summary Get dataset metadata
+// @Description Describes the dataset currently served: source file, SHA-256, vendor version, row counts and load time. Use it to confirm a rollout picked up a new release.
+// @Tags Dataset
+// @Produce json
+// @Success 200 {object} v1.DatasetResponse "Dataset metadata"
+// @Failure 405 {object} v1.ErrorResponse "Method not allowed"
+// @Router /dataset [get]
+func (h *DatasetHandler) GetDataset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sendJSON(w, NewDatasetResponse(h.provider.DatasetInfo()), http.StatusOK)
+}
+
+// NewDatasetResponse maps dataset metadata onto its API representation.
+func NewDatasetResponse(info domain.DatasetInfo) v1.DatasetResponse {
+	return v1.DatasetResponse{
+		Path:        info.Path,
+		SHA256:      info.SHA256,
+		Version:     info.Version,
+		Rows:        info.Rows,
+		SkippedRows: info.SkippedRows,
+		LoadedAt:    info.LoadedAt,
+	}
+}
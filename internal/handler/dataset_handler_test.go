@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/internal/domain"
+)
+
+type mockDatasetInfoProvider struct {
+	info domain.DatasetInfo
+}
+
+func (m *mockDatasetInfoProvider) DatasetInfo() domain.DatasetInfo {
+	return m.info
+}
+
+func TestDatasetHandler_GetDataset(t *testing.T) {
+	loadedAt := time.Date(2024, 10, 2, 3, 4, 5, 0, time.UTC)
+	handler := NewDatasetHandler(&mockDatasetInfoProvider{info: domain.DatasetInfo{
+		Path:        "data/IP2LOCATION-LITE-DB11-202410.CSV",
+		SHA256:      "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Version:     "2024-10",
+		Rows:        2900000,
+		SkippedRows: 3,
+		LoadedAt:    loadedAt,
+	}})
+
+	req := httptest.NewRequest(http.MethodGet, "/dataset", nil)
+	w := httptest.NewRecorder()
+	handler.GetDataset(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetDataset() status = %v, want %v", w.Code, http.StatusOK)
+	}
+
+	var got v1.DatasetResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode dataset response: %v", err)
+	}
+	want := v1.DatasetResponse{
+		Path:        "data/IP2LOCATION-LITE-DB11-202410.CSV",
+		SHA256:      "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Version:     "2024-10",
+		Rows:        2900000,
+		SkippedRows: 3,
+		LoadedAt:    loadedAt,
+	}
+	if got != want {
+		t.Errorf("GetDataset() = %+v, want %+v", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/dataset", nil)
+	w = httptest.NewRecorder()
+	handler.GetDataset(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GetDataset() with POST status = %v, want %v", w.Code, http.StatusMethodNotAllowed)
+	}
+}
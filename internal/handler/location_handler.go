@@ -1,44 +1,81 @@
 package handler
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
 	"time"
 
 	v1 "arena-backend-challenge/api/v1"
 	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/middleware"
 	"arena-backend-challenge/internal/service"
 	"arena-backend-challenge/pkg/logger"
 )
 
+// Default limits of a batch lookup request.
+const (
+	DefaultBatchMaxSize      = 1000
+	DefaultBatchMaxBodyBytes = 1 << 20
+)
+
 type LocationHandler struct {
-	service *service.LocationService
+	service           *service.LocationService
+	batchMaxSize      int
+	batchMaxBodyBytes int64
 }
 
-func NewLocationHandler(service *service.LocationService) *LocationHandler {
-	return &LocationHandler{
-		service: service,
+// LocationHandlerOption customises a LocationHandler.
+type LocationHandlerOption func(*LocationHandler)
+
+// WithBatchLimits caps the number of addresses and the body size, in bytes,
+// of a batch lookup request.
+func WithBatchLimits(maxSize int, maxBodyBytes int64) LocationHandlerOption {
+	return func(h *LocationHandler) {
+		h.batchMaxSize = maxSize
+		h.batchMaxBodyBytes = maxBodyBytes
+	}
+}
+
+func NewLocationHandler(service *service.LocationService, opts ...LocationHandlerOption) *LocationHandler {
+	h := &LocationHandler{
+		service:           service,
+		batchMaxSize:      DefaultBatchMaxSize,
+		batchMaxBodyBytes: DefaultBatchMaxBodyBytes,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // GetLocation godoc
 // @Summary Get IP location
-// @Description Get geographic location information for a given IP address
+// @Description Get geographic location information for a given IP address. With cidr instead of ip, returns a v1.NetworkResponse: every range overlapping the block (at most 1000 listed), each with its location and the number of block addresses it covers, and the share of the block per country. Blocks must be /8 or longer for IPv4 and /16 or longer for IPv6.
 // @Tags Location
 // @Accept json
 // @Produce json
-// @Param ip query string true "IPv4 address (e.g., 8.8.8.8)"
+// @Param ip query string false "IPv4 or IPv6 address (e.g., 8.8.8.8 or 2001:db8::1)"
+// @Param cidr query string false "Network block, instead of ip (e.g., 1.0.0.0/16)"
 // @Success 200 {object} v1.LocationResponse "Location found"
-// @Failure 400 {object} v1.ErrorResponse "Invalid IP address format"
+// @Failure 400 {object} v1.ErrorResponse "Invalid IP address or CIDR format"
 // @Failure 404 {object} v1.ErrorResponse "Location not found for the given IP"
+// @Failure 500 {object} v1.ErrorResponse "The dataset or an enrichment repository failed"
+// @Failure 501 {object} v1.ErrorResponse "CIDR queries are not supported by the configured data source"
 // @Router /ip/location [get]
 func (h *LocationHandler) GetLocation(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
 	ip := r.URL.Query().Get("ip")
+	if cidr := r.URL.Query().Get("cidr"); cidr != "" {
+		if ip != "" {
+			sendError(w, "Pass either ip or cidr, not both", http.StatusBadRequest)
+			return
+		}
+		h.getNetwork(w, cidr, start)
+		return
+	}
 	if ip == "" {
-		h.sendError(w, "IP address is required", http.StatusBadRequest)
+		sendError(w, "IP address is required", http.StatusBadRequest)
 		logger.Warningf("Bad request - missing IP parameter - Duration: %v", time.Since(start))
 		return
 	}
@@ -48,41 +85,141 @@ func (h *LocationHandler) GetLocation(w http.ResponseWriter, r *http.Request) {
 		duration := time.Since(start)
 
 		if errors.Is(err, domain.ErrLocationNotFound) {
-			h.sendError(w, "Location not found for the given IP", http.StatusNotFound)
+			sendError(w, "Location not found for the given IP", http.StatusNotFound)
 			logger.Infof("IP lookup not found - IP: %s - Status: 404 - Duration: %v - Error chain: %v",
 				ip, duration, err)
 			return
 		}
 
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		if errors.Is(err, domain.ErrInvalidIP) {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			logger.Warningf("IP lookup failed - IP: %s - Status: 400 - Duration: %v - Error chain: %v",
+				ip, duration, err)
+			return
+		}
 
-		logger.Warningf("IP lookup failed - IP: %s - Status: 400 - Duration: %v - Error chain: %v",
+		// The dataset or an enrichment repository failed, not the request.
+		sendError(w, "Internal server error", http.StatusInternalServerError)
+		logger.Errorf("IP lookup failed - IP: %s - Status: 500 - Duration: %v - Error chain: %v",
 			ip, duration, err)
 		return
 	}
 
-	response := v1.LocationResponse{
-		Country:     location.Country,
-		CountryCode: location.CountryCode,
-		City:        location.City,
-	}
-
-	h.sendJSON(w, response, http.StatusOK)
+	sendJSON(w, newLocationResponse(location), http.StatusOK)
 
 	duration := time.Since(start)
 	logger.Infof("IP lookup success - IP: %s - Country: %s - City: %s - Status: 200 - Duration: %v",
 		ip, location.Country, location.City, duration)
 }
 
-func (h *LocationHandler) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// GetMyLocation godoc
+// @Summary Get the caller's location
+// @Description Looks up the address of the client making the request. Behind proxies listed in TRUSTED_PROXIES, the client address is taken from the Forwarded, X-Forwarded-For or X-Real-IP header they set; otherwise it is the address the request came from. The result is never cached.
+// @Tags Location
+// @Produce json
+// @Success 200 {object} v1.BatchLocationResult "The client address, with its location when found"
+// @Failure 400 {object} v1.ErrorResponse "The client address could not be determined"
+// @Router /ip/me [get]
+func (h *LocationHandler) GetMyLocation(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	client, ok := middleware.ClientIP(r)
+	if !ok {
+		sendError(w, "Client address could not be determined", http.StatusBadRequest)
+		logger.Warningf("Client lookup failed - RemoteAddr: %s - Status: 400", r.RemoteAddr)
+		return
+	}
+
+	ip := client.String()
+	location, err := h.service.GetLocationByIP(ip)
+	result := NewLookupResult(ip, domain.LookupResult{Location: location, Err: err})
+
+	w.Header().Set("Cache-Control", "no-store")
+	sendJSON(w, v1.BatchLocationResult{IP: ip, LookupResult: result}, http.StatusOK)
+
+	logger.Infof("Client lookup - IP: %s - Status: %s - Duration: %v", ip, result.Status, time.Since(start))
+}
+
+// getNetwork answers a cidr query.
+func (h *LocationHandler) getNetwork(w http.ResponseWriter, cidr string, start time.Time) {
+	report, err := h.service.GetLocationsByCIDR(cidr)
+	if err != nil {
+		duration := time.Since(start)
+
+		if errors.Is(err, domain.ErrNetworkSearchUnsupported) {
+			sendError(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
 
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		logger.Errorf("Error encoding JSON response: %v", err)
+		sendError(w, err.Error(), http.StatusBadRequest)
+		logger.Warningf("CIDR lookup failed - CIDR: %s - Status: 400 - Duration: %v - Error chain: %v",
+			cidr, duration, err)
+		return
+	}
+
+	response := v1.NetworkResponse{
+		CIDR:        report.Prefix.String(),
+		Addresses:   report.Size,
+		Covered:     report.Covered,
+		TotalRanges: report.TotalRanges,
+		Truncated:   len(report.Ranges) < report.TotalRanges,
+		Ranges:      make([]v1.NetworkRangeResponse, 0, len(report.Ranges)),
+		Countries:   make([]v1.CountryShareResponse, 0, len(report.Countries)),
+	}
+	for _, rng := range report.Ranges {
+		response.Ranges = append(response.Ranges, v1.NetworkRangeResponse{
+			Start:     rng.First.String(),
+			End:       rng.Last.String(),
+			Addresses: rng.Addresses,
+			Location:  newLocationResponse(rng.Location),
+		})
 	}
+	for _, share := range report.Countries {
+		response.Countries = append(response.Countries, v1.CountryShareResponse{
+			CountryCode: share.CountryCode,
+			Country:     share.Country,
+			Addresses:   share.Addresses,
+			Share:       share.Share,
+		})
+	}
+
+	sendJSON(w, response, http.StatusOK)
+
+	logger.Infof("CIDR lookup success - CIDR: %s - Ranges: %d - Countries: %d - Status: 200 - Duration: %v",
+		report.Prefix, report.TotalRanges, len(report.Countries), time.Since(start))
 }
 
-func (h *LocationHandler) sendError(w http.ResponseWriter, message string, statusCode int) {
-	h.sendJSON(w, v1.ErrorResponse{Error: message}, statusCode)
+// newLocationResponse maps a location onto its API representation.
+func newLocationResponse(location *domain.Location) v1.LocationResponse {
+	response := v1.LocationResponse{
+		Country:     location.Country,
+		CountryCode: location.CountryCode,
+		Region:      location.Region,
+		City:        location.City,
+		Latitude:    location.Latitude,
+		Longitude:   location.Longitude,
+		ZipCode:     location.ZipCode,
+		TimeZone:    location.TimeZone,
+		Source:      location.Source,
+	}
+	if location.ASN != nil {
+		response.ASN = &v1.ASNResponse{
+			Number:  location.ASN.Number,
+			Name:    location.ASN.Name,
+			Network: location.ASN.Network,
+		}
+	}
+	if location.Threat != nil {
+		response.Threat = &v1.ThreatResponse{
+			IsProxy:   location.Threat.IsProxy,
+			IsVPN:     location.Threat.IsVPN,
+			IsTor:     location.Threat.IsTor,
+			IsHosting: location.Threat.IsHosting,
+			ProxyType: location.Threat.ProxyType,
+			Provider:  location.Threat.Provider,
+			UsageType: location.Threat.UsageType,
+		}
+	}
+
+	return response
 }
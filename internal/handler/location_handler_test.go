@@ -2,12 +2,16 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"reflect"
 	"testing"
 
 	v1 "arena-backend-challenge/api/v1"
 	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/middleware"
 	"arena-backend-challenge/internal/repository"
 	"arena-backend-challenge/internal/service"
 )
@@ -78,6 +82,28 @@ func TestLocationHandler_GetLocation(t *testing.T) {
 			wantCountry: "United States",
 			wantCity:    "Mountain View",
 		},
+		{
+			name:       "IPv4-mapped IPv6",
+			queryParam: "ip=::ffff:8.8.8.8",
+			mockFunc: func(ipID uint32) (*domain.Location, error) {
+				return &domain.Location{
+					Country:     "United States",
+					CountryCode: "US",
+					City:        "Mountain View",
+				}, nil
+			},
+			wantStatus:  http.StatusOK,
+			wantCountry: "United States",
+			wantCity:    "Mountain View",
+		},
+		{
+			name:           "IPv6 not in dataset",
+			queryParam:     "ip=2001:db8::1",
+			mockFunc:       nil,
+			wantStatus:     http.StatusNotFound,
+			wantError:      "Location not found for the given IP",
+			checkErrorOnly: true,
+		},
 		{
 			name:       "IP out of range",
 			queryParam: "ip=256.256.256.256",
@@ -87,6 +113,16 @@ func TestLocationHandler_GetLocation(t *testing.T) {
 			wantStatus:     http.StatusBadRequest,
 			checkErrorOnly: true,
 		},
+		{
+			name:       "repository failure",
+			queryParam: "ip=8.8.8.8",
+			mockFunc: func(ipID uint32) (*domain.Location, error) {
+				return nil, errors.New("dataset unavailable")
+			},
+			wantStatus:     http.StatusInternalServerError,
+			wantError:      "Internal server error",
+			checkErrorOnly: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -150,6 +186,95 @@ func TestLocationHandler_GetLocation(t *testing.T) {
 	}
 }
 
+// A failing enrichment repository is a server error, not a bad request.
+func TestLocationHandler_GetLocation_EnrichmentFailure(t *testing.T) {
+	mockRepo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			return &domain.Location{Country: "United States", CountryCode: "US"}, nil
+		},
+	}
+	failing := errors.New("table unavailable")
+
+	tests := []struct {
+		name string
+		opt  service.Option
+	}{
+		{name: "ASN", opt: service.WithASN(&repository.MockASNRepository{
+			FindASNByIPIDFunc: func(ipID uint32) (*domain.ASN, error) { return nil, failing },
+		})},
+		{name: "threat", opt: service.WithThreat(&repository.MockThreatRepository{
+			FindThreatByIPIDFunc: func(ipID uint32) (*domain.Threat, error) { return nil, failing },
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLocationHandler(service.NewLocationService(mockRepo, tt.opt))
+
+			req := httptest.NewRequest(http.MethodGet, "/ip/location?ip=8.8.8.8", nil)
+			w := httptest.NewRecorder()
+			handler.GetLocation(w, req)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("GetLocation() status = %v, want %v", w.Code, http.StatusInternalServerError)
+			}
+		})
+	}
+}
+
+func TestLocationHandler_GetLocation_AllFields(t *testing.T) {
+	mockRepo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			return &domain.Location{
+				Country:     "United States",
+				CountryCode: "US",
+				Region:      "California",
+				City:        "Mountain View",
+				Latitude:    37.405992,
+				Longitude:   -122.078515,
+				ZipCode:     "94035",
+				TimeZone:    "-07:00",
+				Source:      "corp.yaml",
+				ASN:         &domain.ASN{Number: 15169, Name: "Google LLC", Network: "8.8.8.0/24"},
+				Threat:      &domain.Threat{IsProxy: true, IsHosting: true, ProxyType: "DCH", UsageType: "DCH"},
+			}, nil
+		},
+	}
+
+	handler := NewLocationHandler(service.NewLocationService(mockRepo))
+
+	req := httptest.NewRequest(http.MethodGet, "/ip/location?ip=8.8.8.8", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetLocation(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetLocation() status = %v, want %v", w.Code, http.StatusOK)
+	}
+
+	var got v1.LocationResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode location response: %v", err)
+	}
+
+	want := v1.LocationResponse{
+		Country:     "United States",
+		CountryCode: "US",
+		Region:      "California",
+		City:        "Mountain View",
+		Latitude:    37.405992,
+		Longitude:   -122.078515,
+		ZipCode:     "94035",
+		TimeZone:    "-07:00",
+		Source:      "corp.yaml",
+		ASN:         &v1.ASNResponse{Number: 15169, Name: "Google LLC", Network: "8.8.8.0/24"},
+		Threat:      &v1.ThreatResponse{IsProxy: true, IsHosting: true, ProxyType: "DCH", UsageType: "DCH"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetLocation() = %+v, want %+v", got, want)
+	}
+}
+
 func TestLocationHandler_GetLocation_Methods(t *testing.T) {
 	mockRepo := &repository.MockRepository{
 		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
@@ -216,3 +341,106 @@ func BenchmarkLocationHandler_GetLocation(b *testing.B) {
 		handler.GetLocation(w, req)
 	}
 }
+
+func TestLocationHandler_GetLocation_CIDR(t *testing.T) {
+	mockRepo := &repository.MockNetworkRepository{Locations: []*domain.Location{
+		{LowerIPID: 16777216, UpperIPID: 16777471, Country: "United States", CountryCode: "US", City: "Los Angeles"},
+		{LowerIPID: 16777472, UpperIPID: 16778239, Country: "China", CountryCode: "CN", City: "Fuzhou"},
+	}}
+	handler := NewLocationHandler(service.NewLocationService(mockRepo))
+
+	req := httptest.NewRequest(http.MethodGet, "/ip/location?cidr=1.0.0.0/23", nil)
+	w := httptest.NewRecorder()
+	handler.GetLocation(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetLocation(cidr) status = %v, want %v: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	// Address counts are plain JSON integers.
+	want := `{"cidr":"1.0.0.0/23","addresses":512,"covered":512,"totalRanges":2,"truncated":false,` +
+		`"ranges":[{"start":"1.0.0.0","end":"1.0.0.255","addresses":256,"location":{"country":"United States","countryCode":"US","region":"","city":"Los Angeles","latitude":0,"longitude":0,"zipCode":"","timeZone":""}},` +
+		`{"start":"1.0.1.0","end":"1.0.3.255","addresses":256,"location":{"country":"China","countryCode":"CN","region":"","city":"Fuzhou","latitude":0,"longitude":0,"zipCode":"","timeZone":""}}],` +
+		`"countries":[{"countryCode":"CN","country":"China","addresses":256,"share":0.5},{"countryCode":"US","country":"United States","addresses":256,"share":0.5}]}` + "\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("GetLocation(cidr) body =\n%s\nwant\n%s", got, want)
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		repo       domain.Repository
+		wantStatus int
+	}{
+		{"ip and cidr", "/ip/location?ip=1.0.0.1&cidr=1.0.0.0/24", mockRepo, http.StatusBadRequest},
+		{"invalid cidr", "/ip/location?cidr=1.0.0.0/40", mockRepo, http.StatusBadRequest},
+		{"block too large", "/ip/location?cidr=0.0.0.0/0", mockRepo, http.StatusBadRequest},
+		{"unsupported data source", "/ip/location?cidr=1.0.0.0/24", &repository.MockRepository{}, http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLocationHandler(service.NewLocationService(tt.repo))
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			w := httptest.NewRecorder()
+			handler.GetLocation(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("GetLocation() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestLocationHandler_GetMyLocation(t *testing.T) {
+	mockRepo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			if ipID == 134744072 { // 8.8.8.8
+				return &domain.Location{Country: "United States", CountryCode: "US", City: "Mountain View"}, nil
+			}
+			return nil, domain.ErrLocationNotFound
+		},
+	}
+	handler := middleware.NewClientIPResolver([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}).
+		Middleware(http.HandlerFunc(NewLocationHandler(service.NewLocationService(mockRepo)).GetMyLocation))
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		wantIP       string
+		wantStatus   string
+		wantCountry  string
+	}{
+		{"behind a trusted proxy", "10.0.0.1:5000", "8.8.8.8", "8.8.8.8", v1.BatchStatusFound, "US"},
+		{"spoofed header", "192.0.2.1:5000", "8.8.8.8", "192.0.2.1", v1.BatchStatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ip/me", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("GetMyLocation() status = %v, want %v", w.Code, http.StatusOK)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("GetMyLocation() Cache-Control = %q, want no-store", got)
+			}
+
+			var response v1.BatchLocationResult
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.IP != tt.wantIP || response.Status != tt.wantStatus {
+				t.Errorf("GetMyLocation() = %s %s, want %s %s", response.IP, response.Status, tt.wantIP, tt.wantStatus)
+			}
+			if tt.wantCountry != "" && (response.Location == nil || response.Location.CountryCode != tt.wantCountry) {
+				t.Errorf("GetMyLocation() location = %+v, want %s", response.Location, tt.wantCountry)
+			}
+		})
+	}
+}
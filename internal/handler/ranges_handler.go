@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/pkg/iputil"
+	"arena-backend-challenge/pkg/logger"
+)
+
+const (
+	defaultRangesLimit = 100
+	maxRangesLimit     = 1000
+)
+
+type RangesHandler struct {
+	lister domain.RangeLister
+}
+
+// NewRangesHandler creates the reverse lookup API. A nil lister, for data
+// sources that cannot list ranges, answers 501.
+func NewRangesHandler(lister domain.RangeLister) *RangesHandler {
+	return &RangesHandler{
+		lister: lister,
+	}
+}
+
+// GetRanges godoc
+// @Summary List IP ranges of a country or city
+// @Description Reverse lookup: lists the dataset ranges assigned to a country, or to a city in it, in address order with IPv4 first. Each range comes with the minimal set of CIDR blocks covering it. Results are paginated with offset and limit.
+// @Tags Location
+// @Produce json
+// @Param country query string true "ISO 3166 country code (e.g., BR)"
+// @Param city query string false "City name, case-insensitive (e.g., Sao Paulo)"
+// @Param version query int false "IP version, 4 or 6; both when omitted"
+// @Param offset query int false "Ranges to skip" default(0)
+// @Param limit query int false "Maximum ranges returned (at most 1000)" default(100)
+// @Success 200 {object} v1.RangesResponse "Ranges found"
+// @Failure 400 {object} v1.ErrorResponse "Invalid query parameter"
+// @Failure 405 {object} v1.ErrorResponse "Method not allowed"
+// @Failure 501 {object} v1.ErrorResponse "The configured data source does not support reverse lookups"
+// @Router /ranges [get]
+func (h *RangesHandler) GetRanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.lister == nil {
+		sendError(w, "Reverse lookup is not supported by the configured data source", http.StatusNotImplemented)
+		return
+	}
+
+	query, err := parseRangeQuery(r)
+	if err != nil {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page := h.lister.ListRanges(query)
+
+	response := v1.RangesResponse{
+		CountryCode: strings.ToUpper(query.CountryCode),
+		City:        query.City,
+		Total:       page.Total,
+		Offset:      query.Offset,
+		Limit:       query.Limit,
+		Ranges:      make([]v1.RangeResponse, 0, len(page.Ranges)),
+	}
+	for _, rng := range page.Ranges {
+		item, err := NewRangeResponse(rng)
+		if err != nil {
+			logger.Errorf("Reverse lookup returned an invalid range - Country: %s - Error chain: %v", query.CountryCode, err)
+			sendError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		response.Ranges = append(response.Ranges, item)
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+func parseRangeQuery(r *http.Request) (domain.RangeQuery, error) {
+	params := r.URL.Query()
+
+	query := domain.RangeQuery{
+		CountryCode: params.Get("country"),
+		City:        strings.TrimSpace(params.Get("city")),
+		Limit:       defaultRangesLimit,
+	}
+	if query.CountryCode == "" {
+		return query, fmt.Errorf("country is required")
+	}
+	if len(query.CountryCode) != 2 {
+		return query, fmt.Errorf("country must be a two-letter code")
+	}
+
+	var err error
+	if query.IPVersion, err = intParam(params.Get("version"), 0); err != nil ||
+		(query.IPVersion != 0 && query.IPVersion != 4 && query.IPVersion != 6) {
+		return query, fmt.Errorf("version must be 4 or 6")
+	}
+	if query.Offset, err = intParam(params.Get("offset"), 0); err != nil || query.Offset < 0 {
+		return query, fmt.Errorf("offset must be a non-negative integer")
+	}
+	if query.Limit, err = intParam(params.Get("limit"), defaultRangesLimit); err != nil ||
+		query.Limit < 1 || query.Limit > maxRangesLimit {
+		return query, fmt.Errorf("limit must be between 1 and %d", maxRangesLimit)
+	}
+
+	return query, nil
+}
+
+// intParam parses an optional integer query parameter.
+func intParam(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// NewRangeResponse maps a dataset range onto its API representation.
+func NewRangeResponse(rng domain.IPRange) (v1.RangeResponse, error) {
+	prefixes, err := iputil.RangeToPrefixes(rng.First, rng.Last)
+	if err != nil {
+		return v1.RangeResponse{}, err
+	}
+
+	cidrs := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		cidrs[i] = prefix.String()
+	}
+
+	return v1.RangeResponse{
+		Start:  rng.First.String(),
+		End:    rng.Last.String(),
+		CIDRs:  cidrs,
+		Region: rng.Region,
+		City:   rng.City,
+	}, nil
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/repository"
+)
+
+type mockRangeLister struct {
+	page  domain.RangePage
+	query domain.RangeQuery
+}
+
+func (m *mockRangeLister) ListRanges(q domain.RangeQuery) domain.RangePage {
+	m.query = q
+	return m.page
+}
+
+func TestRangesHandler_GetRanges(t *testing.T) {
+	lister := &mockRangeLister{page: domain.RangePage{
+		Total: 7,
+		Ranges: []domain.IPRange{
+			{
+				First:  netip.MustParseAddr("1.0.0.0"),
+				Last:   netip.MustParseAddr("1.0.2.255"),
+				Region: "Sao Paulo",
+				City:   "Sao Paulo",
+			},
+			{
+				First:  netip.MustParseAddr("2001:db8::"),
+				Last:   netip.MustParseAddr("2001:db8::ffff"),
+				Region: "Sao Paulo",
+				City:   "Sao Paulo",
+			},
+		},
+	}}
+	handler := NewRangesHandler(lister)
+
+	req := httptest.NewRequest(http.MethodGet, "/ranges?country=br&city=Sao+Paulo&offset=5&limit=2", nil)
+	w := httptest.NewRecorder()
+	handler.GetRanges(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetRanges() status = %v, want %v: %s", w.Code, http.StatusOK, w.Body)
+	}
+	wantQuery := domain.RangeQuery{CountryCode: "br", City: "Sao Paulo", Offset: 5, Limit: 2}
+	if lister.query != wantQuery {
+		t.Errorf("ListRanges() query = %+v, want %+v", lister.query, wantQuery)
+	}
+
+	var got v1.RangesResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode ranges response: %v", err)
+	}
+	want := v1.RangesResponse{
+		CountryCode: "BR",
+		City:        "Sao Paulo",
+		Total:       7,
+		Offset:      5,
+		Limit:       2,
+		Ranges: []v1.RangeResponse{
+			{
+				Start:  "1.0.0.0",
+				End:    "1.0.2.255",
+				CIDRs:  []string{"1.0.0.0/23", "1.0.2.0/24"},
+				Region: "Sao Paulo",
+				City:   "Sao Paulo",
+			},
+			{
+				Start:  "2001:db8::",
+				End:    "2001:db8::ffff",
+				CIDRs:  []string{"2001:db8::/112"},
+				Region: "Sao Paulo",
+				City:   "Sao Paulo",
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRanges() = %+v, want %+v", got, want)
+	}
+}
+
+// An offset past the end gives an empty page, even one that would overflow
+// when added to where the country starts.
+func TestRangesHandler_GetRanges_HugeOffset(t *testing.T) {
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","BR","Brazil","Sao Paulo","Sao Paulo","-23.547500","-46.636110","01000-000","-03:00"
+"16777472","16777727","US","United States","California","Los Angeles","34.052230","-118.243680","90001","-07:00"`
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	repo, err := repository.NewMemoryRepository(path)
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+	handler := NewRangesHandler(repo)
+
+	req := httptest.NewRequest(http.MethodGet, "/ranges?country=US&offset=9223372036854775807", nil)
+	w := httptest.NewRecorder()
+	handler.GetRanges(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetRanges() status = %v, want %v: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var got v1.RangesResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode ranges response: %v", err)
+	}
+	if got.Total != 1 || len(got.Ranges) != 0 {
+		t.Errorf("GetRanges() total = %d with %d ranges, want 1 with none", got.Total, len(got.Ranges))
+	}
+}
+
+func TestRangesHandler_GetRanges_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		lister     domain.RangeLister
+		wantStatus int
+	}{
+		{"missing country", http.MethodGet, "/ranges", &mockRangeLister{}, http.StatusBadRequest},
+		{"long country", http.MethodGet, "/ranges?country=BRA", &mockRangeLister{}, http.StatusBadRequest},
+		{"bad version", http.MethodGet, "/ranges?country=BR&version=5", &mockRangeLister{}, http.StatusBadRequest},
+		{"negative offset", http.MethodGet, "/ranges?country=BR&offset=-1", &mockRangeLister{}, http.StatusBadRequest},
+		{"zero limit", http.MethodGet, "/ranges?country=BR&limit=0", &mockRangeLister{}, http.StatusBadRequest},
+		{"limit too large", http.MethodGet, "/ranges?country=BR&limit=1001", &mockRangeLister{}, http.StatusBadRequest},
+		{"non-numeric limit", http.MethodGet, "/ranges?country=BR&limit=ten", &mockRangeLister{}, http.StatusBadRequest},
+		{"POST", http.MethodPost, "/ranges?country=BR", &mockRangeLister{}, http.StatusMethodNotAllowed},
+		{"unsupported data source", http.MethodGet, "/ranges?country=BR", nil, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewRangesHandler(tt.lister)
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			w := httptest.NewRecorder()
+			handler.GetRanges(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("GetRanges() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
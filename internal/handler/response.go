@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/pkg/logger"
+)
+
+func sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.Errorf("Error encoding JSON response: %v", err)
+	}
+}
+
+func sendError(w http.ResponseWriter, message string, statusCode int) {
+	sendJSON(w, v1.ErrorResponse{Error: message}, statusCode)
+}
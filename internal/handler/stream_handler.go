@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	v1 "arena-backend-challenge/api/v1"
+	"arena-backend-challenge/pkg/logger"
+)
+
+// A stream is answered a chunk of lines at a time: the lines already
+// received, at most streamChunkLines, are looked up together. Lines longer
+// than maxStreamLineBytes are rejected, so memory use does not depend on the
+// size of the body.
+const (
+	streamChunkLines   = 64
+	maxStreamLineBytes = 64 << 10
+)
+
+var errLineTooLong = fmt.Errorf("line exceeds %d bytes", maxStreamLineBytes)
+
+// streamLine is one non-empty line of a stream.
+type streamLine struct {
+	number int
+	// object is the JSON object read, nil for a bare IP.
+	object []byte
+	ip     string
+	// err is set when the line could not be read; it is answered with an
+	// error line instead of a lookup.
+	err    error
+	result v1.LookupResult
+}
+
+// StreamLocations godoc
+// @Summary Enrich a stream of IPs
+// @Description Reads newline-delimited JSON from the body and streams NDJSON back as lines arrive. A line holding a JSON object gets a member, named by into, with the lookup of the address in its field member; every other member is passed through as is. A line holding a bare IP, as text or a JSON string, is answered with an object like the results of POST /ip/locations. Blank lines are skipped and a line that cannot be read is answered with an error object naming its line number. Lines are limited to 64 KiB; the body is not.
+// @Tags Location
+// @Accept plain
+// @Produce json
+// @Param field query string false "Member of each object holding the IP" default(ip)
+// @Param into query string false "Member added to each object with the lookup" default(geo)
+// @Param body body string true "Newline-delimited JSON objects or IPs"
+// @Success 200 {object} v1.LookupResult "One line per input line, as NDJSON"
+// @Failure 400 {object} v1.ErrorResponse "Invalid query parameter"
+// @Failure 405 {object} v1.ErrorResponse "Method not allowed"
+// @Router /ip/locations/stream [post]
+func (h *LocationHandler) StreamLocations(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		field = "ip"
+	}
+	into := r.URL.Query().Get("into")
+	if into == "" {
+		into = "geo"
+	}
+	if field == into {
+		sendError(w, "field and into must name different members", http.StatusBadRequest)
+		return
+	}
+	intoKey, _ := json.Marshal(into)
+
+	// Answers are written while the body is still being read.
+	controller := http.NewResponseController(w)
+	if err := controller.EnableFullDuplex(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.Warningf("Stream lookup cannot enable full duplex: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	reader := bufio.NewReader(r.Body)
+	writer := bufio.NewWriter(w)
+	chunk := make([]streamLine, 0, streamChunkLines)
+	ips := make([]string, 0, streamChunkLines)
+	var lines, found int
+	var readErr error
+
+	for number := 1; readErr == nil; {
+		chunk = chunk[:0]
+		for len(chunk) < streamChunkLines && readErr == nil {
+			var raw []byte
+			raw, readErr = readLine(reader)
+			switch {
+			case errors.Is(readErr, errLineTooLong):
+				chunk = append(chunk, streamLine{number: number, err: readErr})
+				readErr = nil
+			case readErr != nil && !errors.Is(readErr, io.EOF):
+				// A partial line is dropped.
+			default:
+				if line, ok := parseStreamLine(raw, field, into); ok {
+					line.number = number
+					chunk = append(chunk, line)
+				}
+			}
+			number++
+			// Answer what has arrived before waiting for more.
+			if reader.Buffered() == 0 {
+				break
+			}
+		}
+
+		ips = ips[:0]
+		for i := range chunk {
+			if chunk[i].err == nil && chunk[i].result.Status == "" {
+				ips = append(ips, chunk[i].ip)
+			}
+		}
+		results := h.service.GetLocationsByIPs(ips)
+		for i := range chunk {
+			line := &chunk[i]
+			if line.err == nil && line.result.Status == "" {
+				line.result = NewLookupResult(line.ip, results[0])
+				results = results[1:]
+			}
+			if line.result.Status == v1.BatchStatusFound {
+				found++
+			}
+			if err := writeStreamLine(writer, line, intoKey); err != nil {
+				logger.Warningf("Stream lookup aborted writing line %d: %v", line.number, err)
+				return
+			}
+		}
+		lines += len(chunk)
+
+		if reader.Buffered() == 0 || readErr != nil {
+			if err := writer.Flush(); err != nil {
+				logger.Warningf("Stream lookup aborted: %v", err)
+				return
+			}
+			if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				logger.Warningf("Stream lookup aborted: %v", err)
+				return
+			}
+		}
+	}
+
+	if !errors.Is(readErr, io.EOF) {
+		logger.Warningf("Stream lookup stopped reading the body after %d lines: %v", lines, readErr)
+		return
+	}
+
+	logger.Infof("Stream lookup - Lines: %d - Found: %d - Duration: %v", lines, found, time.Since(start))
+}
+
+// readLine returns the next line of r without its line ending, and io.EOF
+// with the last one. A line longer than maxStreamLineBytes is skipped and
+// reported with errLineTooLong.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		fragment, err := r.ReadSlice('\n')
+		if !tooLong && len(line)+len(fragment) > maxStreamLineBytes {
+			tooLong, line = true, nil
+		}
+		if !tooLong {
+			line = append(line, fragment...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if tooLong {
+			if err == nil || errors.Is(err, io.EOF) {
+				err = errLineTooLong
+			}
+			return nil, err
+		}
+		return line, err
+	}
+}
+
+// parseStreamLine reads the object or bare IP of a line. It reports false
+// for a blank line. A line without a usable address is answered without a
+// lookup, through err or result.
+func parseStreamLine(raw []byte, field, into string) (streamLine, bool) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0:
+		return streamLine{}, false
+	case raw[0] == '{':
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &members); err != nil {
+			return streamLine{err: fmt.Errorf("invalid JSON: %w", err)}, true
+		}
+		if _, ok := members[into]; ok {
+			return streamLine{err: fmt.Errorf("object already has a %q member", into)}, true
+		}
+		line := streamLine{object: raw}
+		value, ok := members[field]
+		if !ok {
+			line.result = v1.LookupResult{Status: v1.BatchStatusInvalid, Reason: fmt.Sprintf("missing %q member", field)}
+		} else if err := json.Unmarshal(value, &line.ip); err != nil {
+			line.result = v1.LookupResult{Status: v1.BatchStatusInvalid, Reason: fmt.Sprintf("%q member is not a string", field)}
+		}
+		return line, true
+	case raw[0] == '"':
+		var line streamLine
+		if err := json.Unmarshal(raw, &line.ip); err != nil {
+			return streamLine{err: fmt.Errorf("invalid JSON: %w", err)}, true
+		}
+		return line, true
+	default:
+		return streamLine{ip: string(raw)}, true
+	}
+}
+
+// writeStreamLine writes the answer to line: the object with the lookup
+// added as its last member, a BatchLocationResult for a bare IP, or an
+// ErrorResponse.
+func writeStreamLine(w *bufio.Writer, line *streamLine, intoKey []byte) error {
+	var answer []byte
+	var err error
+	switch {
+	case line.err != nil:
+		answer, err = json.Marshal(v1.ErrorResponse{Error: fmt.Sprintf("line %d: %v", line.number, line.err)})
+	case line.object == nil:
+		answer, err = json.Marshal(v1.BatchLocationResult{IP: line.ip, LookupResult: line.result})
+	default:
+		var result []byte
+		if result, err = json.Marshal(line.result); err != nil {
+			break
+		}
+		// Splice the member in before the closing brace, so the other
+		// members keep their order and bytes.
+		body := bytes.TrimSpace(line.object[1 : len(line.object)-1])
+		answer = append(answer, '{')
+		if len(body) > 0 {
+			answer = append(append(answer, body...), ',')
+		}
+		answer = append(append(append(answer, intoKey...), ':'), result...)
+		answer = append(answer, '}')
+	}
+	if err != nil {
+		return err
+	}
+
+	answer = append(answer, '\n')
+	_, err = w.Write(answer)
+	return err
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/repository"
+	"arena-backend-challenge/internal/service"
+)
+
+func newStreamTestHandler() *LocationHandler {
+	mockRepo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			if ipID == 134744072 { // 8.8.8.8
+				return &domain.Location{LowerIPID: ipID, UpperIPID: ipID, CountryCode: "US"}, nil
+			}
+			return nil, domain.ErrLocationNotFound
+		},
+	}
+	return NewLocationHandler(service.NewLocationService(mockRepo))
+}
+
+func TestLocationHandler_StreamLocations(t *testing.T) {
+	found := `{"status":"found","location":{"country":"","countryCode":"US","region":"","city":"","latitude":0,"longitude":0,"zipCode":"","timeZone":""}}`
+
+	tests := []struct {
+		name  string
+		query string
+		body  string
+		want  []string
+	}{
+		{
+			name: "objects keep their members",
+			body: "{\"id\": 7, \"ip\": \"8.8.8.8\", \"tags\": [\"a\"]}\n{\"ip\":\"10.0.0.1\"}\n",
+			want: []string{
+				`{"id": 7, "ip": "8.8.8.8", "tags": ["a"],"geo":` + found + `}`,
+				`{"ip":"10.0.0.1","geo":{"status":"not_found"}}`,
+			},
+		},
+		{
+			name:  "custom field and member",
+			query: "?field=addr&into=where",
+			body:  `{"addr":"8.8.8.8"}`,
+			want:  []string{`{"addr":"8.8.8.8","where":` + found + `}`},
+		},
+		{
+			name: "bare IPs and blank lines",
+			body: "8.8.8.8\r\n\n\"10.0.0.1\"\nbogus",
+			want: []string{
+				`{"ip":"8.8.8.8",` + found[1:],
+				`{"ip":"10.0.0.1","status":"not_found"}`,
+				`{"ip":"bogus","status":"invalid","reason":"invalid IP address: invalid IP format 'bogus': expected 4 octets, got 1"}`,
+			},
+		},
+		{
+			name: "unusable lines",
+			body: "{\"ip\": 1}\n{}\n{\"ip\":\n{\"geo\":1}\n" + strings.Repeat("1", maxStreamLineBytes+1) + "\n8.8.8.8\n",
+			want: []string{
+				`{"ip": 1,"geo":{"status":"invalid","reason":"\"ip\" member is not a string"}}`,
+				`{"geo":{"status":"invalid","reason":"missing \"ip\" member"}}`,
+				`{"error":"line 3: invalid JSON: unexpected end of JSON input"}`,
+				`{"error":"line 4: object already has a \"geo\" member"}`,
+				`{"error":"line 5: line exceeds 65536 bytes"}`,
+				`{"ip":"8.8.8.8",` + found[1:],
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/ip/locations/stream"+tt.query, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			newStreamTestHandler().StreamLocations(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("StreamLocations() status = %v, want %v: %s", w.Code, http.StatusOK, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+				t.Errorf("StreamLocations() Content-Type = %q", got)
+			}
+			got := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+			if len(got) != len(tt.want) {
+				t.Fatalf("StreamLocations() lines =\n%s\nwant %d", w.Body, len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("line %d =\n%s\nwant\n%s", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLocationHandler_StreamLocations_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		wantStatus int
+	}{
+		{"GET", http.MethodGet, "/ip/locations/stream", http.StatusMethodNotAllowed},
+		{"same field and member", http.MethodPost, "/ip/locations/stream?field=ip&into=ip", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader("8.8.8.8\n"))
+			w := httptest.NewRecorder()
+			newStreamTestHandler().StreamLocations(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("StreamLocations() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// The answer to a line arrives while the request body is still open.
+func TestLocationHandler_StreamLocations_FullDuplex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(newStreamTestHandler().StreamLocations))
+	defer server.Close()
+
+	bodyReader, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	req, err := http.NewRequest(http.MethodPost, server.URL, bodyReader)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	go func() {
+		_, _ = io.WriteString(bodyWriter, "10.0.0.1\n")
+	}()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading the first answer: %v", err)
+	}
+	if want := `{"ip":"10.0.0.1","status":"not_found"}` + "\n"; line != want {
+		t.Errorf("first answer = %q, want %q", line, want)
+	}
+}
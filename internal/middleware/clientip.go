@@ -0,0 +1,155 @@
+// Package middleware holds HTTP middleware shared by every route.
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// ClientIPResolver works out the address of the client behind a request.
+// Forwarding headers are only believed when the request comes from one of
+// the trusted proxies, and only as far back as the chain of trusted proxies
+// goes: a client cannot pick its own address by sending the headers itself.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver trusts the forwarding headers set by proxies in the
+// given networks. With none, the peer address is always the client.
+func NewClientIPResolver(trusted []netip.Prefix) *ClientIPResolver {
+	return &ClientIPResolver{trusted: trusted}
+}
+
+// Middleware resolves the client address of each request before next
+// handles it; handlers read it back with ClientIP.
+func (c *ClientIPResolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := c.Resolve(r); ok {
+			r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, addr))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Resolve returns the client address of r. The peer is the client unless it
+// is a trusted proxy; then the addresses it forwarded, from the RFC 7239
+// Forwarded header, X-Forwarded-For or X-Real-IP in that order of
+// preference, are walked from the nearest hop back to the first one not
+// trusted. A hop that cannot be parsed ends the walk at the hop after it.
+func (c *ClientIPResolver) Resolve(r *http.Request) (netip.Addr, bool) {
+	client, ok := parseHost(r.RemoteAddr)
+	if !ok || !c.isTrusted(client) {
+		return client, ok
+	}
+
+	var hops []string
+	switch {
+	case len(r.Header.Values("Forwarded")) > 0:
+		hops = forwardedFor(r.Header.Values("Forwarded"))
+	case len(r.Header.Values("X-Forwarded-For")) > 0:
+		hops = splitList(r.Header.Values("X-Forwarded-For"))
+	case r.Header.Get("X-Real-IP") != "":
+		hops = []string{strings.TrimSpace(r.Header.Get("X-Real-IP"))}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHost(hops[i])
+		if !ok {
+			break
+		}
+		client = hop
+		if !c.isTrusted(hop) {
+			break
+		}
+	}
+	return client, true
+}
+
+func (c *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client address of r resolved by the middleware, or
+// the peer address when the middleware did not run.
+func ClientIP(r *http.Request) (netip.Addr, bool) {
+	if addr, ok := r.Context().Value(clientIPKey{}).(netip.Addr); ok {
+		return addr, true
+	}
+	return parseHost(r.RemoteAddr)
+}
+
+// parseHost parses an address with an optional port, IPv6 addresses
+// bracketed when a port is given. IPv4-mapped addresses are unmapped and
+// zones dropped.
+func parseHost(host string) (netip.Addr, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// splitList splits comma-separated header values into their items.
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	return items
+}
+
+// forwardedFor returns the for= node of each element of RFC 7239 Forwarded
+// header values, nearest hop last. An element without one yields an empty
+// node, which does not parse as an address.
+func forwardedFor(values []string) []string {
+	var nodes []string
+	for _, element := range splitQuoted(values, ',') {
+		var node string
+		for _, pair := range splitQuoted([]string{element}, ';') {
+			key, value, ok := strings.Cut(pair, "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+				node = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// splitQuoted splits values on sep outside of quoted strings.
+func splitQuoted(values []string, sep byte) []string {
+	var items []string
+	for _, value := range values {
+		quoted, start := false, 0
+		for i := 0; i < len(value); i++ {
+			switch value[i] {
+			case '"':
+				quoted = !quoted
+			case sep:
+				if !quoted {
+					items = append(items, strings.TrimSpace(value[start:i]))
+					start = i + 1
+				}
+			}
+		}
+		items = append(items, strings.TrimSpace(value[start:]))
+	}
+	return items
+}
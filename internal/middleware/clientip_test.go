@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIPResolver_Resolve(t *testing.T) {
+	resolver := NewClientIPResolver([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "untrusted peer ignores headers",
+			remoteAddr: "203.0.113.9:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "203.0.113.9",
+		},
+		{
+			name:       "trusted peer without headers",
+			remoteAddr: "10.0.0.1:5000",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "X-Forwarded-For skips trusted hops only",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"192.0.2.66, 198.51.100.1", "10.1.2.3"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "X-Forwarded-For of trusted hops only",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.9.9.9, 10.1.2.3"}},
+			want:       "10.9.9.9",
+		},
+		{
+			name:       "X-Forwarded-For with a garbage hop",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1, garbage, 10.1.2.3"}},
+			want:       "10.1.2.3",
+		},
+		{
+			name:       "X-Real-IP",
+			remoteAddr: "10.0.0.1:5000",
+			headers:    map[string][]string{"X-Real-Ip": {" 198.51.100.7 "}},
+			want:       "198.51.100.7",
+		},
+		{
+			name:       "Forwarded wins over X-Forwarded-For",
+			remoteAddr: "10.0.0.1:5000",
+			headers: map[string][]string{
+				"Forwarded":       {`for=192.0.2.60;proto=http;by=203.0.113.43, For="[2001:db8:cafe::17]:4711"`},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "2001:db8:cafe::17",
+		},
+		{
+			name:       "Forwarded with an obfuscated hop",
+			remoteAddr: "[fd00::1]:5000",
+			headers:    map[string][]string{"Forwarded": {"for=198.51.100.1", `for=_hidden, for="10.2.0.1:80";proto="a,b"`}},
+			want:       "10.2.0.1",
+		},
+		{
+			name:       "IPv4-mapped peer",
+			remoteAddr: "[::ffff:10.0.0.1]:5000",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ip/me", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+
+			got, ok := resolver.Resolve(req)
+			if !ok || got.String() != tt.want {
+				t.Errorf("Resolve() = %v, %v, want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPResolver_Middleware(t *testing.T) {
+	resolver := NewClientIPResolver([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	var got netip.Addr
+	handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = ClientIP(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/ip/me", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.String() != "198.51.100.1" {
+		t.Errorf("ClientIP() = %v, want 198.51.100.1", got)
+	}
+
+	// Without the middleware the peer is the client.
+	if addr, ok := ClientIP(req); !ok || addr.String() != "10.0.0.1" {
+		t.Errorf("ClientIP() without middleware = %v, %v, want 10.0.0.1", addr, ok)
+	}
+}
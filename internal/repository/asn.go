@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// ASNRepository serves autonomous system lookups from an IP2Location LITE
+// ASN CSV (ip_from, ip_to, cidr, asn, as) held in memory. Both the IPv4 and
+// the IPv6 edition are accepted, like the location CSV.
+type ASNRepository struct {
+	csvPath  string
+	opts     options
+	data     atomic.Pointer[asnDataset]
+	reloadMu sync.Mutex
+}
+
+// asnDataset holds the ASN ranges; each range points to its row, and each
+// row into the deduplicated systems table.
+type asnDataset struct {
+	ranges  rangeTables
+	rows    []asnRow
+	systems []autonomousSystem
+}
+
+// asnRow keeps the network of a CSV row as the vendor published it.
+type asnRow struct {
+	system  uint32
+	network string
+}
+
+type autonomousSystem struct {
+	number uint32
+	name   string
+}
+
+func NewASNRepository(csvPath string, opts ...Option) (*ASNRepository, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	data, err := loadASNCSV(csvPath, o)
+	if err != nil {
+		return nil, fmt.Errorf("load ASN CSV: %w", err)
+	}
+
+	repo := &ASNRepository{
+		csvPath: csvPath,
+		opts:    o,
+	}
+	repo.data.Store(data)
+
+	return repo, nil
+}
+
+// Reload loads csvPath (or the current file when empty) and atomically
+// replaces the served dataset. On any error the previous dataset keeps being
+// served.
+func (r *ASNRepository) Reload(csvPath string) error {
+	if !r.reloadMu.TryLock() {
+		return domain.ErrReloadInProgress
+	}
+	defer r.reloadMu.Unlock()
+
+	if csvPath == "" {
+		csvPath = r.csvPath
+	}
+
+	data, err := loadASNCSV(csvPath, r.opts)
+	if err != nil {
+		return fmt.Errorf("load ASN CSV: %w", err)
+	}
+	if data.ranges.Len() == 0 {
+		return fmt.Errorf("validate %s: dataset has no valid rows", csvPath)
+	}
+
+	r.data.Store(data)
+	r.csvPath = csvPath
+
+	return nil
+}
+
+// Len returns the number of ranges currently served, IPv4 and IPv6 combined.
+func (r *ASNRepository) Len() int {
+	return r.data.Load().ranges.Len()
+}
+
+func (r *ASNRepository) FindASNByIPID(ipID uint32) (*domain.ASN, error) {
+	data := r.data.Load()
+	t := &data.ranges.v4
+
+	idx, ok := t.find(ipID)
+	if !ok {
+		return nil, fmt.Errorf("search ASN for IP ID %d: %w", ipID, domain.ErrASNNotFound)
+	}
+
+	return data.asn(t.value[idx]), nil
+}
+
+// FindASNByIPv6ID resolves a 128-bit IP number. IPv4-mapped addresses are
+// answered from the IPv4 table.
+func (r *ASNRepository) FindASNByIPv6ID(ipID domain.IPv6ID) (*domain.ASN, error) {
+	if ipv4ID, ok := ipID.IPv4(); ok {
+		return r.FindASNByIPID(ipv4ID)
+	}
+
+	data := r.data.Load()
+	t := &data.ranges.v6
+
+	idx, ok := t.find(ipID)
+	if !ok {
+		return nil, fmt.Errorf("search ASN for IPv6 ID %x%016x: %w", ipID.Hi, ipID.Lo, domain.ErrASNNotFound)
+	}
+
+	return data.asn(t.value[idx]), nil
+}
+
+func (d *asnDataset) asn(row uint32) *domain.ASN {
+	as := &d.systems[d.rows[row].system]
+	return &domain.ASN{
+		Number:  as.number,
+		Name:    as.name,
+		Network: d.rows[row].network,
+	}
+}
+
+// parseASNNetwork normalizes the cidr column: IPv4-mapped prefixes of the
+// IPv6 edition are written as IPv4, like the addresses they answer. A column
+// that does not parse yields no network rather than dropping the row.
+func parseASNNetwork(cidr string) string {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return ""
+	}
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked().String()
+}
+
+// loadASNCSV streams an IP2Location ASN CSV. Rows without an AS number
+// ("-", unassigned space) and malformed rows are skipped. The cidr column is
+// kept as each row's network.
+func loadASNCSV(csvPath string, opts options) (*asnDataset, error) {
+	file, err := openDatasetFile(csvPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close ASN CSV file: %v\n", closeErr)
+		}
+	}()
+
+	fileSize := file.size
+	counter := &countingReader{r: file}
+	reader := csv.NewReader(bufio.NewReaderSize(counter, 1<<20))
+	reader.ReuseRecord = true
+
+	progress := newLoadProgress(csvPath, fileSize, opts.progressInterval)
+
+	data := &asnDataset{}
+	systems := make(map[autonomousSystem]uint32)
+	names := newInterner()
+
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			if line == 0 {
+				return nil, fmt.Errorf("CSV file is empty")
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+
+		if line == 0 {
+			continue
+		}
+
+		progress.row(counter.n)
+
+		if len(record) < 5 {
+			continue
+		}
+		lower, err := parseIPNumber(record[0])
+		if err != nil {
+			continue
+		}
+		upper, err := parseIPNumber(record[1])
+		if err != nil {
+			continue
+		}
+		number, err := strconv.ParseUint(record[3], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		as := autonomousSystem{number: uint32(number), name: record[4]}
+		system, ok := systems[as]
+		if !ok {
+			as.name = names.intern(as.name)
+			system = uint32(len(data.systems))
+			data.systems = append(data.systems, as)
+			systems[as] = system
+		}
+
+		data.ranges.add(lower, upper, uint32(len(data.rows)))
+		data.rows = append(data.rows, asnRow{system: system, network: parseASNNetwork(record[2])})
+	}
+
+	data.ranges.sort()
+	progress.done(counter.n, data.ranges.Len())
+
+	return data, nil
+}
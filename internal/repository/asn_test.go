@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+const asnCSV = `"ip_from","ip_to","cidr","asn","as"
+"0","16777215","0.0.0.0/8","-","-"
+"16777216","16777983","1.0.0.0/22","13335","Cloudflare Inc"
+"134744064","134744319","8.8.8.0/24","15169","Google LLC"
+"281470816486400","281470816486655","::ffff:8.8.4.0/120","15169","Google LLC"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","2001:db8::/32","64500","Example Networks"
+"134744320","134744575","unknown","15169","Google LLC"
+"not-a-number","1","1.0.0.0/32","1","Broken"`
+
+func newTestASNRepository(t *testing.T, content string) (*ASNRepository, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "asn.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write ASN CSV: %v", err)
+	}
+
+	repo, err := NewASNRepository(path)
+	if err != nil {
+		t.Fatalf("NewASNRepository() error = %v", err)
+	}
+	return repo, path
+}
+
+func TestASNRepository_FindASNByIPID(t *testing.T) {
+	repo, _ := newTestASNRepository(t, asnCSV)
+
+	if got := repo.Len(); got != 5 {
+		t.Errorf("Len() = %d, want 5", got)
+	}
+
+	tests := []struct {
+		name    string
+		ipID    uint32
+		want    domain.ASN
+		wantErr error
+	}{
+		{name: "single CIDR row", ipID: 134744072, want: domain.ASN{Number: 15169, Name: "Google LLC", Network: "8.8.8.0/24"}},
+		{name: "IPv4-mapped row", ipID: 134743044, want: domain.ASN{Number: 15169, Name: "Google LLC", Network: "8.8.4.0/24"}},
+		{name: "row not aligned to its CIDR", ipID: 16777729, want: domain.ASN{Number: 13335, Name: "Cloudflare Inc", Network: "1.0.0.0/22"}},
+		{name: "row with a malformed CIDR", ipID: 134744330, want: domain.ASN{Number: 15169, Name: "Google LLC"}},
+		{name: "unassigned range", ipID: 1, wantErr: domain.ErrASNNotFound},
+		{name: "past the last range", ipID: 4294967295, wantErr: domain.ErrASNNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.FindASNByIPID(tt.ipID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("FindASNByIPID() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindASNByIPID() error = %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("FindASNByIPID() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestASNRepository_FindASNByIPv6ID(t *testing.T) {
+	repo, _ := newTestASNRepository(t, asnCSV)
+
+	got, err := repo.FindASNByIPv6ID(domain.IPv6ID{Hi: 0x20010db8_00010000, Lo: 1})
+	if err != nil {
+		t.Fatalf("FindASNByIPv6ID() error = %v", err)
+	}
+	want := domain.ASN{Number: 64500, Name: "Example Networks", Network: "2001:db8::/32"}
+	if *got != want {
+		t.Errorf("FindASNByIPv6ID() = %+v, want %+v", *got, want)
+	}
+
+	got, err = repo.FindASNByIPv6ID(domain.IPv4ToIPv6ID(134744072))
+	if err != nil || got.Number != 15169 {
+		t.Errorf("FindASNByIPv6ID() for an IPv4-mapped address = %+v, %v, want AS15169", got, err)
+	}
+
+	if _, err := repo.FindASNByIPv6ID(domain.IPv6ID{Hi: 0x20010db9_00000000}); !errors.Is(err, domain.ErrASNNotFound) {
+		t.Errorf("FindASNByIPv6ID() error = %v, want %v", err, domain.ErrASNNotFound)
+	}
+}
+
+func TestASNRepository_Reload(t *testing.T) {
+	repo, path := newTestASNRepository(t, asnCSV)
+
+	updated := `"ip_from","ip_to","cidr","asn","as"
+"134744064","134744319","8.8.8.0/24","64501","Renumbered"`
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatalf("Failed to rewrite ASN CSV: %v", err)
+	}
+	if err := repo.Reload(""); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got, err := repo.FindASNByIPID(134744072); err != nil || got.Number != 64501 {
+		t.Errorf("FindASNByIPID() after reload = %+v, %v, want AS64501", got, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`"ip_from","ip_to","cidr","asn","as"`), 0644); err != nil {
+		t.Fatalf("Failed to rewrite ASN CSV: %v", err)
+	}
+	if err := repo.Reload(""); err == nil {
+		t.Error("Reload() of a CSV without rows should fail")
+	}
+	if got, err := repo.FindASNByIPID(134744072); err != nil || got.Number != 64501 {
+		t.Errorf("FindASNByIPID() after failed reload = %+v, %v, want AS64501", got, err)
+	}
+}
@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"unsafe"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// dataset is an immutable, sorted snapshot of the loaded CSV. Reload builds a
+// new one and swaps the pointer, so lookups that already hold the previous
+// snapshot finish against it.
+//
+// Ranges are stored column by column; every row only carries small indexes
+// into the deduplicated country and city tables, so repeated names such as
+// "United States of America" are stored once.
+type dataset struct {
+	v4        ipv4Table
+	v6        ipv6Table
+	countries []country
+	cities    []city
+	// report is set for datasets loaded from CSV; snapshots have none.
+	report *ValidationReport
+	info   domain.DatasetInfo
+	// placeIndex answers reverse lookups; see places.
+	placesOnce sync.Once
+	placeIndex *placeIndex
+}
+
+type country struct {
+	code string
+	name string
+}
+
+// city holds every per-place DB11 column. Two rows share a city entry only
+// when all of them match.
+type city struct {
+	region    string
+	name      string
+	latitude  float64
+	longitude float64
+	zipCode   string
+	timeZone  string
+}
+
+// ipv4Table stores IPv4 ranges as parallel columns sorted by lower bound.
+type ipv4Table struct {
+	lower   []uint32
+	upper   []uint32
+	country []uint16
+	city    []uint32
+	// line is the CSV line of each row, kept only until validation is done.
+	line []uint32
+	// index answers find once the table is complete; see WithIndex.
+	index ipv4Index
+}
+
+func (t *ipv4Table) Len() int           { return len(t.lower) }
+func (t *ipv4Table) Less(i, j int) bool { return t.lower[i] < t.lower[j] }
+func (t *ipv4Table) Swap(i, j int) {
+	t.lower[i], t.lower[j] = t.lower[j], t.lower[i]
+	t.upper[i], t.upper[j] = t.upper[j], t.upper[i]
+	t.country[i], t.country[j] = t.country[j], t.country[i]
+	t.city[i], t.city[j] = t.city[j], t.city[i]
+	if t.line != nil {
+		t.line[i], t.line[j] = t.line[j], t.line[i]
+	}
+}
+
+// find returns the row containing ipID.
+func (t *ipv4Table) find(ipID uint32) (int, bool) {
+	if t.index != nil {
+		return t.index.find(ipID)
+	}
+
+	idx := sort.Search(len(t.upper), func(i int) bool {
+		return t.upper[i] >= ipID
+	})
+
+	if idx < len(t.upper) && t.lower[idx] <= ipID {
+		return idx, true
+	}
+	return 0, false
+}
+
+// ipv6Table stores native IPv6 ranges as parallel columns sorted by lower
+// bound.
+type ipv6Table struct {
+	lower   []domain.IPv6ID
+	upper   []domain.IPv6ID
+	country []uint16
+	city    []uint32
+	line    []uint32
+}
+
+func (t *ipv6Table) Len() int           { return len(t.lower) }
+func (t *ipv6Table) Less(i, j int) bool { return t.lower[i].Less(t.lower[j]) }
+func (t *ipv6Table) Swap(i, j int) {
+	t.lower[i], t.lower[j] = t.lower[j], t.lower[i]
+	t.upper[i], t.upper[j] = t.upper[j], t.upper[i]
+	t.country[i], t.country[j] = t.country[j], t.country[i]
+	t.city[i], t.city[j] = t.city[j], t.city[i]
+	if t.line != nil {
+		t.line[i], t.line[j] = t.line[j], t.line[i]
+	}
+}
+
+func (t *ipv6Table) find(ipID domain.IPv6ID) (int, bool) {
+	idx := sort.Search(len(t.upper), func(i int) bool {
+		return t.upper[i].Compare(ipID) >= 0
+	})
+
+	if idx < len(t.upper) && t.lower[idx].Compare(ipID) <= 0 {
+		return idx, true
+	}
+	return 0, false
+}
+
+func (d *dataset) Len() int {
+	return d.v4.Len() + d.v6.Len()
+}
+
+// location4 builds the domain view of IPv4 row i.
+func (d *dataset) location4(i int) *domain.Location {
+	location := d.attributes(d.v4.country[i], d.v4.city[i])
+	location.LowerIPID = d.v4.lower[i]
+	location.UpperIPID = d.v4.upper[i]
+	return location
+}
+
+// location6 builds the domain view of IPv6 row i.
+func (d *dataset) location6(i int) *domain.Location {
+	location := d.attributes(d.v6.country[i], d.v6.city[i])
+	location.LowerIPv6ID = d.v6.lower[i]
+	location.UpperIPv6ID = d.v6.upper[i]
+	return location
+}
+
+func (d *dataset) attributes(countryIdx uint16, cityIdx uint32) *domain.Location {
+	c := &d.countries[countryIdx]
+	ct := &d.cities[cityIdx]
+
+	return &domain.Location{
+		Country:     c.name,
+		CountryCode: c.code,
+		Region:      ct.region,
+		City:        ct.name,
+		Latitude:    ct.latitude,
+		Longitude:   ct.longitude,
+		ZipCode:     ct.zipCode,
+		TimeZone:    ct.timeZone,
+	}
+}
+
+// validate rejects datasets that would silently break lookups if swapped in
+// over a working one.
+func (d *dataset) validate() error {
+	if d.Len() == 0 {
+		return fmt.Errorf("dataset has no valid rows")
+	}
+	return nil
+}
+
+var (
+	countrySize = int64(unsafe.Sizeof(country{}))
+	citySize    = int64(unsafe.Sizeof(city{}))
+	ipv4RowSize = int64(4 + 4 + 2 + 4 + 4)
+	ipv6RowSize = int64(unsafe.Sizeof(domain.IPv6ID{}))*2 + 2 + 4 + 4
+)
+
+// datasetBuilder accumulates parsed rows into a dataset, deduplicating the
+// country and city attributes as it goes.
+type datasetBuilder struct {
+	data      *dataset
+	countries map[country]uint16
+	cities    map[city]uint32
+	interner  *interner
+}
+
+func newDatasetBuilder() *datasetBuilder {
+	return &datasetBuilder{
+		data:      &dataset{},
+		countries: make(map[country]uint16),
+		cities:    make(map[city]uint32),
+		interner:  newInterner(),
+	}
+}
+
+// grow reserves room for n more IPv4 rows.
+func (b *datasetBuilder) grow(n int) {
+	t := &b.data.v4
+	t.lower = slices.Grow(t.lower, n)
+	t.upper = slices.Grow(t.upper, n)
+	t.country = slices.Grow(t.country, n)
+	t.city = slices.Grow(t.city, n)
+	t.line = slices.Grow(t.line, n)
+}
+
+// add appends a parsed row read from CSV line line.
+func (b *datasetBuilder) add(location *domain.Location, line int) error {
+	countryIdx, err := b.countryIndex(country{
+		code: location.CountryCode,
+		name: location.Country,
+	})
+	if err != nil {
+		return err
+	}
+
+	cityIdx := b.cityIndex(city{
+		region:    location.Region,
+		name:      location.City,
+		latitude:  location.Latitude,
+		longitude: location.Longitude,
+		zipCode:   location.ZipCode,
+		timeZone:  location.TimeZone,
+	})
+
+	if location.IsIPv6() {
+		t := &b.data.v6
+		t.lower = append(t.lower, location.LowerIPv6ID)
+		t.upper = append(t.upper, location.UpperIPv6ID)
+		t.country = append(t.country, countryIdx)
+		t.city = append(t.city, cityIdx)
+		t.line = append(t.line, uint32(line))
+		return nil
+	}
+
+	t := &b.data.v4
+	t.lower = append(t.lower, location.LowerIPID)
+	t.upper = append(t.upper, location.UpperIPID)
+	t.country = append(t.country, countryIdx)
+	t.city = append(t.city, cityIdx)
+	t.line = append(t.line, uint32(line))
+	return nil
+}
+
+func (b *datasetBuilder) countryIndex(c country) (uint16, error) {
+	if idx, ok := b.countries[c]; ok {
+		return idx, nil
+	}
+
+	if len(b.data.countries) > math.MaxUint16 {
+		return 0, fmt.Errorf("more than %d distinct countries", math.MaxUint16+1)
+	}
+
+	c.code = b.interner.intern(c.code)
+	c.name = b.interner.intern(c.name)
+
+	idx := uint16(len(b.data.countries))
+	b.data.countries = append(b.data.countries, c)
+	b.countries[c] = idx
+	return idx, nil
+}
+
+func (b *datasetBuilder) cityIndex(c city) uint32 {
+	if idx, ok := b.cities[c]; ok {
+		return idx
+	}
+
+	c.region = b.interner.intern(c.region)
+	c.name = b.interner.intern(c.name)
+	c.zipCode = b.interner.intern(c.zipCode)
+	c.timeZone = b.interner.intern(c.timeZone)
+
+	idx := uint32(len(b.data.cities))
+	b.data.cities = append(b.data.cities, c)
+	b.cities[c] = idx
+	return idx
+}
+
+// bytes estimates the memory held by the dataset built so far.
+func (b *datasetBuilder) bytes() int64 {
+	d := b.data
+	return int64(cap(d.v4.lower))*ipv4RowSize +
+		int64(cap(d.v6.lower))*ipv6RowSize +
+		int64(cap(d.countries))*countrySize +
+		int64(cap(d.cities))*citySize +
+		b.interner.bytes
+}
+
+// build sorts the tables (IP2Location files are normally sorted already, so
+// this is usually just a check) and returns the finished dataset.
+func (b *datasetBuilder) build() *dataset {
+	d := b.data
+	if !sort.IsSorted(&d.v4) {
+		sort.Stable(&d.v4)
+	}
+	if !sort.IsSorted(&d.v6) {
+		sort.Stable(&d.v6)
+	}
+
+	d.countries = slices.Clip(d.countries)
+	d.cities = slices.Clip(d.cities)
+
+	b.data = nil
+	b.countries = nil
+	b.cities = nil
+	return d
+}
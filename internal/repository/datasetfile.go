@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// datasetFile is a dataset opened for streaming. Files ending in .gz are
+// gunzipped and .zip archives are read from their CSV entry while parsing,
+// so the uncompressed data never has to exist on disk.
+type datasetFile struct {
+	io.Reader
+	// size is the uncompressed length, 0 when unknown. It drives progress
+	// reporting and capacity estimates, which both work on CSV bytes.
+	size int64
+
+	file    *os.File
+	closers []io.Closer
+	// hash is fed the file as stored on disk, so the checksum matches the
+	// artifact that was shipped whether or not it is compressed.
+	hash hash.Hash
+}
+
+func openDatasetFile(path string) (*datasetFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open file %s: %w", path, err)
+	}
+
+	f := &datasetFile{file: file, hash: sha256.New()}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		err = f.openGzip()
+	case ".zip":
+		err = f.openZip()
+	default:
+		if info, statErr := file.Stat(); statErr == nil {
+			f.size = info.Size()
+		}
+		f.Reader = io.TeeReader(file, f.hash)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return f, nil
+}
+
+func (f *datasetFile) openGzip() error {
+	f.size = gzipSize(f.file)
+
+	gz, err := gzip.NewReader(io.TeeReader(f.file, f.hash))
+	if err != nil {
+		return fmt.Errorf("read gzip header: %w", err)
+	}
+	f.Reader = gz
+	f.closers = append(f.closers, gz)
+	return nil
+}
+
+// openZip hashes the archive up front, since zip entries are read through
+// random access rather than one pass over the file, then opens its CSV.
+func (f *datasetFile) openZip() error {
+	size, err := io.Copy(f.hash, f.file)
+	if err != nil {
+		return fmt.Errorf("read archive: %w", err)
+	}
+
+	archive, err := zip.NewReader(f.file, size)
+	if err != nil {
+		return fmt.Errorf("read zip archive: %w", err)
+	}
+
+	entry, err := zipCSVEntry(archive)
+	if err != nil {
+		return err
+	}
+	rc, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("open %s in archive: %w", entry.Name, err)
+	}
+
+	f.Reader = rc
+	f.size = int64(entry.UncompressedSize64)
+	f.closers = append(f.closers, rc)
+	return nil
+}
+
+// zipCSVEntry picks the CSV inside an archive. Vendor archives also carry a
+// license and a readme, so exactly one .csv entry is expected.
+func zipCSVEntry(archive *zip.Reader) (*zip.File, error) {
+	var found []*zip.File
+	for _, entry := range archive.File {
+		if !entry.FileInfo().IsDir() && strings.EqualFold(filepath.Ext(entry.Name), ".csv") {
+			found = append(found, entry)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no CSV file in archive")
+	case 1:
+		return found[0], nil
+	}
+
+	names := make([]string, len(found))
+	for i, entry := range found {
+		names[i] = entry.Name
+	}
+	return nil, fmt.Errorf("archive holds several CSV files: %s", strings.Join(names, ", "))
+}
+
+// gzipSize reads the uncompressed length from the gzip trailer. It is only
+// stored modulo 4 GiB, so an implausible value is reported as unknown.
+func gzipSize(file *os.File) int64 {
+	info, err := file.Stat()
+	if err != nil || info.Size() < 18 {
+		return 0
+	}
+
+	var trailer [4]byte
+	if _, err := file.ReadAt(trailer[:], info.Size()-4); err != nil {
+		return 0
+	}
+	size := int64(binary.LittleEndian.Uint32(trailer[:]))
+	if size < info.Size()/2 {
+		return 0
+	}
+	return size
+}
+
+// Sum returns the SHA-256 of the file on disk. For plain and gzip files it
+// is only complete once the content has been read to the end.
+func (f *datasetFile) Sum() []byte {
+	return f.hash.Sum(nil)
+}
+
+func (f *datasetFile) Close() error {
+	var firstErr error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		if err := f.closers[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := f.file.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const compressedTestCSV = `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.052230","-118.243680","90001","-07:00"
+"16777472","16778239","CN","China","Fujian","Fuzhou","26.061390","119.306110","350004","+08:00"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(data)); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewMemoryRepository_Compressed(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+	}{
+		{name: "gzip", file: "IP2LOCATION-LITE-DB11.CSV.gz", data: gzipBytes(t, compressedTestCSV)},
+		{name: "zip", file: "IP2LOCATION-LITE-DB11.CSV.ZIP", data: zipBytes(t, map[string]string{
+			"IP2LOCATION-LITE-DB11.CSV": compressedTestCSV,
+			"LICENSE_LITE.TXT":          "license",
+			"README_LITE.TXT":           "readme",
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", tt.file, err)
+			}
+
+			repo, err := NewMemoryRepository(path)
+			if err != nil {
+				t.Fatalf("NewMemoryRepository() error = %v", err)
+			}
+			if repo.Len() != 3 {
+				t.Errorf("Len() = %d, want 3", repo.Len())
+			}
+
+			location, err := repo.FindByIPID(134744072)
+			if err != nil || location.City != "Mountain View" {
+				t.Errorf("FindByIPID() = %+v, %v, want Mountain View", location, err)
+			}
+
+			sum := sha256.Sum256(tt.data)
+			if got := repo.DatasetInfo().SHA256; got != hex.EncodeToString(sum[:]) {
+				t.Errorf("DatasetInfo().SHA256 = %s, want the checksum of the compressed file", got)
+			}
+		})
+	}
+}
+
+func TestOpenDatasetFile_ZipEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		entries map[string]string
+		wantErr string
+	}{
+		{name: "no CSV", entries: map[string]string{"README_LITE.TXT": "readme"}, wantErr: "no CSV file"},
+		{name: "several CSVs", entries: map[string]string{"a.csv": "", "b.CSV": ""}, wantErr: "several CSV files"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".zip")
+			if err := os.WriteFile(path, zipBytes(t, tt.entries), 0644); err != nil {
+				t.Fatalf("Failed to write archive: %v", err)
+			}
+
+			_, err := openDatasetFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("openDatasetFile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenDatasetFile_GzipSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv.gz")
+	if err := os.WriteFile(path, gzipBytes(t, compressedTestCSV), 0644); err != nil {
+		t.Fatalf("Failed to write gzip: %v", err)
+	}
+
+	f, err := openDatasetFile(path)
+	if err != nil {
+		t.Fatalf("openDatasetFile() error = %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if f.size != int64(len(compressedTestCSV)) {
+		t.Errorf("size = %d, want the uncompressed length %d", f.size, len(compressedTestCSV))
+	}
+}
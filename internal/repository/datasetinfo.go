@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// versionSidecarSuffix names the file next to a dataset that states its
+// vendor version, e.g. IP2LOCATION-LITE-DB11.CSV.version.
+const versionSidecarSuffix = ".version"
+
+// fileNameDate matches a release date in a dataset file name: 2024-10,
+// 202410, 2024-10-01 or 20241001.
+var fileNameDate = regexp.MustCompile(`(?:^|[^0-9])((?:19|20)[0-9]{2})-?(0[1-9]|1[0-2])(?:-?(0[1-9]|[12][0-9]|3[01]))?(?:[^0-9]|$)`)
+
+func newDatasetInfo(path string, sum []byte, rows, skipped int) domain.DatasetInfo {
+	return domain.DatasetInfo{
+		Path:        path,
+		SHA256:      hex.EncodeToString(sum),
+		Version:     datasetVersion(path),
+		Rows:        rows,
+		SkippedRows: skipped,
+		LoadedAt:    time.Now(),
+	}
+}
+
+// datasetVersion returns the first line of path's version sidecar or, when
+// there is none, the release date in the file name.
+func datasetVersion(path string) string {
+	if file, err := os.Open(path + versionSidecarSuffix); err == nil {
+		defer func() {
+			_ = file.Close()
+		}()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				return line
+			}
+		}
+	}
+
+	m := fileNameDate.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return ""
+	}
+	if m[3] == "" {
+		return m[1] + "-" + m[2]
+	}
+	return m[1] + "-" + m[2] + "-" + m[3]
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDatasetVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		file    string
+		sidecar string
+		want    string
+	}{
+		{name: "no date", file: "IP2LOCATION-LITE-DB11.CSV", want: ""},
+		{name: "year and month", file: "IP2LOCATION-LITE-DB11-202410.CSV", want: "2024-10"},
+		{name: "dashed date", file: "db11_2024-10-01.csv", want: "2024-10-01"},
+		{name: "compact date", file: "IP2LOCATION-LITE-DB11.20241001.CSV", want: "2024-10-01"},
+		{name: "edition number is not a date", file: "IP2LOCATION-LITE-DB11.IPV6.CSV", want: ""},
+		{name: "sidecar wins", file: "IP2LOCATION-LITE-DB11-202409.CSV", sidecar: "\n 2024-10 \n", want: "2024-10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if tt.sidecar != "" {
+				if err := os.WriteFile(path+versionSidecarSuffix, []byte(tt.sidecar), 0644); err != nil {
+					t.Fatalf("Failed to write sidecar: %v", err)
+				}
+			}
+
+			if got := datasetVersion(path); got != tt.want {
+				t.Errorf("datasetVersion(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRepository_DatasetInfo(t *testing.T) {
+	content := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.052230","-118.243680","90001","-07:00"
+"16777472","16778239","CN","China","Fujian","Fuzhou","26.061390","119.306110","350004","+08:00"
+"bad","16778240","CN","China","Fujian","Fuzhou","26.061390","119.306110","350004","+08:00"
+"16779000","16778240","CN","China","Fujian","Fuzhou","26.061390","119.306110","350004","+08:00"`
+
+	path := filepath.Join(t.TempDir(), "IP2LOCATION-LITE-DB11-202410.CSV")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	repo, err := NewMemoryRepository(path)
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+
+	info := repo.DatasetInfo()
+	sum := sha256.Sum256([]byte(content))
+	if info.Path != path || info.SHA256 != hex.EncodeToString(sum[:]) || info.Version != "2024-10" {
+		t.Errorf("DatasetInfo() = %+v, want path %s, version 2024-10 and the file's SHA-256", info, path)
+	}
+	if info.Rows != 2 || info.SkippedRows != 2 {
+		t.Errorf("DatasetInfo() rows = %d, skipped = %d, want 2 and 2", info.Rows, info.SkippedRows)
+	}
+	if info.LoadedAt.IsZero() {
+		t.Error("DatasetInfo() LoadedAt is not set")
+	}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+)
+
+// IndexKind selects the structure that answers IPv4 lookups in a CSV
+// dataset. Every kind returns the same row for every address; they differ
+// in memory use and cache behaviour.
+type IndexKind string
+
+const (
+	// IndexBinary binary-searches the sorted upper bounds. It needs no
+	// memory beyond the table.
+	IndexBinary IndexKind = "binary"
+	// IndexEytzinger lays the upper bounds out in breadth-first (Eytzinger)
+	// order, so the first levels of every search share a few cache lines.
+	IndexEytzinger IndexKind = "eytzinger"
+	// IndexPrefix narrows the search with a table keyed on the top 16 bits
+	// of the address before binary-searching the few rows left.
+	IndexPrefix IndexKind = "prefix"
+	// IndexTrie walks a bitmap-compressed radix trie over the address bits,
+	// at most six nodes per lookup and no comparisons.
+	IndexTrie IndexKind = "trie"
+)
+
+// IndexKinds lists the accepted index kinds, default first.
+func IndexKinds() []IndexKind {
+	return []IndexKind{IndexBinary, IndexEytzinger, IndexPrefix, IndexTrie}
+}
+
+// ipv4Index answers lookups on a sorted ipv4Table. find returns the first
+// row, in table order, whose range contains ipID; rows are only ambiguous
+// when the dataset has overlapping ranges.
+type ipv4Index interface {
+	find(ipID uint32) (int, bool)
+	// bytes is the memory held by the index beyond the table itself.
+	bytes() int64
+}
+
+func newIPv4Index(kind IndexKind, t *ipv4Table) (ipv4Index, error) {
+	keys := searchKeys(t.upper)
+
+	switch kind {
+	case IndexBinary, "":
+		return &binaryIndex{keys: keys, lower: t.lower, extra: extraBytes(keys, t.upper)}, nil
+	case IndexEytzinger:
+		return newEytzingerIndex(keys, t.lower), nil
+	case IndexPrefix:
+		return newPrefixIndex(keys, t.lower, extraBytes(keys, t.upper)), nil
+	case IndexTrie:
+		return newTrieIndex(t.lower, t.upper), nil
+	}
+	return nil, fmt.Errorf("unknown index %q", kind)
+}
+
+// searchKeys returns the running maximum of upper. The first row whose key
+// is >= an address is then the first row that can contain it, even when
+// overlapping ranges leave upper unsorted. Sorted input, the normal case, is
+// returned as is.
+func searchKeys(upper []uint32) []uint32 {
+	if slices.IsSorted(upper) {
+		return upper
+	}
+
+	keys := make([]uint32, len(upper))
+	var maxUpper uint32
+	for i, u := range upper {
+		maxUpper = max(maxUpper, u)
+		keys[i] = maxUpper
+	}
+	return keys
+}
+
+// extraBytes is the size of keys when searchKeys had to copy them.
+func extraBytes(keys, upper []uint32) int64 {
+	if len(keys) > 0 && &keys[0] != &upper[0] {
+		return int64(len(keys)) * 4
+	}
+	return 0
+}
+
+// lowerBound returns the first i with keys[i] >= ipID, or len(keys).
+func lowerBound(keys []uint32, ipID uint32) int {
+	lo, hi := 0, len(keys)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if keys[mid] < ipID {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+type binaryIndex struct {
+	keys  []uint32
+	lower []uint32
+	extra int64
+}
+
+func (x *binaryIndex) find(ipID uint32) (int, bool) {
+	i := lowerBound(x.keys, ipID)
+	if i < len(x.keys) && x.lower[i] <= ipID {
+		return i, true
+	}
+	return 0, false
+}
+
+func (x *binaryIndex) bytes() int64 { return x.extra }
@@ -0,0 +1,72 @@
+package repository
+
+import "math/bits"
+
+// eytzingerIndex stores the search keys as an implicit binary search tree:
+// node k has children 2k and 2k+1 and the root is 1. A search touches as
+// many keys as a binary search, but the top levels sit together at the front
+// of the array and stay in cache, and each node keeps its row next to its key.
+type eytzingerIndex struct {
+	// nodes is indexed by tree node; index 0 is unused.
+	nodes []eytzingerNode
+	lower []uint32
+}
+
+type eytzingerNode struct {
+	key uint32
+	row uint32
+}
+
+func newEytzingerIndex(sorted, lower []uint32) *eytzingerIndex {
+	n := len(sorted)
+	x := &eytzingerIndex{
+		nodes: make([]eytzingerNode, n+1),
+		lower: lower,
+	}
+
+	// An in-order walk of the implicit tree visits nodes in sorted order.
+	next := 0
+	var fill func(k int)
+	fill = func(k int) {
+		if k > n {
+			return
+		}
+		fill(2 * k)
+		x.nodes[k] = eytzingerNode{key: sorted[next], row: uint32(next)}
+		next++
+		fill(2*k + 1)
+	}
+	fill(1)
+
+	return x
+}
+
+func (x *eytzingerIndex) find(ipID uint32) (int, bool) {
+	n := len(x.nodes) - 1
+
+	k := 1
+	for k <= n {
+		if x.nodes[k].key < ipID {
+			k = 2*k + 1
+		} else {
+			k = 2 * k
+		}
+	}
+	// k turned left at the last node with key >= ipID and right ever
+	// since. Dropping those right turns (trailing 1 bits) and the left turn
+	// returns to that node; k is 0 when every key is smaller.
+	k >>= bits.TrailingZeros(^uint(k)) + 1
+	if k == 0 {
+		return 0, false
+	}
+
+	row := int(x.nodes[k].row)
+	if x.lower[row] <= ipID {
+		return row, true
+	}
+	return 0, false
+}
+
+func (x *eytzingerIndex) bytes() int64 {
+	return int64(len(x.nodes)) * 8
+}
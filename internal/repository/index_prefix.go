@@ -0,0 +1,55 @@
+package repository
+
+// prefixBits is how many leading address bits the first level of
+// prefixIndex resolves.
+const prefixBits = 16
+
+// prefixIndex is a two-level lookup: a table indexed by the top 16 bits of
+// the address bounds the rows that can answer, and a binary search over
+// those rows, a handful on a full dataset, finishes it.
+type prefixIndex struct {
+	// start[p] is the first row whose key is >= p << 16; start has one
+	// more entry than there are prefixes, holding the row count.
+	start []uint32
+	keys  []uint32
+	lower []uint32
+	extra int64
+}
+
+func newPrefixIndex(keys, lower []uint32, extra int64) *prefixIndex {
+	x := &prefixIndex{
+		start: make([]uint32, 1<<prefixBits+1),
+		keys:  keys,
+		lower: lower,
+		extra: extra,
+	}
+
+	p := 0
+	for i, key := range keys {
+		for ; p <= int(key>>(32-prefixBits)); p++ {
+			x.start[p] = uint32(i)
+		}
+	}
+	for ; p < len(x.start); p++ {
+		x.start[p] = uint32(len(keys))
+	}
+
+	return x
+}
+
+func (x *prefixIndex) find(ipID uint32) (int, bool) {
+	p := ipID >> (32 - prefixBits)
+	lo, hi := int(x.start[p]), int(x.start[p+1])
+
+	// Row hi, if any, has a key in a later prefix, so it is the answer when
+	// nothing in [lo, hi) is >= ipID.
+	i := lo + lowerBound(x.keys[lo:hi], ipID)
+	if i < len(x.keys) && x.lower[i] <= ipID {
+		return i, true
+	}
+	return 0, false
+}
+
+func (x *prefixIndex) bytes() int64 {
+	return int64(len(x.start))*4 + x.extra
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// randomIPv4Table builds a sorted table of up to n ranges with random sizes,
+// mostly back to back as in IP2Location files, with occasional gaps. With
+// overlaps, some ranges also reach into or past their successors.
+func randomIPv4Table(rng *rand.Rand, n int, overlaps bool) *ipv4Table {
+	t := &ipv4Table{}
+	step := uint64(math.MaxUint32) / uint64(n+1)
+
+	var next uint64
+	for range n {
+		from := next
+		if rng.IntN(4) == 0 {
+			from += rng.Uint64N(step/4 + 1)
+		}
+		to := min(from+rng.Uint64N(step), math.MaxUint32)
+
+		t.lower = append(t.lower, uint32(from))
+		if overlaps && rng.IntN(10) == 0 {
+			t.upper = append(t.upper, uint32(min(to+rng.Uint64N(3*step), math.MaxUint32)))
+		} else {
+			t.upper = append(t.upper, uint32(to))
+		}
+
+		next = to + 1
+		if next > math.MaxUint32 {
+			break
+		}
+	}
+	return t
+}
+
+// referenceFind is the lookup every index must agree with: the first row in
+// table order whose range contains ipID.
+func referenceFind(t *ipv4Table, ipID uint32) (int, bool) {
+	for i := range t.lower {
+		if t.lower[i] <= ipID && ipID <= t.upper[i] {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func TestIPv4Index_MatchesReference(t *testing.T) {
+	tables := map[string]*ipv4Table{
+		"empty":        {},
+		"single":       {lower: []uint32{10}, upper: []uint32{20}},
+		"whole space":  {lower: []uint32{0}, upper: []uint32{math.MaxUint32}},
+		"edges":        {lower: []uint32{0, math.MaxUint32}, upper: []uint32{0, math.MaxUint32}},
+		"nested":       {lower: []uint32{0, 5, 5, 100}, upper: []uint32{50, 10, 60, 100}},
+		"sparse":       randomIPv4Table(rand.New(rand.NewPCG(1, 2)), 50, false),
+		"dense":        randomIPv4Table(rand.New(rand.NewPCG(3, 4)), 5000, false),
+		"overlapping":  randomIPv4Table(rand.New(rand.NewPCG(5, 6)), 5000, true),
+		"single hosts": {lower: []uint32{1, 2, 3, 64, 65, 4096}, upper: []uint32{1, 2, 3, 64, 65, 4096}},
+	}
+
+	for name, table := range tables {
+		// Probe every bound, its neighbours and random addresses.
+		var probes []uint32
+		for i := range table.lower {
+			for _, p := range []uint32{table.lower[i], table.upper[i]} {
+				probes = append(probes, p, p-1, p+1)
+			}
+		}
+		rng := rand.New(rand.NewPCG(7, 8))
+		for range 2000 {
+			probes = append(probes, rng.Uint32())
+		}
+		probes = append(probes, 0, math.MaxUint32)
+
+		for _, kind := range IndexKinds() {
+			t.Run(name+"/"+string(kind), func(t *testing.T) {
+				index, err := newIPv4Index(kind, table)
+				if err != nil {
+					t.Fatalf("newIPv4Index() error = %v", err)
+				}
+
+				for _, ipID := range probes {
+					wantRow, wantOK := referenceFind(table, ipID)
+					gotRow, gotOK := index.find(ipID)
+					if gotOK != wantOK || (wantOK && gotRow != wantRow) {
+						t.Fatalf("find(%d) = %d, %v, want %d, %v", ipID, gotRow, gotOK, wantRow, wantOK)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMemoryRepository_WithIndex(t *testing.T) {
+	content := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.052230","-118.243680","90001","-07:00"
+"16777472","16778239","CN","China","Fujian","Fuzhou","26.061390","119.306110","350004","+08:00"
+"16779264","16781311","AU","Australia","Victoria","Melbourne","-37.814007","144.963171","3000","+10:00"`
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	for _, kind := range IndexKinds() {
+		t.Run(string(kind), func(t *testing.T) {
+			repo, err := NewMemoryRepository(path, WithIndex(kind))
+			if err != nil {
+				t.Fatalf("NewMemoryRepository() error = %v", err)
+			}
+
+			got, err := repo.FindByIPID(16777500)
+			if err != nil || got.CountryCode != "CN" {
+				t.Errorf("FindByIPID(16777500) = %+v, %v, want CN", got, err)
+			}
+			if _, err := repo.FindByIPID(16778500); err == nil {
+				t.Error("FindByIPID() in a gap should fail")
+			}
+			if err := repo.Reload(""); err != nil {
+				t.Fatalf("Reload() error = %v", err)
+			}
+			if got, err := repo.FindByIPID(16780000); err != nil || got.CountryCode != "AU" {
+				t.Errorf("FindByIPID(16780000) after reload = %+v, %v, want AU", got, err)
+			}
+		})
+	}
+
+	if _, err := NewMemoryRepository(path, WithIndex("btree")); err == nil {
+		t.Error("NewMemoryRepository() with an unknown index should fail")
+	}
+}
+
+// The index benchmarks run on a generated table the size of the full
+// IP2Location LITE DB11 file (BENCH_CSV_ROWS overrides the row count):
+//
+//	go test ./internal/repository -run '^$' -bench 'IPv4Index' -benchmem
+
+var indexBenchmarkTable *ipv4Table
+
+func benchmarkIPv4Table(b *testing.B) *ipv4Table {
+	b.Helper()
+
+	if indexBenchmarkTable == nil {
+		rows := 2_900_000
+		if v := os.Getenv("BENCH_CSV_ROWS"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				b.Fatalf("Invalid BENCH_CSV_ROWS: %v", err)
+			}
+			rows = n
+		}
+		indexBenchmarkTable = randomIPv4Table(rand.New(rand.NewPCG(42, 42)), rows, false)
+	}
+	return indexBenchmarkTable
+}
+
+func BenchmarkIPv4Index(b *testing.B) {
+	table := benchmarkIPv4Table(b)
+
+	rng := rand.New(rand.NewPCG(9, 9))
+	probes := make([]uint32, 1<<16)
+	for i := range probes {
+		probes[i] = rng.Uint32()
+	}
+
+	b.Run("sort.Search", func(b *testing.B) {
+		plain := &ipv4Table{lower: table.lower, upper: table.upper}
+		for i := 0; b.Loop(); i++ {
+			plain.find(probes[i&(len(probes)-1)])
+		}
+	})
+
+	for _, kind := range IndexKinds() {
+		b.Run(string(kind), func(b *testing.B) {
+			index, err := newIPv4Index(kind, table)
+			if err != nil {
+				b.Fatalf("newIPv4Index() error = %v", err)
+			}
+			for i := 0; b.Loop(); i++ {
+				index.find(probes[i&(len(probes)-1)])
+			}
+			b.ReportMetric(float64(index.bytes())/float64(len(table.lower)), "index-B/row")
+		})
+	}
+}
+
+func BenchmarkIPv4Index_Build(b *testing.B) {
+	table := benchmarkIPv4Table(b)
+
+	for _, kind := range IndexKinds() {
+		b.Run(string(kind), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := newIPv4Index(kind, table); err != nil {
+					b.Fatalf("newIPv4Index() error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+	"unsafe"
+)
+
+// trieStride is how many address bits each trie node resolves; 64 children
+// fit one bitmap word.
+const trieStride = 6
+
+// trieIndex is a multibit radix trie over the address space, compressed
+// the way poptrie compresses routing tables. Each node resolves six bits.
+// Children whose address block lies inside one range (or one gap) are leaves
+// rather than nodes, and of a node's leaves only the first of each run of
+// equal values is stored. Children whose block holds exactly one range
+// boundary are path-compressed into a split that compares against it. Bitmaps
+// and a popcount locate a child, so a lookup is at most six dependent loads.
+type trieIndex struct {
+	nodes  []trieNode
+	splits []trieSplit
+	// leaves holds row + 1 for each leaf run, 0 for addresses in no range.
+	leaves []uint32
+}
+
+// trieNode locates child c through three bitmaps. A node child's index is
+// innerBase plus the number of node children before it, and likewise for
+// splits. A leaf child's value is the last leaf run started at or before it.
+type trieNode struct {
+	inner     uint64
+	split     uint64
+	leaf      uint64
+	innerBase uint32
+	splitBase uint32
+	leafBase  uint32
+}
+
+// trieSplit answers a block holding one boundary: addresses from boundary
+// on get above, the others below (row + 1, or 0).
+type trieSplit struct {
+	boundary uint32
+	below    uint32
+	above    uint32
+}
+
+// newTrieIndex builds the trie from the addresses each row answers: the
+// part of its range not already covered by an earlier row.
+func newTrieIndex(lower, upper []uint32) *trieIndex {
+	b := &trieBuilder{
+		starts: []uint32{0},
+		values: []uint32{0},
+	}
+
+	covered := int64(-1)
+	for i := range lower {
+		from := max(int64(lower[i]), covered+1)
+		if from > int64(upper[i]) {
+			continue
+		}
+		if from > covered+1 {
+			b.segment(uint32(covered+1), 0)
+		}
+		b.segment(uint32(from), uint32(i+1))
+		covered = int64(upper[i])
+	}
+	if covered < math.MaxUint32 {
+		b.segment(uint32(covered+1), 0)
+	}
+
+	b.nodes = make([]trieNode, 1)
+	b.fill(0, 0, 32, 0)
+
+	return &trieIndex{
+		nodes:  slices.Clip(b.nodes),
+		splits: slices.Clip(b.splits),
+		leaves: slices.Clip(b.leaves),
+	}
+}
+
+func (x *trieIndex) find(ipID uint32) (int, bool) {
+	node := &x.nodes[0]
+	for rem := uint(32); ; {
+		stride := min(trieStride, rem)
+		rem -= stride
+		bit := uint64(1) << ((ipID >> rem) & (1<<stride - 1))
+
+		var value uint32
+		switch {
+		case node.inner&bit != 0:
+			node = &x.nodes[node.innerBase+uint32(bits.OnesCount64(node.inner&(bit-1)))]
+			continue
+		case node.split&bit != 0:
+			split := &x.splits[node.splitBase+uint32(bits.OnesCount64(node.split&(bit-1)))]
+			value = split.below
+			if ipID >= split.boundary {
+				value = split.above
+			}
+		default:
+			value = x.leaves[node.leafBase+uint32(bits.OnesCount64(node.leaf&(bit|(bit-1))))-1]
+		}
+
+		if value == 0 {
+			return 0, false
+		}
+		return int(value - 1), true
+	}
+}
+
+func (x *trieIndex) bytes() int64 {
+	return int64(len(x.nodes))*int64(unsafe.Sizeof(trieNode{})) +
+		int64(len(x.splits))*int64(unsafe.Sizeof(trieSplit{})) +
+		int64(len(x.leaves))*4
+}
+
+// trieBuilder holds the address space as consecutive segments: segment i
+// starts at starts[i], ends where the next one starts and answers values[i].
+type trieBuilder struct {
+	starts []uint32
+	values []uint32
+	nodes  []trieNode
+	splits []trieSplit
+	leaves []uint32
+}
+
+func (b *trieBuilder) segment(start, value uint32) {
+	last := len(b.starts) - 1
+	switch {
+	case b.values[last] == value:
+	case b.starts[last] == start:
+		b.values[last] = value
+	default:
+		b.starts = append(b.starts, start)
+		b.values = append(b.values, value)
+	}
+}
+
+// boundaries returns how many segments start inside the block from first to
+// last, counting at most two, after moving seg to the segment containing
+// first.
+func (b *trieBuilder) boundaries(seg *int, first, last uint64) int {
+	for *seg+1 < len(b.starts) && uint64(b.starts[*seg+1]) <= first {
+		*seg++
+	}
+
+	n := 0
+	for i := *seg + 1; n < 2 && i < len(b.starts) && uint64(b.starts[i]) <= last; i++ {
+		n++
+	}
+	return n
+}
+
+// fill builds node idx, which covers the 2^rem addresses from base; seg is
+// the segment containing base. A node's node children are allocated next to
+// each other, as are its splits and its leaves, before any child is filled.
+func (b *trieBuilder) fill(idx int, base uint64, rem uint, seg int) {
+	stride := min(trieStride, rem)
+	childRem := rem - stride
+	children := 1 << stride
+
+	node := trieNode{
+		splitBase: uint32(len(b.splits)),
+		leafBase:  uint32(len(b.leaves)),
+	}
+	var (
+		inner    int
+		hasLeaf  bool
+		runValue uint32
+	)
+	for c, s := 0, seg; c < children; c++ {
+		first := base + uint64(c)<<childRem
+		switch b.boundaries(&s, first, first+1<<childRem-1) {
+		case 2:
+			node.inner |= 1 << c
+			inner++
+		case 1:
+			node.split |= 1 << c
+			b.splits = append(b.splits, trieSplit{
+				boundary: b.starts[s+1],
+				below:    b.values[s],
+				above:    b.values[s+1],
+			})
+		default:
+			if value := b.values[s]; !hasLeaf || value != runValue {
+				node.leaf |= 1 << c
+				b.leaves = append(b.leaves, value)
+				hasLeaf, runValue = true, value
+			}
+		}
+	}
+
+	node.innerBase = uint32(len(b.nodes))
+	b.nodes = append(b.nodes, make([]trieNode, inner)...)
+	b.nodes[idx] = node
+
+	next := int(node.innerBase)
+	for c, s := 0, seg; c < children; c++ {
+		first := base + uint64(c)<<childRem
+		if b.boundaries(&s, first, first+1<<childRem-1) == 2 {
+			b.fill(next, first, childRem, s)
+			next++
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package repository
+
+import "strings"
+
+// interner deduplicates the strings kept in the country and city tables.
+// csv.Reader returns every field as a substring of one string per record, so
+// keeping a field without copying it would pin the whole line in memory.
+type interner struct {
+	values map[string]string
+	bytes  int64
+}
+
+func newInterner() *interner {
+	return &interner{values: make(map[string]string)}
+}
+
+func (in *interner) intern(s string) string {
+	if v, ok := in.values[s]; ok {
+		return v
+	}
+
+	v := strings.Clone(s)
+	in.values[v] = v
+	in.bytes += int64(len(v))
+	return v
+}
@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"math"
+	"math/bits"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// LayeredRepository stacks override files on top of a base repository. A
+// lookup is answered by the highest-priority layer that covers the address,
+// and the returned location names that layer in Source.
+//
+// Overrides are flattened into one table of disjoint segments when they are
+// loaded: a higher layer always wins over a lower one, and inside a layer the
+// most specific (smallest) range wins, with later entries breaking ties. The
+// bounds of a base answer are clipped to the gap between override segments,
+// so they describe the range the base layer really serves.
+type LayeredRepository struct {
+	base          domain.Repository
+	baseName      string
+	overridePaths []string
+
+	overlay  atomic.Pointer[overlay]
+	reloadMu sync.Mutex
+}
+
+// NewLayeredRepository serves base under overridePaths, which are listed
+// from highest to lowest priority. Layers are named after their file; the
+// base layer is called baseName.
+func NewLayeredRepository(base domain.Repository, baseName string, overridePaths ...string) (*LayeredRepository, error) {
+	o, err := loadOverlay(overridePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &LayeredRepository{
+		base:          base,
+		baseName:      baseName,
+		overridePaths: overridePaths,
+	}
+	repo.overlay.Store(o)
+	return repo, nil
+}
+
+// Reload re-reads every override file and reloads the base repository with
+// path (the current base source when empty). Nothing is swapped unless both
+// succeed.
+func (r *LayeredRepository) Reload(path string) error {
+	if !r.reloadMu.TryLock() {
+		return domain.ErrReloadInProgress
+	}
+	defer r.reloadMu.Unlock()
+
+	o, err := loadOverlay(r.overridePaths)
+	if err != nil {
+		return err
+	}
+
+	if reloader, ok := r.base.(domain.Reloader); ok {
+		if err := reloader.Reload(path); err != nil {
+			return err
+		}
+	}
+
+	r.overlay.Store(o)
+	return nil
+}
+
+// DatasetInfo describes the base dataset; override files are not included.
+func (r *LayeredRepository) DatasetInfo() domain.DatasetInfo {
+	if provider, ok := r.base.(domain.DatasetInfoProvider); ok {
+		return provider.DatasetInfo()
+	}
+	return domain.DatasetInfo{}
+}
+
+func (r *LayeredRepository) FindByIPID(ipID uint32) (*domain.Location, error) {
+	o := r.overlay.Load()
+	key := domain.IPv4ToIPv6ID(ipID)
+
+	idx, found := o.find(key)
+	if found {
+		seg := o.segments[idx]
+		location := o.location(seg)
+		location.LowerIPID = uint32(seg.lower.Lo)
+		location.UpperIPID = uint32(seg.upper.Lo)
+		return location, nil
+	}
+
+	base, err := r.base.FindByIPID(ipID)
+	if err != nil {
+		return nil, err
+	}
+
+	location := *base
+	location.Source = r.baseName
+	gapLower, gapUpper := o.gap(idx)
+	if lower := domain.IPv4ToIPv6ID(location.LowerIPID); lower.Less(gapLower) {
+		location.LowerIPID = uint32(gapLower.Lo)
+	}
+	if upper := domain.IPv4ToIPv6ID(location.UpperIPID); gapUpper.Less(upper) {
+		location.UpperIPID = uint32(gapUpper.Lo)
+	}
+	return &location, nil
+}
+
+// FindByIPv6ID resolves a 128-bit IP number. IPv4-mapped addresses are
+// answered like IPv4 lookups.
+func (r *LayeredRepository) FindByIPv6ID(ipID domain.IPv6ID) (*domain.Location, error) {
+	if ipv4ID, ok := ipID.IPv4(); ok {
+		return r.FindByIPID(ipv4ID)
+	}
+
+	o := r.overlay.Load()
+
+	idx, found := o.find(ipID)
+	if found {
+		seg := o.segments[idx]
+		location := o.location(seg)
+		location.LowerIPv6ID = seg.lower
+		location.UpperIPv6ID = seg.upper
+		return location, nil
+	}
+
+	base, err := r.base.FindByIPv6ID(ipID)
+	if err != nil {
+		return nil, err
+	}
+
+	location := *base
+	location.Source = r.baseName
+	gapLower, gapUpper := o.gap(idx)
+	if location.LowerIPv6ID.Less(gapLower) {
+		location.LowerIPv6ID = gapLower
+	}
+	if gapUpper.Less(location.UpperIPv6ID) {
+		location.UpperIPv6ID = gapUpper
+	}
+	return &location, nil
+}
+
+// overlay is the flattened override table: disjoint segments sorted by
+// lower bound, each pointing at the entry that won it.
+type overlay struct {
+	segments []overlaySegment
+	entries  []overlayEntry
+}
+
+type overlaySegment struct {
+	lower domain.IPv6ID
+	upper domain.IPv6ID
+	entry int
+}
+
+type overlayEntry struct {
+	layer    string
+	location domain.Location
+}
+
+var (
+	ipv4MappedLower = domain.IPv4ToIPv6ID(0)
+	ipv4MappedUpper = domain.IPv4ToIPv6ID(math.MaxUint32)
+	maxIPv6ID       = domain.IPv6ID{Hi: math.MaxUint64, Lo: math.MaxUint64}
+)
+
+// loadOverlay reads paths (highest priority first) and paints their entries
+// from the lowest priority up, so whatever is painted last wins.
+func loadOverlay(paths []string) (*overlay, error) {
+	o := &overlay{}
+
+	for i := len(paths) - 1; i >= 0; i-- {
+		entries, err := loadOverrides(paths[i])
+		if err != nil {
+			return nil, err
+		}
+
+		// Larger ranges first, so more specific ones are painted over them.
+		sort.SliceStable(entries, func(a, b int) bool {
+			return span(entries[b]).Less(span(entries[a]))
+		})
+
+		layer := filepath.Base(paths[i])
+		for _, e := range entries {
+			o.entries = append(o.entries, overlayEntry{layer: layer, location: e.location})
+			entry := len(o.entries) - 1
+
+			if _, is4 := e.lower.IPv4(); is4 {
+				o.paint(e.lower, e.upper, entry)
+				continue
+			}
+			// IPv4 lookups own ::ffff:0:0/96; an IPv6 network spanning it
+			// only covers the parts around it.
+			if e.lower.Less(ipv4MappedLower) {
+				o.paint(e.lower, minID(e.upper, ipv4MappedLower.Prev()), entry)
+			}
+			if ipv4MappedUpper.Less(e.upper) {
+				o.paint(maxID(e.lower, ipv4MappedUpper.Next()), e.upper, entry)
+			}
+		}
+	}
+
+	return o, nil
+}
+
+// paint assigns lower..upper to entry, splitting the segments it overlaps.
+func (o *overlay) paint(lower, upper domain.IPv6ID, entry int) {
+	segs := o.segments
+	first := sort.Search(len(segs), func(i int) bool {
+		return segs[i].upper.Compare(lower) >= 0
+	})
+	end := sort.Search(len(segs), func(i int) bool {
+		return upper.Less(segs[i].lower)
+	})
+
+	var replacement []overlaySegment
+	if first < end && segs[first].lower.Less(lower) {
+		replacement = append(replacement, overlaySegment{lower: segs[first].lower, upper: lower.Prev(), entry: segs[first].entry})
+	}
+	replacement = append(replacement, overlaySegment{lower: lower, upper: upper, entry: entry})
+	if first < end && upper.Less(segs[end-1].upper) {
+		replacement = append(replacement, overlaySegment{lower: upper.Next(), upper: segs[end-1].upper, entry: segs[end-1].entry})
+	}
+
+	o.segments = slices.Replace(segs, first, end, replacement...)
+}
+
+// find returns the segment containing key. When there is none, idx is the
+// first segment above key.
+func (o *overlay) find(key domain.IPv6ID) (idx int, found bool) {
+	idx = sort.Search(len(o.segments), func(i int) bool {
+		return o.segments[i].upper.Compare(key) >= 0
+	})
+	if idx < len(o.segments) && o.segments[idx].lower.Compare(key) <= 0 {
+		return idx, true
+	}
+	return idx, false
+}
+
+// gap returns the bounds of the uncovered space just below segment idx.
+func (o *overlay) gap(idx int) (lower, upper domain.IPv6ID) {
+	upper = maxIPv6ID
+	if idx > 0 {
+		lower = o.segments[idx-1].upper.Next()
+	}
+	if idx < len(o.segments) {
+		upper = o.segments[idx].lower.Prev()
+	}
+	return lower, upper
+}
+
+func (o *overlay) location(seg overlaySegment) *domain.Location {
+	e := &o.entries[seg.entry]
+	location := e.location
+	location.Source = e.layer
+	return &location
+}
+
+// span is the number of addresses in e minus one.
+func span(e overrideEntry) domain.IPv6ID {
+	lo, borrow := bits.Sub64(e.upper.Lo, e.lower.Lo, 0)
+	hi, _ := bits.Sub64(e.upper.Hi, e.lower.Hi, borrow)
+	return domain.IPv6ID{Hi: hi, Lo: lo}
+}
+
+func minID(a, b domain.IPv6ID) domain.IPv6ID {
+	if b.Less(a) {
+		return b
+	}
+	return a
+}
+
+func maxID(a, b domain.IPv6ID) domain.IPv6ID {
+	if a.Less(b) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+const layeredBaseCSV = `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"167772160","184549375","US","United States","Virginia","Ashburn","39.04372","-77.48749","20147","-04:00"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","JP","Japan","Tokyo","Tokyo","35.689497","139.692317","100-0001","+09:00"`
+
+const layeredCorpYAML = `ranges:
+  - network: 10.0.0.0/16
+    country_code: BR
+    country: Brazil
+    region: Sao Paulo
+    city: Sao Paulo
+    latitude: -23.5475
+    longitude: -46.63611
+    time_zone: "-03:00"
+  - network: 10.0.5.0/24
+    country_code: BR
+    country: Brazil
+    region: Rio de Janeiro
+    city: Rio de Janeiro
+  - network: 2001:db8::/48
+    country_code: DE
+    country: Germany
+    city: Berlin
+`
+
+const layeredLabCSV = `network,country_code,country_name,region_name,city_name,latitude,longitude,zip_code,time_zone
+10.0.5.128-10.0.5.255,PT,Portugal,Lisboa,Lisbon,38.71667,-9.13333,1000-001,+00:00
+`
+
+func newTestLayeredRepository(t *testing.T) (*LayeredRepository, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	base, err := NewMemoryRepository(write("base.csv", layeredBaseCSV))
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+
+	labPath := write("lab.csv", layeredLabCSV)
+	corpPath := write("corp.yaml", layeredCorpYAML)
+	repo, err := NewLayeredRepository(base, "base.csv", labPath, corpPath)
+	if err != nil {
+		t.Fatalf("NewLayeredRepository() error = %v", err)
+	}
+	return repo, labPath, corpPath
+}
+
+func TestLayeredRepository_FindByIPID(t *testing.T) {
+	repo, _, _ := newTestLayeredRepository(t)
+
+	tests := []struct {
+		name       string
+		ipID       uint32
+		wantCity   string
+		wantSource string
+		wantLower  uint32
+		wantUpper  uint32
+	}{
+		{name: "less specific entry of a layer", ipID: 167772160, wantCity: "Sao Paulo", wantSource: "corp.yaml", wantLower: 167772160, wantUpper: 167773439},
+		{name: "most specific entry of a layer wins", ipID: 167773440, wantCity: "Rio de Janeiro", wantSource: "corp.yaml", wantLower: 167773440, wantUpper: 167773567},
+		{name: "higher layer wins", ipID: 167773600, wantCity: "Lisbon", wantSource: "lab.csv", wantLower: 167773568, wantUpper: 167773695},
+		{name: "less specific entry resumes after", ipID: 167773696, wantCity: "Sao Paulo", wantSource: "corp.yaml", wantLower: 167773696, wantUpper: 167837695},
+		{name: "base bounds clipped to the gap", ipID: 167837696, wantCity: "Ashburn", wantSource: "base.csv", wantLower: 167837696, wantUpper: 184549375},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.FindByIPID(tt.ipID)
+			if err != nil {
+				t.Fatalf("FindByIPID() error = %v", err)
+			}
+			if got.City != tt.wantCity || got.Source != tt.wantSource {
+				t.Errorf("FindByIPID() = %s from %s, want %s from %s", got.City, got.Source, tt.wantCity, tt.wantSource)
+			}
+			if got.LowerIPID != tt.wantLower || got.UpperIPID != tt.wantUpper {
+				t.Errorf("FindByIPID() bounds = %d-%d, want %d-%d", got.LowerIPID, got.UpperIPID, tt.wantLower, tt.wantUpper)
+			}
+		})
+	}
+
+	if _, err := repo.FindByIPID(1); !errors.Is(err, domain.ErrLocationNotFound) {
+		t.Errorf("FindByIPID() outside every layer error = %v, want ErrLocationNotFound", err)
+	}
+}
+
+func TestLayeredRepository_FindByIPv6ID(t *testing.T) {
+	repo, _, _ := newTestLayeredRepository(t)
+
+	got, err := repo.FindByIPv6ID(domain.IPv6ID{Hi: 0x20010db800000000, Lo: 1})
+	if err != nil {
+		t.Fatalf("FindByIPv6ID() error = %v", err)
+	}
+	if got.City != "Berlin" || got.Source != "corp.yaml" {
+		t.Errorf("FindByIPv6ID() = %s from %s, want Berlin from corp.yaml", got.City, got.Source)
+	}
+
+	got, err = repo.FindByIPv6ID(domain.IPv6ID{Hi: 0x20010db800010000})
+	if err != nil {
+		t.Fatalf("FindByIPv6ID() error = %v", err)
+	}
+	if got.City != "Tokyo" || got.Source != "base.csv" {
+		t.Errorf("FindByIPv6ID() = %s from %s, want Tokyo from base.csv", got.City, got.Source)
+	}
+	if want := (domain.IPv6ID{Hi: 0x20010db800010000}); got.LowerIPv6ID != want {
+		t.Errorf("FindByIPv6ID() lower = %+v, want %+v", got.LowerIPv6ID, want)
+	}
+
+	got, err = repo.FindByIPv6ID(domain.IPv4ToIPv6ID(167773600))
+	if err != nil {
+		t.Fatalf("FindByIPv6ID() error = %v", err)
+	}
+	if got.City != "Lisbon" {
+		t.Errorf("FindByIPv6ID(IPv4-mapped) City = %s, want Lisbon", got.City)
+	}
+}
+
+func TestLayeredRepository_Reload(t *testing.T) {
+	repo, labPath, _ := newTestLayeredRepository(t)
+
+	updated := layeredLabCSV + "10.200.0.1,US,United States,Texas,Austin,30.26715,-97.74306,78701,-05:00\n"
+	if err := os.WriteFile(labPath, []byte(updated), 0644); err != nil {
+		t.Fatalf("Failed to update override: %v", err)
+	}
+	if err := repo.Reload(""); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got, err := repo.FindByIPID(180879361); err != nil || got.City != "Austin" {
+		t.Errorf("FindByIPID() after reload = %+v, %v, want Austin", got, err)
+	}
+
+	if err := os.WriteFile(labPath, []byte(layeredLabCSV+"not-an-ip,US,,,,0,0,,\n"), 0644); err != nil {
+		t.Fatalf("Failed to update override: %v", err)
+	}
+	if err := repo.Reload(""); err == nil {
+		t.Fatal("Reload() with a malformed override should fail")
+	}
+	if got, err := repo.FindByIPID(180879361); err != nil || got.City != "Austin" {
+		t.Errorf("FindByIPID() after failed reload = %+v, %v, want previous Austin", got, err)
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		network   string
+		wantFirst string
+		wantLast  string
+		wantErr   bool
+	}{
+		{network: "10.0.0.0/8", wantFirst: "10.0.0.0", wantLast: "10.255.255.255"},
+		{network: "10.1.2.3/8", wantFirst: "10.0.0.0", wantLast: "10.255.255.255"},
+		{network: "::ffff:10.0.0.0/104", wantFirst: "10.0.0.0", wantLast: "10.255.255.255"},
+		{network: "2001:db8::/32", wantFirst: "2001:db8::", wantLast: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{network: "192.168.1.10 - 192.168.1.20", wantFirst: "192.168.1.10", wantLast: "192.168.1.20"},
+		{network: "8.8.8.8", wantFirst: "8.8.8.8", wantLast: "8.8.8.8"},
+		{network: "192.168.1.20-192.168.1.10", wantErr: true},
+		{network: "10.0.0.1-2001:db8::", wantErr: true},
+		{network: "corp", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			first, last, err := parseNetwork(tt.network)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNetwork() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if first.String() != tt.wantFirst || last.String() != tt.wantLast {
+				t.Errorf("parseNetwork() = %s-%s, want %s-%s", first, last, tt.wantFirst, tt.wantLast)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// The load benchmarks parse a generated DB11-shaped CSV. They are slow by
+// design and meant to be run on their own:
+//
+//	go test ./internal/repository -run '^$' -bench 'Load' -benchtime 1x
+//
+// BENCH_CSV_ROWS overrides the row count (default: 3,000,000, close to the
+// full IP2Location LITE DB11 file).
+
+func benchmarkCSV(b *testing.B) string {
+	b.Helper()
+
+	rows := 3_000_000
+	if v := os.Getenv("BENCH_CSV_ROWS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			b.Fatalf("Invalid BENCH_CSV_ROWS: %v", err)
+		}
+		rows = n
+	}
+
+	path := filepath.Join(b.TempDir(), "synthetic-db11.csv")
+	if err := writeSyntheticCSV(path, rows); err != nil {
+		b.Fatalf("Failed to generate benchmark CSV: %v", err)
+	}
+	return path
+}
+
+// writeSyntheticCSV writes rows consecutive ranges spread over the IPv4 space
+// with a realistic mix of repeated countries and cities.
+func writeSyntheticCSV(path string, rows int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriterSize(file, 1<<20)
+	fmt.Fprintln(w, `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"`)
+
+	countries := []struct{ code, name string }{
+		{"US", "United States of America"},
+		{"CN", "China"},
+		{"BR", "Brazil"},
+		{"DE", "Germany"},
+		{"JP", "Japan"},
+		{"IN", "India"},
+		{"GB", "United Kingdom of Great Britain and Northern Ireland"},
+		{"FR", "France"},
+	}
+
+	step := uint64(1<<32) / uint64(rows)
+	if step == 0 {
+		step = 1
+	}
+
+	for i := 0; i < rows; i++ {
+		from := uint64(i) * step
+		to := from + step - 1
+		c := countries[i%len(countries)]
+		city := i % 200_000
+		fmt.Fprintf(w, "\"%d\",\"%d\",\"%s\",\"%s\",\"Region %d\",\"City %d\",\"%.6f\",\"%.6f\",\"%05d\",\"-03:00\"\n",
+			from, to, c.code, c.name, city%500, city, float64(city%180)-90, float64(city%360)-180, city)
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadCSVReadAll is the original loader, kept as the "before" baseline.
+func loadCSVReadAll(csvPath string) ([]domain.Location, error) {
+	file, err := os.Open(csvPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]domain.Location, 0, len(records)-1)
+	for _, record := range records[1:] {
+		lowerIPID, err := strconv.ParseUint(record[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		upperIPID, err := strconv.ParseUint(record[1], 10, 32)
+		if err != nil {
+			continue
+		}
+		latitude, _ := strconv.ParseFloat(record[6], 64)
+		longitude, _ := strconv.ParseFloat(record[7], 64)
+
+		locations = append(locations, domain.Location{
+			LowerIPID:   uint32(lowerIPID),
+			UpperIPID:   uint32(upperIPID),
+			CountryCode: record[2],
+			Country:     record[3],
+			Region:      record[4],
+			City:        record[5],
+			Latitude:    latitude,
+			Longitude:   longitude,
+			ZipCode:     record[8],
+			TimeZone:    record[9],
+		})
+	}
+	return locations, nil
+}
+
+func BenchmarkLoadCSV_ReadAll(b *testing.B) {
+	path := benchmarkCSV(b)
+
+	benchmarkLoad(b, func() (any, error) {
+		return loadCSVReadAll(path)
+	})
+}
+
+func BenchmarkLoadCSV_Streaming(b *testing.B) {
+	path := benchmarkCSV(b)
+
+	benchmarkLoad(b, func() (any, error) {
+		return NewMemoryRepository(path, WithProgressInterval(0))
+	})
+}
+
+func BenchmarkLoadCSV_StreamingWithCeiling(b *testing.B) {
+	path := benchmarkCSV(b)
+
+	benchmarkLoad(b, func() (any, error) {
+		return NewMemoryRepository(path, WithProgressInterval(0), WithMaxMemory(1<<30))
+	})
+}
+
+// benchmarkLoad reports the peak resident set size (Linux only) and the heap
+// still retained by the loaded result, next to the usual ns/op load time.
+func benchmarkLoad(b *testing.B, load func() (any, error)) {
+	b.Helper()
+
+	var peak, retained uint64
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		debug.FreeOSMemory()
+		resetPeakRSS()
+
+		result, err := load()
+		if err != nil {
+			b.Fatalf("load failed: %v", err)
+		}
+
+		peak = max(peak, peakRSS())
+
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		retained = stats.HeapAlloc
+		runtime.KeepAlive(result)
+	}
+
+	if peak > 0 {
+		b.ReportMetric(float64(peak)/(1<<20), "peak-rss-MB")
+	}
+	b.ReportMetric(float64(retained)/(1<<20), "retained-heap-MB")
+}
+
+// resetPeakRSS resets VmHWM to the current RSS (Linux >= 4.0).
+func resetPeakRSS() {
+	_ = os.WriteFile("/proc/self/clear_refs", []byte("5"), 0)
+}
+
+// peakRSS returns VmHWM in bytes, or 0 where /proc is unavailable.
+func peakRSS() uint64 {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		value, ok := strings.CutPrefix(line, "VmHWM:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseUint(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "kB")), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb << 10
+	}
+	return 0
+}
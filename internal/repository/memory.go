@@ -1,50 +1,184 @@
 package repository
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 
 	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/pkg/iputil"
+	"arena-backend-challenge/pkg/logger"
 )
 
 type MemoryRepository struct {
-	locations []domain.Location
+	csvPath  string
+	opts     options
+	data     atomic.Pointer[dataset]
+	reloadMu sync.Mutex
 }
 
-func NewMemoryRepository(csvPath string) (*MemoryRepository, error) {
-	locations, err := loadCSV(csvPath)
+func NewMemoryRepository(csvPath string, opts ...Option) (*MemoryRepository, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	data, err := loadDataset(csvPath, o)
 	if err != nil {
-		return nil, fmt.Errorf("load CSV: %w", err)
+		return nil, err
 	}
 
-	sort.Slice(locations, func(i, j int) bool {
-		return locations[i].LowerIPID < locations[j].LowerIPID
-	})
+	repo := &MemoryRepository{
+		csvPath: csvPath,
+		opts:    o,
+	}
+	repo.data.Store(data)
 
-	return &MemoryRepository{
-		locations: locations,
-	}, nil
+	return repo, nil
+}
+
+// Reload loads csvPath (or the current file when empty) in the calling
+// goroutine, validates it and atomically replaces the served dataset. On any
+// error the previous dataset keeps being served.
+func (r *MemoryRepository) Reload(csvPath string) error {
+	if !r.reloadMu.TryLock() {
+		return domain.ErrReloadInProgress
+	}
+	defer r.reloadMu.Unlock()
+
+	if csvPath == "" {
+		csvPath = r.csvPath
+	}
+
+	data, err := loadDataset(csvPath, r.opts)
+	if err != nil {
+		return err
+	}
+
+	if err := data.validate(); err != nil {
+		return fmt.Errorf("validate %s: %w", csvPath, err)
+	}
+
+	r.data.Store(data)
+	r.csvPath = csvPath
+
+	return nil
+}
+
+// Len returns the number of ranges currently served, IPv4 and IPv6 combined.
+func (r *MemoryRepository) Len() int {
+	return r.current().Len()
+}
+
+func (r *MemoryRepository) current() *dataset {
+	return r.data.Load()
 }
 
 func (r *MemoryRepository) FindByIPID(ipID uint32) (*domain.Location, error) {
-	idx := sort.Search(len(r.locations), func(i int) bool {
-		return r.locations[i].UpperIPID >= ipID
-	})
+	data := r.current()
 
-	if idx < len(r.locations) && r.locations[idx].LowerIPID <= ipID && ipID <= r.locations[idx].UpperIPID {
-		return &r.locations[idx], nil
+	if idx, ok := data.v4.find(ipID); ok {
+		return data.location4(idx), nil
 	}
 
 	return nil, fmt.Errorf("search IP ID %d: %w", ipID, domain.ErrLocationNotFound)
 }
 
-func loadCSV(csvPath string) ([]domain.Location, error) {
-	file, err := os.Open(csvPath)
+// FindByIPv6ID resolves a 128-bit IP number. IPv4-mapped addresses are
+// answered from the IPv4 table.
+func (r *MemoryRepository) FindByIPv6ID(ipID domain.IPv6ID) (*domain.Location, error) {
+	if ipv4ID, ok := ipID.IPv4(); ok {
+		return r.FindByIPID(ipv4ID)
+	}
+
+	data := r.current()
+
+	if idx, ok := data.v6.find(ipID); ok {
+		return data.location6(idx), nil
+	}
+
+	return nil, fmt.Errorf("search IPv6 ID %x%016x: %w", ipID.Hi, ipID.Lo, domain.ErrLocationNotFound)
+}
+
+// FindByIPIDRange calls yield with every IPv4 range overlapping first..last.
+// Like find, it relies on the upper bounds being sorted, which they are
+// unless the dataset has overlapping ranges.
+func (r *MemoryRepository) FindByIPIDRange(first, last uint32, yield func(*domain.Location) bool) {
+	data := r.current()
+	t := &data.v4
+
+	from := sort.Search(len(t.upper), func(i int) bool {
+		return t.upper[i] >= first
+	})
+	for i := from; i < len(t.lower) && t.lower[i] <= last; i++ {
+		if t.upper[i] >= first && !yield(data.location4(i)) {
+			return
+		}
+	}
+}
+
+// FindByIPv6IDRange calls yield with every native IPv6 range overlapping
+// first..last; IPv4-mapped addresses are only in the IPv4 table.
+func (r *MemoryRepository) FindByIPv6IDRange(first, last domain.IPv6ID, yield func(*domain.Location) bool) {
+	data := r.current()
+	t := &data.v6
+
+	from := sort.Search(len(t.upper), func(i int) bool {
+		return t.upper[i].Compare(first) >= 0
+	})
+	for i := from; i < len(t.lower) && t.lower[i].Compare(last) <= 0; i++ {
+		if t.upper[i].Compare(first) >= 0 && !yield(data.location6(i)) {
+			return
+		}
+	}
+}
+
+// ValidationReport returns the data-quality report of the dataset currently
+// served.
+func (r *MemoryRepository) ValidationReport() ValidationReport {
+	return *r.current().report
+}
+
+// DatasetInfo describes the CSV currently served.
+func (r *MemoryRepository) DatasetInfo() domain.DatasetInfo {
+	return r.current().info
+}
+
+func loadDataset(csvPath string, opts options) (*dataset, error) {
+	data, err := loadCSV(csvPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("load CSV: %w", err)
+	}
+
+	if data.report.HasIssues() {
+		logger.Warningf("Dataset validation found issues - Path: %s - %s", csvPath, data.report)
+	}
+	if opts.strict != nil {
+		if err := data.report.Check(*opts.strict); err != nil {
+			return nil, fmt.Errorf("validate %s: %w", csvPath, err)
+		}
+	}
+	return data, nil
+}
+
+// loadCSV streams an IP2Location DB11 CSV record by record. Both the IPv4
+// file (32-bit bounds) and the IPv6 file (128-bit bounds) are accepted: rows
+// that fall inside the IPv4 space, either directly or as IPv4-mapped IPv6, go
+// to the IPv4 table and native IPv6 rows go to the IPv6 table.
+//
+// Malformed rows and inverted ranges are skipped; they and every other
+// data-quality issue are recorded in the dataset's validation report.
+func loadCSV(csvPath string, opts options) (*dataset, error) {
+	file, err := openDatasetFile(csvPath)
 	if err != nil {
-		return nil, fmt.Errorf("open file %s: %w", csvPath, err)
+		return nil, err
 	}
 	defer func() {
 		if closeErr := file.Close(); closeErr != nil {
@@ -52,47 +186,168 @@ func loadCSV(csvPath string) ([]domain.Location, error) {
 		}
 	}()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("read CSV: %w", err)
-	}
+	fileSize := file.size
+	counter := &countingReader{r: file}
+	reader := csv.NewReader(bufio.NewReaderSize(counter, 1<<20))
+	reader.ReuseRecord = true
 
-	if len(records) == 0 {
-		return nil, fmt.Errorf("CSV file is empty")
+	progress := newLoadProgress(csvPath, fileSize, opts.progressInterval)
+
+	if opts.maxMemory > 0 {
+		defer setLoadMemoryLimit(opts.maxMemory)()
 	}
 
-	locations := make([]domain.Location, 0, len(records)-1)
+	var (
+		builder     = newDatasetBuilder()
+		report      = &ValidationReport{}
+		sampleBytes int64
+	)
 
-	for i, record := range records {
-		if i == 0 {
-			continue
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			if line == 0 {
+				return nil, fmt.Errorf("CSV file is empty")
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
 		}
 
-		if len(record) < 10 {
+		if line == 0 {
 			continue
 		}
 
-		lowerIPID, err := strconv.ParseUint(record[0], 10, 32)
-		if err != nil {
-			continue
+		progress.row(counter.n)
+
+		if line <= capacitySampleRows && fileSize > 0 {
+			sampleBytes += recordSize(record)
+			if line == capacitySampleRows {
+				// Size the IPv4 columns once from the average row length instead
+				// of letting append double them and briefly hold both copies.
+				estimated := int(fileSize / (sampleBytes / capacitySampleRows))
+				builder.grow(estimated + estimated/20)
+			}
 		}
 
-		upperIPID, err := strconv.ParseUint(record[1], 10, 32)
-		if err != nil {
+		report.Rows++
+
+		location, validCoordinates, ok := parseRecord(record)
+		if !ok {
+			report.SkippedLines.add(line + 1)
+			continue
+		}
+		if !validCoordinates {
+			report.InvalidCoordinates.add(line + 1)
+		}
+		if isInverted(&location) {
+			report.InvertedRanges.add(line + 1)
 			continue
 		}
+		if !validCountryCode(location.CountryCode) {
+			report.InvalidCountryCodes.add(line + 1)
+		}
+
+		if err := builder.add(&location, line+1); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line+1, err)
+		}
 
-		location := domain.Location{
-			LowerIPID:   uint32(lowerIPID),
-			UpperIPID:   uint32(upperIPID),
-			CountryCode: record[2],
-			Country:     record[3],
-			City:        record[5],
+		if opts.maxMemory > 0 {
+			if used := builder.bytes(); used > opts.maxMemory {
+				return nil, fmt.Errorf("line %d: dataset needs more than %d bytes: %w",
+					line+1, opts.maxMemory, ErrMemoryLimitExceeded)
+			}
 		}
+	}
 
-		locations = append(locations, location)
+	// The digest is complete only now, so a tampered file is parsed but
+	// never returned.
+	if opts.publicKey != nil {
+		if err := verifySignature(csvPath, file.Sum(), opts.publicKey); err != nil {
+			return nil, err
+		}
 	}
 
-	return locations, nil
+	data := builder.build()
+	data.checkRanges(report)
+	if data.v4.index, err = newIPv4Index(opts.index, &data.v4); err != nil {
+		return nil, err
+	}
+	report.Loaded = data.Len()
+	data.report = report
+	data.info = newDatasetInfo(csvPath, file.Sum(), data.Len(),
+		report.SkippedLines.Count+report.InvertedRanges.Count)
+	progress.done(counter.n, data.Len())
+
+	return data, nil
+}
+
+// parseRecord converts one DB11 row. Malformed rows are reported as !ok and
+// skipped by the caller. A row whose coordinates do not parse is still
+// loaded, at 0,0, and reported through validCoordinates: its country and city
+// are worth more than a not found.
+// The returned strings still point into the record; the dataset builder
+// copies what it keeps.
+func parseRecord(record []string) (location domain.Location, validCoordinates, ok bool) {
+	if len(record) < 10 {
+		return domain.Location{}, false, false
+	}
+
+	lowerIPID, err := parseIPNumber(record[0])
+	if err != nil {
+		return domain.Location{}, false, false
+	}
+
+	upperIPID, err := parseIPNumber(record[1])
+	if err != nil {
+		return domain.Location{}, false, false
+	}
+
+	latitude, latErr := strconv.ParseFloat(record[6], 64)
+	longitude, lonErr := strconv.ParseFloat(record[7], 64)
+	validCoordinates = latErr == nil && lonErr == nil
+	if !validCoordinates {
+		latitude, longitude = 0, 0
+	}
+
+	location = domain.Location{
+		CountryCode: record[2],
+		Country:     record[3],
+		Region:      record[4],
+		City:        record[5],
+		Latitude:    latitude,
+		Longitude:   longitude,
+		ZipCode:     record[8],
+		TimeZone:    record[9],
+	}
+
+	lower4, lowerIs4 := ipv4ID(lowerIPID)
+	upper4, upperIs4 := ipv4ID(upperIPID)
+	if lowerIs4 && upperIs4 {
+		location.LowerIPID = lower4
+		location.UpperIPID = upper4
+		return location, validCoordinates, true
+	}
+
+	location.LowerIPv6ID = lowerIPID
+	location.UpperIPv6ID = upperIPID
+	return location, validCoordinates, true
+}
+
+func parseIPNumber(s string) (domain.IPv6ID, error) {
+	hi, lo, err := iputil.ParseDecimal128(s)
+	if err != nil {
+		return domain.IPv6ID{}, err
+	}
+	return domain.IPv6ID{Hi: hi, Lo: lo}, nil
+}
+
+// ipv4ID reports the IPv4 ID of a row bound taken from either the IPv4 file
+// (plain 32-bit number) or the IPv6 file (IPv4-mapped address).
+func ipv4ID(id domain.IPv6ID) (uint32, bool) {
+	if id.Hi == 0 && id.Lo <= math.MaxUint32 {
+		return uint32(id.Lo), true
+	}
+	return id.IPv4()
 }
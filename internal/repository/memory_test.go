@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"arena-backend-challenge/internal/domain"
@@ -46,6 +47,14 @@ func TestNewMemoryRepository(t *testing.T) {
 			wantErr:   false,
 			wantCount: 2,
 		},
+		{
+			name: "CSV with invalid coordinates (loaded)",
+			csvData: `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777472","16778239","CN","China","Fujian","Fuzhou","north","119.30611","-","08:00"`,
+			wantErr:   false,
+			wantCount: 2,
+		},
 	}
 
 	for _, tt := range tests {
@@ -68,8 +77,8 @@ func TestNewMemoryRepository(t *testing.T) {
 			}
 
 			if !tt.wantErr && repo != nil {
-				if len(repo.locations) != tt.wantCount {
-					t.Errorf("NewMemoryRepository() loaded %d locations, want %d", len(repo.locations), tt.wantCount)
+				if repo.current().v4.Len() != tt.wantCount {
+					t.Errorf("NewMemoryRepository() loaded %d locations, want %d", repo.current().v4.Len(), tt.wantCount)
 				}
 			}
 		})
@@ -196,6 +205,354 @@ func TestMemoryRepository_FindByIPID(t *testing.T) {
 	}
 }
 
+func TestMemoryRepository_FindByIPID_AllColumns(t *testing.T) {
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+
+	tmpFile, err := createTempCSV(csvData)
+	if err != nil {
+		t.Fatalf("Failed to create temp CSV: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tmpFile); err != nil {
+			t.Logf("Warning: failed to remove temp file: %v", err)
+		}
+	}()
+
+	repo, err := NewMemoryRepository(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	got, err := repo.FindByIPID(134744072)
+	if err != nil {
+		t.Fatalf("FindByIPID() error = %v", err)
+	}
+
+	want := domain.Location{
+		LowerIPID:   134744072,
+		UpperIPID:   134744072,
+		Country:     "United States",
+		CountryCode: "US",
+		Region:      "California",
+		City:        "Mountain View",
+		Latitude:    37.405992,
+		Longitude:   -122.078515,
+		ZipCode:     "94035",
+		TimeZone:    "-07:00",
+	}
+	if *got != want {
+		t.Errorf("FindByIPID() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestMemoryRepository_FindByIPv6ID(t *testing.T) {
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"0","281470681743359","-","-","-","-","0.000000","0.000000","-","-"
+"281470698520576","281470698520831","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"281470816487432","281470816487432","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","JP","Japan","Tokyo","Tokyo","35.689497","139.692317","100-0001","+09:00"
+"42541956101370907050197289607612071936","42541956180599069564461627201156022271","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+
+	tmpFile, err := createTempCSV(csvData)
+	if err != nil {
+		t.Fatalf("Failed to create temp CSV: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tmpFile); err != nil {
+			t.Logf("Warning: failed to remove temp file: %v", err)
+		}
+	}()
+
+	repo, err := NewMemoryRepository(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	if repo.current().v4.Len() != 2 {
+		t.Errorf("IPv4 table has %d locations, want 2", repo.current().v4.Len())
+	}
+	if repo.current().v6.Len() != 3 {
+		t.Errorf("IPv6 table has %d locations, want 3", repo.current().v6.Len())
+	}
+
+	tests := []struct {
+		name        string
+		ipID        domain.IPv6ID
+		wantCountry string
+		wantCity    string
+		wantErr     bool
+	}{
+		{
+			name:        "2001:db8::1",
+			ipID:        domain.IPv6ID{Hi: 0x20010db800000000, Lo: 1},
+			wantCountry: "Japan",
+			wantCity:    "Tokyo",
+		},
+		{
+			name:        "upper bound of 2001:4860::/32",
+			ipID:        domain.IPv6ID{Hi: 0x20014860ffffffff, Lo: 0xffffffffffffffff},
+			wantCountry: "United States",
+			wantCity:    "Mountain View",
+		},
+		{
+			name:        "IPv4-mapped ::ffff:1.0.0.1 uses the IPv4 table",
+			ipID:        domain.IPv4ToIPv6ID(16777217),
+			wantCountry: "United States",
+			wantCity:    "Los Angeles",
+		},
+		{
+			name:        "reserved range below the mapped block",
+			ipID:        domain.IPv6ID{Lo: 1},
+			wantCountry: "-",
+			wantCity:    "-",
+		},
+		{
+			name:    "IPv4-mapped address outside any range",
+			ipID:    domain.IPv4ToIPv6ID(1000),
+			wantErr: true,
+		},
+		{
+			name:    "gap between IPv6 ranges",
+			ipID:    domain.IPv6ID{Hi: 0x2001100000000000},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.FindByIPv6ID(tt.ipID)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FindByIPv6ID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, domain.ErrLocationNotFound) {
+				t.Errorf("FindByIPv6ID() error should wrap domain.ErrLocationNotFound, got %v", err)
+				return
+			}
+
+			if !tt.wantErr && got != nil {
+				if got.Country != tt.wantCountry {
+					t.Errorf("FindByIPv6ID() Country = %v, want %v", got.Country, tt.wantCountry)
+				}
+				if got.City != tt.wantCity {
+					t.Errorf("FindByIPv6ID() City = %v, want %v", got.City, tt.wantCity)
+				}
+			}
+		})
+	}
+
+	// The mapped rows must also answer plain IPv4 lookups.
+	got, err := repo.FindByIPID(134744072)
+	if err != nil {
+		t.Fatalf("FindByIPID(8.8.8.8) error = %v", err)
+	}
+	if got.City != "Mountain View" {
+		t.Errorf("FindByIPID(8.8.8.8) City = %v, want Mountain View", got.City)
+	}
+}
+
+func TestMemoryRepository_Reload(t *testing.T) {
+	header := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"`
+	oldData := header + `
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+	newData := header + `
+"134744072","134744072","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777216","16777471","AU","Australia","Queensland","Brisbane","-27.46794","153.02809","4000","10:00"`
+
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(csvPath, []byte(oldData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	repo, err := NewMemoryRepository(csvPath)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	before, err := repo.FindByIPID(134744072)
+	if err != nil {
+		t.Fatalf("FindByIPID() error = %v", err)
+	}
+
+	t.Run("new file is swapped in", func(t *testing.T) {
+		if err := os.WriteFile(csvPath, []byte(newData), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+
+		if err := repo.Reload(""); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+
+		got, err := repo.FindByIPID(134744072)
+		if err != nil {
+			t.Fatalf("FindByIPID() after reload error = %v", err)
+		}
+		if got.City != "Los Angeles" {
+			t.Errorf("FindByIPID() after reload City = %v, want Los Angeles", got.City)
+		}
+		if repo.Len() != 2 {
+			t.Errorf("Len() after reload = %d, want 2", repo.Len())
+		}
+
+		// Results handed out before the swap still point at the old snapshot.
+		if before.City != "Mountain View" {
+			t.Errorf("old result City = %v, want Mountain View", before.City)
+		}
+	})
+
+	t.Run("invalid file keeps the current dataset", func(t *testing.T) {
+		emptyPath := filepath.Join(dir, "empty.csv")
+		if err := os.WriteFile(emptyPath, []byte(header), 0644); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+
+		if err := repo.Reload(emptyPath); err == nil {
+			t.Fatal("Reload() with an empty dataset should fail")
+		}
+		if err := repo.Reload(filepath.Join(dir, "missing.csv")); err == nil {
+			t.Fatal("Reload() with a missing file should fail")
+		}
+
+		if repo.Len() != 2 {
+			t.Errorf("Len() after failed reload = %d, want 2", repo.Len())
+		}
+	})
+
+	t.Run("lookups run concurrently with reloads", func(t *testing.T) {
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if _, err := repo.FindByIPID(134744072); err != nil {
+						t.Errorf("FindByIPID() during reload error = %v", err)
+						return
+					}
+				}
+			}()
+		}
+
+		for i := 0; i < 5; i++ {
+			if err := repo.Reload(""); err != nil && !errors.Is(err, domain.ErrReloadInProgress) {
+				t.Errorf("Reload() error = %v", err)
+			}
+		}
+
+		close(stop)
+		wg.Wait()
+	})
+}
+
+func TestNewMemoryRepository_DeduplicatesAttributes(t *testing.T) {
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16778240","16779263","AU","Australia","Queensland","Brisbane","-27.46794","153.02809","4000","10:00"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777472","16777727","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777728","16778239","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"`
+
+	tmpFile, err := createTempCSV(csvData)
+	if err != nil {
+		t.Fatalf("Failed to create temp CSV: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tmpFile); err != nil {
+			t.Logf("Warning: failed to remove temp file: %v", err)
+		}
+	}()
+
+	repo, err := NewMemoryRepository(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	data := repo.current()
+	if data.v4.Len() != 4 {
+		t.Errorf("IPv4 table has %d rows, want 4", data.v4.Len())
+	}
+	if len(data.countries) != 2 {
+		t.Errorf("country table has %d entries, want 2", len(data.countries))
+	}
+	if len(data.cities) != 3 {
+		t.Errorf("city table has %d entries, want 3", len(data.cities))
+	}
+
+	// Unsorted input is sorted together with its attribute indexes.
+	for i := 1; i < data.v4.Len(); i++ {
+		if data.v4.lower[i-1] > data.v4.lower[i] {
+			t.Fatalf("IPv4 table not sorted at row %d", i)
+		}
+	}
+
+	got, err := repo.FindByIPID(16778300)
+	if err != nil {
+		t.Fatalf("FindByIPID() error = %v", err)
+	}
+	if got.City != "Brisbane" || got.CountryCode != "AU" || got.LowerIPID != 16778240 {
+		t.Errorf("FindByIPID() = %+v, want Brisbane/AU from 16778240", got)
+	}
+}
+
+func TestNewMemoryRepository_MaxMemory(t *testing.T) {
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777472","16778239","CN","China","Fujian","Fuzhou","26.06139","119.30611","-","08:00"
+"16778240","16779263","AU","Australia","Queensland","Brisbane","-27.46794","153.02809","4000","10:00"`
+
+	tmpFile, err := createTempCSV(csvData)
+	if err != nil {
+		t.Fatalf("Failed to create temp CSV: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tmpFile); err != nil {
+			t.Logf("Warning: failed to remove temp file: %v", err)
+		}
+	}()
+
+	if _, err := NewMemoryRepository(tmpFile, WithMaxMemory(64)); !errors.Is(err, ErrMemoryLimitExceeded) {
+		t.Errorf("NewMemoryRepository() with tiny limit error = %v, want ErrMemoryLimitExceeded", err)
+	}
+
+	repo, err := NewMemoryRepository(tmpFile, WithMaxMemory(1<<20), WithProgressInterval(1))
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() with 1MB limit error = %v", err)
+	}
+	if repo.Len() != 3 {
+		t.Errorf("NewMemoryRepository() loaded %d locations, want 3", repo.Len())
+	}
+}
+
+func TestNewMemoryRepository_MalformedCSV(t *testing.T) {
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777472","16778239","CN","China","Fujian","Fuz"hou","26.06139","119.30611","-","08:00"`
+
+	tmpFile, err := createTempCSV(csvData)
+	if err != nil {
+		t.Fatalf("Failed to create temp CSV: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tmpFile); err != nil {
+			t.Logf("Warning: failed to remove temp file: %v", err)
+		}
+	}()
+
+	if _, err := NewMemoryRepository(tmpFile); err == nil {
+		t.Error("NewMemoryRepository() with a broken quote should fail")
+	}
+}
+
 func BenchmarkMemoryRepository_FindByIPID(b *testing.B) {
 	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
 "16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
@@ -238,3 +595,62 @@ func createTempCSV(content string) (string, error) {
 
 	return tmpFile, nil
 }
+
+func TestMemoryRepository_FindByIPIDRange(t *testing.T) {
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777472","16778239","CN","China","Fujian","Fuzhou","26.06139","119.30611","-","08:00"
+"16779264","16781311","AU","Australia","Victoria","Melbourne","-37.814007","144.963171","3000","+10:00"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","JP","Japan","Tokyo","Tokyo","35.689497","139.692317","100-0001","+09:00"`
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	repo, err := NewMemoryRepository(path)
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		first, last uint32
+		limit       int
+		want        []string
+	}{
+		{"inside one range", 16777300, 16777310, 0, []string{"US"}},
+		{"across ranges and a gap", 16777400, 16780000, 0, []string{"US", "CN", "AU"}},
+		{"gap only", 16778240, 16779263, 0, nil},
+		{"before every range", 0, 16777215, 0, nil},
+		{"stops when yield does", 0, 16781311, 2, []string{"US", "CN"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			repo.FindByIPIDRange(tt.first, tt.last, func(location *domain.Location) bool {
+				got = append(got, location.CountryCode)
+				return tt.limit == 0 || len(got) < tt.limit
+			})
+			if len(got) != len(tt.want) {
+				t.Fatalf("FindByIPIDRange() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("FindByIPIDRange() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	var got []string
+	first := domain.IPv6ID{Hi: 0x2001_0db8_0000_0000}
+	last := domain.IPv6ID{Hi: 0x2001_0db8_ffff_ffff, Lo: ^uint64(0)}
+	repo.FindByIPv6IDRange(first, last, func(location *domain.Location) bool {
+		got = append(got, location.CountryCode)
+		return true
+	})
+	if len(got) != 1 || got[0] != "JP" {
+		t.Errorf("FindByIPv6IDRange() = %v, want [JP]", got)
+	}
+}
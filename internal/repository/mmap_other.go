@@ -0,0 +1,24 @@
+//go:build !unix
+
+package repository
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile falls back to reading the whole file where mmap is unavailable.
+func mmapFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("file is empty")
+	}
+	return data, nil
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
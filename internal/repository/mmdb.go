@@ -0,0 +1,376 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"sync"
+	"time"
+
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/pkg/iputil"
+	"arena-backend-challenge/pkg/mmdb"
+)
+
+// MMDBRepository serves lookups from a MaxMind DB file (GeoLite2/GeoIP2 City
+// or Country) mapped into memory. Records are decoded on every lookup, so
+// only the English names are kept.
+type MMDBRepository struct {
+	// path is only used by Reload, under reloadMu.
+	path     string
+	reloadMu sync.Mutex
+
+	mu     sync.RWMutex
+	data   []byte
+	reader *mmdb.Reader
+	info   domain.DatasetInfo
+}
+
+func NewMMDBRepository(path string) (*MMDBRepository, error) {
+	data, reader, err := openMMDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MMDBRepository{
+		path:   path,
+		data:   data,
+		reader: reader,
+		info:   mmdbInfo(path, data, reader),
+	}, nil
+}
+
+// Reload maps path (or the current file when empty), parses its metadata and
+// swaps it in. The previous mapping is released once no lookup is using it.
+func (r *MMDBRepository) Reload(path string) error {
+	if !r.reloadMu.TryLock() {
+		return domain.ErrReloadInProgress
+	}
+	defer r.reloadMu.Unlock()
+
+	if path == "" {
+		path = r.path
+	}
+
+	data, reader, err := openMMDB(path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	old := r.data
+	r.data = data
+	r.reader = reader
+	r.info = mmdbInfo(path, data, reader)
+	r.mu.Unlock()
+	r.path = path
+
+	return munmapFile(old)
+}
+
+// Close unmaps the database. The repository must not be used afterwards.
+func (r *MMDBRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.data == nil {
+		return nil
+	}
+	err := munmapFile(r.data)
+	r.data = nil
+	r.reader = nil
+	return err
+}
+
+// Metadata returns the metadata of the database currently served.
+func (r *MMDBRepository) Metadata() mmdb.Metadata {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.reader.Metadata
+}
+
+func (r *MMDBRepository) FindByIPID(ipID uint32) (*domain.Location, error) {
+	location, err := r.lookup(ipv4Addr(ipID))
+	if err != nil {
+		return nil, fmt.Errorf("search IP ID %d: %w", ipID, err)
+	}
+	return location, nil
+}
+
+// FindByIPv6ID resolves a 128-bit IP number. IPv4-mapped addresses are
+// answered from the IPv4 subtree.
+func (r *MMDBRepository) FindByIPv6ID(ipID domain.IPv6ID) (*domain.Location, error) {
+	if ipv4ID, ok := ipID.IPv4(); ok {
+		return r.FindByIPID(ipv4ID)
+	}
+
+	location, err := r.lookup(ipv6Addr(ipID))
+	if err != nil {
+		return nil, fmt.Errorf("search IPv6 ID %x%016x: %w", ipID.Hi, ipID.Lo, err)
+	}
+	return location, nil
+}
+
+func (r *MMDBRepository) lookup(ip netip.Addr) (*domain.Location, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if ip.Is6() && r.reader.Metadata.IPVersion == 4 {
+		return nil, domain.ErrLocationNotFound
+	}
+
+	result, err := r.reader.Lookup(ip)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Found {
+		return nil, domain.ErrLocationNotFound
+	}
+
+	record, ok := result.Record.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: record is %T, not a map", mmdb.ErrInvalidDatabase, result.Record)
+	}
+
+	location := mmdbLocation(record)
+	setNetworkBounds(location, result.Network)
+	return location, nil
+}
+
+// DatasetInfo describes the database currently mapped. MMDB files have no
+// row count.
+func (r *MMDBRepository) DatasetInfo() domain.DatasetInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.info
+}
+
+// mmdbInfo falls back to the build date in the metadata when neither a
+// sidecar nor the file name gives a version.
+func mmdbInfo(path string, data []byte, reader *mmdb.Reader) domain.DatasetInfo {
+	sum := sha256.Sum256(data)
+	info := newDatasetInfo(path, sum[:], 0, 0)
+	if info.Version == "" && reader.Metadata.BuildEpoch > 0 {
+		info.Version = time.Unix(int64(reader.Metadata.BuildEpoch), 0).UTC().Format(time.DateOnly)
+	}
+	return info
+}
+
+func openMMDB(path string) ([]byte, *mmdb.Reader, error) {
+	data, err := mmapFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("map MMDB %s: %w", path, err)
+	}
+
+	reader, err := mmdb.FromBytes(data)
+	if err != nil {
+		_ = munmapFile(data)
+		return nil, nil, fmt.Errorf("open MMDB %s: %w", path, err)
+	}
+	return data, reader, nil
+}
+
+// mmdbLocation maps the GeoIP2 City/Country record layout onto a Location.
+// Fields a database does not carry (e.g. city in a Country database) stay
+// empty.
+func mmdbLocation(record map[string]any) *domain.Location {
+	country, _ := record["country"].(map[string]any)
+	if country == nil {
+		// Anonymous proxies and satellite providers only have the country
+		// the block is registered to.
+		country, _ = record["registered_country"].(map[string]any)
+	}
+
+	var subdivision map[string]any
+	if subdivisions, ok := record["subdivisions"].([]any); ok && len(subdivisions) > 0 {
+		subdivision, _ = subdivisions[0].(map[string]any)
+	}
+
+	city, _ := record["city"].(map[string]any)
+	location, _ := record["location"].(map[string]any)
+	postal, _ := record["postal"].(map[string]any)
+
+	return &domain.Location{
+		Country:     englishName(country),
+		CountryCode: stringField(country, "iso_code"),
+		Region:      englishName(subdivision),
+		City:        englishName(city),
+		Latitude:    floatField(location, "latitude"),
+		Longitude:   floatField(location, "longitude"),
+		ZipCode:     stringField(postal, "code"),
+		TimeZone:    stringField(location, "time_zone"),
+	}
+}
+
+// mmdbRecord is the inverse of mmdbLocation: it lays a location out as a
+// GeoIP2 City record with English names. Empty fields are left out.
+func mmdbRecord(location *domain.Location) map[string]any {
+	record := map[string]any{
+		"location": map[string]any{
+			"latitude":  location.Latitude,
+			"longitude": location.Longitude,
+		},
+	}
+
+	if location.Country != "" || location.CountryCode != "" {
+		country := map[string]any{}
+		if location.CountryCode != "" {
+			country["iso_code"] = location.CountryCode
+		}
+		if location.Country != "" {
+			country["names"] = map[string]string{"en": location.Country}
+		}
+		record["country"] = country
+	}
+	if location.Region != "" {
+		record["subdivisions"] = []any{
+			map[string]any{"names": map[string]string{"en": location.Region}},
+		}
+	}
+	if location.City != "" {
+		record["city"] = map[string]any{"names": map[string]string{"en": location.City}}
+	}
+	if location.ZipCode != "" {
+		record["postal"] = map[string]string{"code": location.ZipCode}
+	}
+	if location.TimeZone != "" {
+		record["location"].(map[string]any)["time_zone"] = location.TimeZone
+	}
+
+	return record
+}
+
+// WriteMMDB exports the dataset currently served by repo as a MaxMind DB.
+// Every range is split into CIDR prefixes; rows that share their country and
+// city attributes share one record in the data section.
+func WriteMMDB(w io.Writer, repo *MemoryRepository, options mmdb.WriterOptions) error {
+	return repo.current().writeMMDB(w, options)
+}
+
+func (d *dataset) writeMMDB(w io.Writer, options mmdb.WriterOptions) error {
+	writer := mmdb.NewWriter(options)
+
+	// Encoding each attribute pair once instead of once per prefix is what
+	// keeps exporting millions of ranges fast.
+	records := make(map[[2]uint32]mmdb.DataRecord)
+	insert := func(first, last netip.Addr, countryIdx uint16, cityIdx uint32) error {
+		key := [2]uint32{uint32(countryIdx), cityIdx}
+		record, ok := records[key]
+		if !ok {
+			var err error
+			record, err = writer.AddRecord(mmdbRecord(d.attributes(countryIdx, cityIdx)))
+			if err != nil {
+				return err
+			}
+			records[key] = record
+		}
+
+		prefixes, err := iputil.RangeToPrefixes(first, last)
+		if err != nil {
+			return err
+		}
+		for _, prefix := range prefixes {
+			if err := writer.InsertRecord(prefix, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := range d.v4.Len() {
+		if err := insert(ipv4Addr(d.v4.lower[i]), ipv4Addr(d.v4.upper[i]), d.v4.country[i], d.v4.city[i]); err != nil {
+			return fmt.Errorf("IPv4 range %d: %w", i, err)
+		}
+	}
+	for i := range d.v6.Len() {
+		// ::/96 holds the IPv4 subtree, so IPv6 rows covering it (the
+		// reserved "-" row of the IP2Location IPv6 file) are clipped.
+		lower, upper := d.v6.lower[i], d.v6.upper[i]
+		if upper.Compare(ipv4SubtreeEnd) <= 0 {
+			continue
+		}
+		if lower.Compare(ipv4SubtreeEnd) <= 0 {
+			lower = domain.IPv6ID{Lo: ipv4SubtreeEnd.Lo + 1}
+		}
+
+		if err := insert(ipv6Addr(lower), ipv6Addr(upper), d.v6.country[i], d.v6.city[i]); err != nil {
+			return fmt.Errorf("IPv6 range %d: %w", i, err)
+		}
+	}
+
+	if _, err := writer.WriteTo(w); err != nil {
+		return fmt.Errorf("write MMDB: %w", err)
+	}
+	return nil
+}
+
+// ipv4SubtreeEnd is the last address of ::/96.
+var ipv4SubtreeEnd = domain.IPv6ID{Lo: math.MaxUint32}
+
+func ipv4Addr(ipID uint32) netip.Addr {
+	var raw [4]byte
+	binary.BigEndian.PutUint32(raw[:], ipID)
+	return netip.AddrFrom4(raw)
+}
+
+func ipv6Addr(ipID domain.IPv6ID) netip.Addr {
+	var raw [16]byte
+	binary.BigEndian.PutUint64(raw[:8], ipID.Hi)
+	binary.BigEndian.PutUint64(raw[8:], ipID.Lo)
+	return netip.AddrFrom16(raw)
+}
+
+func englishName(m map[string]any) string {
+	names, _ := m["names"].(map[string]any)
+	return stringField(names, "en")
+}
+
+func stringField(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func floatField(m map[string]any, key string) float64 {
+	switch v := m[key].(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	}
+	return 0
+}
+
+// setNetworkBounds fills the range bounds of location from the tree prefix
+// the address was found in.
+func setNetworkBounds(location *domain.Location, network netip.Prefix) {
+	network = network.Masked()
+	hostBits := network.Addr().BitLen() - network.Bits()
+
+	if network.Addr().Is4() {
+		lower := binary.BigEndian.Uint32(network.Addr().AsSlice())
+		location.LowerIPID = lower
+		location.UpperIPID = lower | uint32(uint64(1)<<hostBits-1)
+		return
+	}
+
+	raw := network.Addr().As16()
+	lower := domain.IPv6ID{
+		Hi: binary.BigEndian.Uint64(raw[:8]),
+		Lo: binary.BigEndian.Uint64(raw[8:]),
+	}
+	upper := lower
+	switch {
+	case hostBits >= 64:
+		upper.Lo = ^uint64(0)
+		upper.Hi |= uint64(1)<<(hostBits-64) - 1
+	default:
+		upper.Lo |= uint64(1)<<hostBits - 1
+	}
+	location.LowerIPv6ID = lower
+	location.UpperIPv6ID = upper
+}
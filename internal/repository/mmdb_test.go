@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/pkg/mmdb"
+)
+
+func TestMMDBLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		record map[string]any
+		want   domain.Location
+	}{
+		{
+			name: "city record",
+			record: map[string]any{
+				"country": map[string]any{
+					"iso_code": "US",
+					"names":    map[string]any{"en": "United States", "de": "USA"},
+				},
+				"subdivisions": []any{
+					map[string]any{"iso_code": "CA", "names": map[string]any{"en": "California"}},
+				},
+				"city":     map[string]any{"names": map[string]any{"en": "Mountain View"}},
+				"postal":   map[string]any{"code": "94043"},
+				"location": map[string]any{"latitude": 37.386, "longitude": -122.0838, "time_zone": "America/Los_Angeles"},
+			},
+			want: domain.Location{
+				Country:     "United States",
+				CountryCode: "US",
+				Region:      "California",
+				City:        "Mountain View",
+				Latitude:    37.386,
+				Longitude:   -122.0838,
+				ZipCode:     "94043",
+				TimeZone:    "America/Los_Angeles",
+			},
+		},
+		{
+			name: "country only falls back to registered country",
+			record: map[string]any{
+				"registered_country": map[string]any{
+					"iso_code": "DE",
+					"names":    map[string]any{"en": "Germany"},
+				},
+			},
+			want: domain.Location{Country: "Germany", CountryCode: "DE"},
+		},
+		{
+			name:   "empty record",
+			record: map[string]any{},
+			want:   domain.Location{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mmdbLocation(tt.record); *got != tt.want {
+				t.Errorf("mmdbLocation() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetNetworkBounds(t *testing.T) {
+	tests := []struct {
+		network   string
+		wantLower domain.IPv6ID
+		wantUpper domain.IPv6ID
+		wantIPv4  [2]uint32
+	}{
+		{network: "8.8.8.0/24", wantIPv4: [2]uint32{134744064, 134744319}},
+		{network: "0.0.0.0/0", wantIPv4: [2]uint32{0, 4294967295}},
+		{
+			network:   "2001:db8::/32",
+			wantLower: domain.IPv6ID{Hi: 0x20010db800000000},
+			wantUpper: domain.IPv6ID{Hi: 0x20010db8ffffffff, Lo: ^uint64(0)},
+		},
+		{
+			network:   "2001:db8::/64",
+			wantLower: domain.IPv6ID{Hi: 0x20010db800000000},
+			wantUpper: domain.IPv6ID{Hi: 0x20010db800000000, Lo: ^uint64(0)},
+		},
+		{
+			network:   "2001:db8::/120",
+			wantLower: domain.IPv6ID{Hi: 0x20010db800000000},
+			wantUpper: domain.IPv6ID{Hi: 0x20010db800000000, Lo: 0xff},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			var location domain.Location
+			setNetworkBounds(&location, netip.MustParsePrefix(tt.network))
+
+			if location.LowerIPID != tt.wantIPv4[0] || location.UpperIPID != tt.wantIPv4[1] {
+				t.Errorf("IPv4 bounds = %d-%d, want %d-%d",
+					location.LowerIPID, location.UpperIPID, tt.wantIPv4[0], tt.wantIPv4[1])
+			}
+			if location.LowerIPv6ID != tt.wantLower || location.UpperIPv6ID != tt.wantUpper {
+				t.Errorf("IPv6 bounds = %+v-%+v, want %+v-%+v",
+					location.LowerIPv6ID, location.UpperIPv6ID, tt.wantLower, tt.wantUpper)
+			}
+		})
+	}
+}
+
+func TestNewMMDBRepository_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+	if err := os.WriteFile(path, []byte("not,an,mmdb\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if _, err := NewMMDBRepository(path); err == nil {
+		t.Fatal("NewMMDBRepository() expected error for a CSV file")
+	}
+}
+
+func TestWriteMMDB_MatchesMemoryRepository(t *testing.T) {
+	dir := t.TempDir()
+	memRepo, _ := writeTestSnapshot(t, dir)
+
+	var buf bytes.Buffer
+	if err := WriteMMDB(&buf, memRepo, mmdb.WriterOptions{DatabaseType: "IP2Location-DB11", BuildEpoch: 1727740800}); err != nil {
+		t.Fatalf("WriteMMDB() error = %v", err)
+	}
+
+	path := filepath.Join(dir, "data.mmdb")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write MMDB: %v", err)
+	}
+
+	mmdbRepo, err := NewMMDBRepository(path)
+	if err != nil {
+		t.Fatalf("NewMMDBRepository() error = %v", err)
+	}
+	defer func() { _ = mmdbRepo.Close() }()
+
+	if got := mmdbRepo.Metadata().DatabaseType; got != "IP2Location-DB11" {
+		t.Errorf("DatabaseType = %q, want IP2Location-DB11", got)
+	}
+	if got := mmdbRepo.DatasetInfo().Version; got != "2024-10-01" {
+		t.Errorf("DatasetInfo().Version = %q, want the build date 2024-10-01", got)
+	}
+
+	// Bounds differ by design (MMDB reports the matching prefix), so only the
+	// attributes are compared.
+	attributes := func(l *domain.Location) domain.Location {
+		a := *l
+		a.LowerIPID, a.UpperIPID = 0, 0
+		a.LowerIPv6ID, a.UpperIPv6ID = domain.IPv6ID{}, domain.IPv6ID{}
+		return a
+	}
+
+	for _, ipID := range []uint32{0, 16777216, 16777300, 16777471, 16777472, 16778239, 16778240, 134744072, 134744073} {
+		want, wantErr := memRepo.FindByIPID(ipID)
+		got, gotErr := mmdbRepo.FindByIPID(ipID)
+
+		if (wantErr != nil) != (gotErr != nil) {
+			t.Errorf("FindByIPID(%d) error = %v, memory repository error = %v", ipID, gotErr, wantErr)
+			continue
+		}
+		if gotErr == nil && attributes(got) != attributes(want) {
+			t.Errorf("FindByIPID(%d) = %+v, want %+v", ipID, *got, *want)
+		}
+	}
+
+	for _, ipID := range []domain.IPv6ID{
+		{Hi: 0x20010db800000000, Lo: 1},
+		{Hi: 0x20010db9ffffffff},
+		{Hi: 0x20010dba00000000},
+		{Lo: 1 << 40},
+	} {
+		want, wantErr := memRepo.FindByIPv6ID(ipID)
+		got, gotErr := mmdbRepo.FindByIPv6ID(ipID)
+
+		if (wantErr != nil) != (gotErr != nil) {
+			t.Errorf("FindByIPv6ID(%v) error = %v, memory repository error = %v", ipID, gotErr, wantErr)
+			continue
+		}
+		if gotErr == nil && attributes(got) != attributes(want) {
+			t.Errorf("FindByIPv6ID(%v) = %+v, want %+v", ipID, *got, *want)
+		}
+	}
+}
+
+func TestMMDBRepository_Reload(t *testing.T) {
+	dir := t.TempDir()
+	memRepo, _ := writeTestSnapshot(t, dir)
+
+	var buf bytes.Buffer
+	if err := WriteMMDB(&buf, memRepo, mmdb.WriterOptions{DatabaseType: "IP2Location-DB11"}); err != nil {
+		t.Fatalf("WriteMMDB() error = %v", err)
+	}
+	path := filepath.Join(dir, "data.mmdb")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write MMDB: %v", err)
+	}
+
+	repo, err := NewMMDBRepository(path)
+	if err != nil {
+		t.Fatalf("NewMMDBRepository() error = %v", err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	if err := repo.Reload(filepath.Join(dir, "data.csv")); err == nil {
+		t.Error("Reload() with a CSV file should fail")
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Reload(""); err != nil && !errors.Is(err, domain.ErrReloadInProgress) {
+				t.Errorf("concurrent Reload() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := repo.FindByIPID(134744072)
+	if err != nil {
+		t.Fatalf("FindByIPID() after reload error = %v", err)
+	}
+	if got.City != "Mountain View" {
+		t.Errorf("FindByIPID() after reload City = %v, want Mountain View", got.City)
+	}
+}
+
+func TestMMDBRecord_RoundTrip(t *testing.T) {
+	want := domain.Location{
+		Country:     "United States",
+		CountryCode: "US",
+		Region:      "California",
+		City:        "Mountain View",
+		Latitude:    37.405992,
+		Longitude:   -122.078515,
+		ZipCode:     "94035",
+		TimeZone:    "-07:00",
+	}
+
+	w := mmdb.NewWriter(mmdb.WriterOptions{})
+	if err := w.Insert(netip.MustParsePrefix("8.8.8.0/24"), mmdbRecord(&want)); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	reader, err := mmdb.FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("FromBytes() error = %v", err)
+	}
+	result, err := reader.Lookup(netip.MustParseAddr("8.8.8.8"))
+	if err != nil || !result.Found {
+		t.Fatalf("Lookup() = %+v, %v", result, err)
+	}
+
+	if got := mmdbLocation(result.Record.(map[string]any)); *got != want {
+		t.Errorf("mmdbLocation() = %+v, want %+v", *got, want)
+	}
+}
@@ -3,7 +3,8 @@ package repository
 import "arena-backend-challenge/internal/domain"
 
 type MockRepository struct {
-	FindByIPIDFunc func(ipID uint32) (*domain.Location, error)
+	FindByIPIDFunc   func(ipID uint32) (*domain.Location, error)
+	FindByIPv6IDFunc func(ipID domain.IPv6ID) (*domain.Location, error)
 }
 
 func (m *MockRepository) FindByIPID(ipID uint32) (*domain.Location, error) {
@@ -12,3 +13,72 @@ func (m *MockRepository) FindByIPID(ipID uint32) (*domain.Location, error) {
 	}
 	return nil, domain.ErrLocationNotFound
 }
+
+func (m *MockRepository) FindByIPv6ID(ipID domain.IPv6ID) (*domain.Location, error) {
+	if m.FindByIPv6IDFunc != nil {
+		return m.FindByIPv6IDFunc(ipID)
+	}
+	return nil, domain.ErrLocationNotFound
+}
+
+// MockNetworkRepository is a MockRepository that also answers CIDR queries
+// from a fixed list of locations.
+type MockNetworkRepository struct {
+	MockRepository
+	Locations []*domain.Location
+}
+
+func (m *MockNetworkRepository) FindByIPIDRange(first, last uint32, yield func(*domain.Location) bool) {
+	for _, location := range m.Locations {
+		if !location.IsIPv6() && location.LowerIPID <= last && location.UpperIPID >= first && !yield(location) {
+			return
+		}
+	}
+}
+
+func (m *MockNetworkRepository) FindByIPv6IDRange(first, last domain.IPv6ID, yield func(*domain.Location) bool) {
+	for _, location := range m.Locations {
+		if location.IsIPv6() && location.LowerIPv6ID.Compare(last) <= 0 && location.UpperIPv6ID.Compare(first) >= 0 &&
+			!yield(location) {
+			return
+		}
+	}
+}
+
+type MockASNRepository struct {
+	FindASNByIPIDFunc   func(ipID uint32) (*domain.ASN, error)
+	FindASNByIPv6IDFunc func(ipID domain.IPv6ID) (*domain.ASN, error)
+}
+
+func (m *MockASNRepository) FindASNByIPID(ipID uint32) (*domain.ASN, error) {
+	if m.FindASNByIPIDFunc != nil {
+		return m.FindASNByIPIDFunc(ipID)
+	}
+	return nil, domain.ErrASNNotFound
+}
+
+func (m *MockASNRepository) FindASNByIPv6ID(ipID domain.IPv6ID) (*domain.ASN, error) {
+	if m.FindASNByIPv6IDFunc != nil {
+		return m.FindASNByIPv6IDFunc(ipID)
+	}
+	return nil, domain.ErrASNNotFound
+}
+
+type MockThreatRepository struct {
+	FindThreatByIPIDFunc   func(ipID uint32) (*domain.Threat, error)
+	FindThreatByIPv6IDFunc func(ipID domain.IPv6ID) (*domain.Threat, error)
+}
+
+func (m *MockThreatRepository) FindThreatByIPID(ipID uint32) (*domain.Threat, error) {
+	if m.FindThreatByIPIDFunc != nil {
+		return m.FindThreatByIPIDFunc(ipID)
+	}
+	return &domain.Threat{}, nil
+}
+
+func (m *MockThreatRepository) FindThreatByIPv6ID(ipID domain.IPv6ID) (*domain.Threat, error) {
+	if m.FindThreatByIPv6IDFunc != nil {
+		return m.FindThreatByIPv6IDFunc(ipID)
+	}
+	return &domain.Threat{}, nil
+}
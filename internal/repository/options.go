@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"runtime"
+	"runtime/debug"
+)
+
+// ErrMemoryLimitExceeded is returned when a dataset does not fit in the
+// configured memory ceiling.
+var ErrMemoryLimitExceeded = errors.New("dataset exceeds memory limit")
+
+// Option customises how a repository loads its dataset.
+type Option func(*options)
+
+type options struct {
+	maxMemory        int64
+	progressInterval int
+	strict           *ValidationLimits
+	publicKey        ed25519.PublicKey
+	index            IndexKind
+	verifySnapshot   bool
+}
+
+func defaultOptions() options {
+	return options{
+		progressInterval: 500_000,
+		index:            IndexBinary,
+	}
+}
+
+// WithMaxMemory aborts loading once the parsed rows would need more than
+// maxBytes of memory. Zero means unlimited.
+func WithMaxMemory(maxBytes int64) Option {
+	return func(o *options) {
+		o.maxMemory = maxBytes
+	}
+}
+
+// WithProgressInterval logs loading progress every rows records. Zero
+// disables progress logging.
+func WithProgressInterval(rows int) Option {
+	return func(o *options) {
+		o.progressInterval = rows
+	}
+}
+
+// WithStrictValidation refuses datasets whose validation report has more
+// issues of any kind than limits allows. Without it issues are only logged.
+func WithStrictValidation(limits ValidationLimits) Option {
+	return func(o *options) {
+		o.strict = &limits
+	}
+}
+
+// WithPublicKey refuses a dataset unless its detached signature (path +
+// SignatureSuffix) verifies against key. It is checked on every load,
+// including reloads, before the new rows are served.
+func WithPublicKey(key ed25519.PublicKey) Option {
+	return func(o *options) {
+		o.publicKey = key
+	}
+}
+
+// WithIndex selects the structure that answers IPv4 lookups. It is built
+// after every load; an unknown kind fails the load.
+func WithIndex(kind IndexKind) Option {
+	return func(o *options) {
+		o.index = kind
+	}
+}
+
+// WithSnapshotVerification makes opening a snapshot also check the
+// checksum of the whole file and every table reference in it, which reads
+// every page. Without it only the header is checked up front.
+func WithSnapshotVerification() Option {
+	return func(o *options) {
+		o.verifySnapshot = true
+	}
+}
+
+// setLoadMemoryLimit lowers the Go runtime soft memory limit to the current
+// footprint plus maxBytes while a load runs, so the GC collects parser
+// garbage before the process grows past the ceiling. Memory already in use
+// (e.g. the dataset being replaced by a reload) is not counted against the
+// ceiling. The returned func restores the previous limit.
+func setLoadMemoryLimit(maxBytes int64) func() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	limit := int64(stats.Sys-stats.HeapReleased) + maxBytes
+	previous := debug.SetMemoryLimit(-1)
+	if limit >= previous {
+		return func() {}
+	}
+
+	debug.SetMemoryLimit(limit)
+	return func() {
+		debug.SetMemoryLimit(previous)
+	}
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"arena-backend-challenge/internal/domain"
+	yaml "go.yaml.in/yaml/v3"
+)
+
+// overrideEntry is one network of an override file with the location it maps
+// to. Bounds are 128-bit keys; IPv4 networks use their IPv4-mapped form.
+type overrideEntry struct {
+	lower    domain.IPv6ID
+	upper    domain.IPv6ID
+	location domain.Location
+}
+
+// overrideYAML is the document layout of a YAML override file.
+type overrideYAML struct {
+	Ranges []struct {
+		Network     string  `yaml:"network"`
+		CountryCode string  `yaml:"country_code"`
+		Country     string  `yaml:"country"`
+		Region      string  `yaml:"region"`
+		City        string  `yaml:"city"`
+		Latitude    float64 `yaml:"latitude"`
+		Longitude   float64 `yaml:"longitude"`
+		ZipCode     string  `yaml:"zip_code"`
+		TimeZone    string  `yaml:"time_zone"`
+	} `yaml:"ranges"`
+}
+
+// loadOverrides reads an override file. Files ending in .yaml or .yml are
+// parsed as YAML, anything else as CSV. Unlike the vendor dataset, override
+// files are small and hand-written, so any malformed entry is an error.
+func loadOverrides(path string) ([]overrideEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open override file %s: %w", path, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var entries []overrideEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		entries, err = parseOverrideYAML(file)
+	default:
+		entries, err = parseOverrideCSV(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse override file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// parseOverrideCSV reads a header line followed by rows of network,
+// country_code, country_name, region_name, city_name, latitude, longitude,
+// zip_code, time_zone: the DB11 columns with one network instead of
+// ip_from/ip_to.
+func parseOverrideCSV(r io.Reader) ([]overrideEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 9
+	reader.TrimLeadingSpace = true
+
+	var entries []overrideEntry
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 0 {
+			continue
+		}
+
+		latitude, err := strconv.ParseFloat(record[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: latitude: %w", line+1, err)
+		}
+		longitude, err := strconv.ParseFloat(record[6], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: longitude: %w", line+1, err)
+		}
+
+		entry, err := newOverrideEntry(record[0], domain.Location{
+			CountryCode: record[1],
+			Country:     record[2],
+			Region:      record[3],
+			City:        record[4],
+			Latitude:    latitude,
+			Longitude:   longitude,
+			ZipCode:     record[7],
+			TimeZone:    record[8],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line+1, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func parseOverrideYAML(r io.Reader) ([]overrideEntry, error) {
+	var doc overrideYAML
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	entries := make([]overrideEntry, 0, len(doc.Ranges))
+	for i, rng := range doc.Ranges {
+		entry, err := newOverrideEntry(rng.Network, domain.Location{
+			CountryCode: rng.CountryCode,
+			Country:     rng.Country,
+			Region:      rng.Region,
+			City:        rng.City,
+			Latitude:    rng.Latitude,
+			Longitude:   rng.Longitude,
+			ZipCode:     rng.ZipCode,
+			TimeZone:    rng.TimeZone,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("range %d: %w", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func newOverrideEntry(network string, location domain.Location) (overrideEntry, error) {
+	first, last, err := parseNetwork(network)
+	if err != nil {
+		return overrideEntry{}, err
+	}
+	return overrideEntry{
+		lower:    addrKey(first),
+		upper:    addrKey(last),
+		location: location,
+	}, nil
+}
+
+// parseNetwork accepts a CIDR prefix ("10.0.0.0/8"), an inclusive range
+// ("10.0.0.1-10.0.0.9") or a single address.
+func parseNetwork(network string) (netip.Addr, netip.Addr, error) {
+	network = strings.TrimSpace(network)
+
+	if strings.Contains(network, "/") {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid network %q: %w", network, err)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefix = prefix.Masked()
+		return prefix.Addr(), lastAddr(prefix), nil
+	}
+
+	if from, to, ok := strings.Cut(network, "-"); ok {
+		first, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid network %q: %w", network, err)
+		}
+		last, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid network %q: %w", network, err)
+		}
+		first, last = first.Unmap(), last.Unmap()
+		if first.Is4() != last.Is4() {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid network %q: mixes IPv4 and IPv6", network)
+		}
+		if last.Less(first) {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid network %q: start is after end", network)
+		}
+		return first, last, nil
+	}
+
+	addr, err := netip.ParseAddr(network)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("invalid network %q: %w", network, err)
+	}
+	return addr.Unmap(), addr.Unmap(), nil
+}
+
+// lastAddr returns the highest address of prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	raw := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(raw)*8; bit++ {
+		raw[bit/8] |= 1 << (7 - bit%8)
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	return addr
+}
+
+// addrKey maps an address onto the 128-bit key space the override table is
+// sorted by, with IPv4 addresses in their IPv4-mapped form.
+func addrKey(addr netip.Addr) domain.IPv6ID {
+	if addr.Is4() {
+		raw := addr.As4()
+		return domain.IPv4ToIPv6ID(binary.BigEndian.Uint32(raw[:]))
+	}
+	raw := addr.As16()
+	return domain.IPv6ID{
+		Hi: binary.BigEndian.Uint64(raw[:8]),
+		Lo: binary.BigEndian.Uint64(raw[8:]),
+	}
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"strings"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// placeIndex is the reverse of the lookup tables: it lists the rows of every
+// country, and of every city within a country, so their ranges can be
+// listed without scanning the dataset. Rows of one place are contiguous, in
+// address order with IPv4 first. It is built on first use, once per dataset.
+type placeIndex struct {
+	countries map[string]placeSpan
+	cities    map[placeKey]placeSpan
+	byCountry []rowRef
+	byCity    []rowRef
+}
+
+// placeKey identifies a city by upper-case country code and lower-case name.
+type placeKey struct {
+	country string
+	city    string
+}
+
+// placeSpan is a place's rows in byCountry or byCity: IPv4 rows from start
+// to v6, IPv6 rows from v6 to end.
+type placeSpan struct {
+	start uint32
+	v6    uint32
+	end   uint32
+}
+
+// rowRef is a row of the IPv4 table, or of the IPv6 table when rowRefIPv6
+// is set.
+type rowRef uint32
+
+const rowRefIPv6 rowRef = 1 << 31
+
+// places returns the dataset's place index, building it on first use.
+func (d *dataset) places() *placeIndex {
+	d.placesOnce.Do(func() {
+		d.placeIndex = newPlaceIndex(d)
+	})
+	return d.placeIndex
+}
+
+func newPlaceIndex(d *dataset) *placeIndex {
+	// Number the distinct country codes and (country, city name) pairs, so
+	// rows can be grouped through slices instead of string-keyed maps.
+	countryIDs := make(map[string]uint32)
+	var countryKeys []string
+	countryOf := make([]uint32, len(d.countries))
+	for i, c := range d.countries {
+		code := strings.ToUpper(c.code)
+		id, ok := countryIDs[code]
+		if !ok {
+			id = uint32(len(countryKeys))
+			countryIDs[code] = id
+			countryKeys = append(countryKeys, code)
+		}
+		countryOf[i] = id
+	}
+
+	nameIDs := make(map[string]uint32)
+	var nameKeys []string
+	nameOf := make([]uint32, len(d.cities))
+	for i, c := range d.cities {
+		name := strings.ToLower(c.name)
+		id, ok := nameIDs[name]
+		if !ok {
+			id = uint32(len(nameKeys))
+			nameIDs[name] = id
+			nameKeys = append(nameKeys, name)
+		}
+		nameOf[i] = id
+	}
+
+	cityIDs := make(map[uint64]uint32)
+	var cityKeys []placeKey
+	cityOf := func(countryIdx uint16, cityIdx uint32) uint32 {
+		country, name := countryOf[countryIdx], nameOf[cityIdx]
+		key := uint64(country)<<32 | uint64(name)
+		id, ok := cityIDs[key]
+		if !ok {
+			id = uint32(len(cityKeys))
+			cityIDs[key] = id
+			cityKeys = append(cityKeys, placeKey{country: countryKeys[country], city: nameKeys[name]})
+		}
+		return id
+	}
+
+	countries4 := make([]uint32, d.v4.Len())
+	cities4 := make([]uint32, d.v4.Len())
+	for i := range countries4 {
+		countries4[i] = countryOf[d.v4.country[i]]
+		cities4[i] = cityOf(d.v4.country[i], d.v4.city[i])
+	}
+	countries6 := make([]uint32, d.v6.Len())
+	cities6 := make([]uint32, d.v6.Len())
+	for i := range countries6 {
+		countries6[i] = countryOf[d.v6.country[i]]
+		cities6[i] = cityOf(d.v6.country[i], d.v6.city[i])
+	}
+
+	x := &placeIndex{
+		countries: make(map[string]placeSpan, len(countryKeys)),
+		cities:    make(map[placeKey]placeSpan, len(cityKeys)),
+	}
+
+	var spans []placeSpan
+	x.byCountry, spans = groupRows(len(countryKeys), countries4, countries6)
+	for id, span := range spans {
+		x.countries[countryKeys[id]] = span
+	}
+	x.byCity, spans = groupRows(len(cityKeys), cities4, cities6)
+	for id, span := range spans {
+		x.cities[cityKeys[id]] = span
+	}
+
+	return x
+}
+
+// groupRows counting-sorts the rows by group, given the group of every IPv4
+// and IPv6 row. Rows keep their table order within a group, which is
+// address order since the tables are sorted.
+func groupRows(groups int, rows4, rows6 []uint32) ([]rowRef, []placeSpan) {
+	count4 := make([]uint32, groups)
+	count6 := make([]uint32, groups)
+	for _, g := range rows4 {
+		count4[g]++
+	}
+	for _, g := range rows6 {
+		count6[g]++
+	}
+
+	spans := make([]placeSpan, groups)
+	var next uint32
+	for g := range spans {
+		spans[g] = placeSpan{start: next, v6: next + count4[g], end: next + count4[g] + count6[g]}
+		next = spans[g].end
+	}
+
+	// Reuse the counts as fill positions.
+	refs := make([]rowRef, next)
+	for g := range spans {
+		count4[g], count6[g] = spans[g].start, spans[g].v6
+	}
+	for row, g := range rows4 {
+		refs[count4[g]] = rowRef(row)
+		count4[g]++
+	}
+	for row, g := range rows6 {
+		refs[count6[g]] = rowRef(row) | rowRefIPv6
+		count6[g]++
+	}
+
+	return refs, spans
+}
+
+// ListRanges lists the ranges of a country, or of a city in it, one page at
+// a time. Unknown places yield an empty page.
+func (r *MemoryRepository) ListRanges(q domain.RangeQuery) domain.RangePage {
+	data := r.current()
+	places := data.places()
+
+	var (
+		span placeSpan
+		refs []rowRef
+		ok   bool
+	)
+	code := strings.ToUpper(q.CountryCode)
+	if q.City == "" {
+		span, ok = places.countries[code]
+		refs = places.byCountry
+	} else {
+		span, ok = places.cities[placeKey{country: code, city: strings.ToLower(q.City)}]
+		refs = places.byCity
+	}
+	if !ok {
+		return domain.RangePage{}
+	}
+
+	from, to := int(span.start), int(span.end)
+	switch q.IPVersion {
+	case 4:
+		to = int(span.v6)
+	case 6:
+		from = int(span.v6)
+	}
+
+	page := domain.RangePage{Total: to - from}
+	// Clamp before adding, so a huge offset cannot overflow.
+	from += min(max(q.Offset, 0), to-from)
+	if q.Limit > 0 {
+		to = min(from+q.Limit, to)
+	}
+
+	page.Ranges = make([]domain.IPRange, 0, to-from)
+	for _, ref := range refs[from:to] {
+		page.Ranges = append(page.Ranges, data.ipRange(ref))
+	}
+	return page
+}
+
+func (d *dataset) ipRange(ref rowRef) domain.IPRange {
+	if ref&rowRefIPv6 != 0 {
+		i := int(ref &^ rowRefIPv6)
+		ct := &d.cities[d.v6.city[i]]
+		return domain.IPRange{
+			First:  ipv6Addr(d.v6.lower[i]),
+			Last:   ipv6Addr(d.v6.upper[i]),
+			Region: ct.region,
+			City:   ct.name,
+		}
+	}
+
+	i := int(ref)
+	ct := &d.cities[d.v4.city[i]]
+	return domain.IPRange{
+		First:  ipv4Addr(d.v4.lower[i]),
+		Last:   ipv4Addr(d.v4.upper[i]),
+		Region: ct.region,
+		City:   ct.name,
+	}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+func TestMemoryRepository_ListRanges(t *testing.T) {
+	// Sao Paulo appears with two zip codes, so as two city entries that
+	// must still list as one city.
+	content := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","BR","Brazil","Sao Paulo","Sao Paulo","-23.547500","-46.636110","01000-000","-03:00"
+"16777472","16777727","US","United States","California","Los Angeles","34.052230","-118.243680","90001","-07:00"
+"16777728","16778239","BR","Brazil","Rio de Janeiro","Rio de Janeiro","-22.902780","-43.207500","20000-000","-03:00"
+"16778240","16778250","BR","Brazil","Sao Paulo","Sao Paulo","-23.547500","-46.636110","01001-000","-03:00"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","BR","Brazil","Sao Paulo","Sao Paulo","-23.547500","-46.636110","01000-000","-03:00"`
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	repo, err := NewMemoryRepository(path)
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+
+	var (
+		saoPaulo1 = "1.0.0.0-1.0.0.255"
+		rio       = "1.0.2.0-1.0.3.255"
+		saoPaulo2 = "1.0.4.0-1.0.4.10"
+		saoPaulo6 = "2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"
+	)
+
+	tests := []struct {
+		name      string
+		query     domain.RangeQuery
+		wantTotal int
+		want      []string
+	}{
+		{
+			name:      "country",
+			query:     domain.RangeQuery{CountryCode: "BR"},
+			wantTotal: 4,
+			want:      []string{saoPaulo1, rio, saoPaulo2, saoPaulo6},
+		},
+		{
+			name:      "city, any case",
+			query:     domain.RangeQuery{CountryCode: "br", City: "SAO PAULO"},
+			wantTotal: 3,
+			want:      []string{saoPaulo1, saoPaulo2, saoPaulo6},
+		},
+		{
+			name:      "IPv4 only",
+			query:     domain.RangeQuery{CountryCode: "BR", City: "Sao Paulo", IPVersion: 4},
+			wantTotal: 2,
+			want:      []string{saoPaulo1, saoPaulo2},
+		},
+		{
+			name:      "IPv6 only",
+			query:     domain.RangeQuery{CountryCode: "BR", IPVersion: 6},
+			wantTotal: 1,
+			want:      []string{saoPaulo6},
+		},
+		{
+			name:      "page",
+			query:     domain.RangeQuery{CountryCode: "BR", Offset: 1, Limit: 2},
+			wantTotal: 4,
+			want:      []string{rio, saoPaulo2},
+		},
+		{
+			name:      "offset past the end",
+			query:     domain.RangeQuery{CountryCode: "BR", Offset: 10, Limit: 2},
+			wantTotal: 4,
+			want:      nil,
+		},
+		{
+			name:  "city in another country",
+			query: domain.RangeQuery{CountryCode: "US", City: "Sao Paulo"},
+		},
+		{
+			name:  "unknown country",
+			query: domain.RangeQuery{CountryCode: "ZZ"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := repo.ListRanges(tt.query)
+			if page.Total != tt.wantTotal {
+				t.Errorf("ListRanges() total = %d, want %d", page.Total, tt.wantTotal)
+			}
+
+			var got []string
+			for _, r := range page.Ranges {
+				got = append(got, r.First.String()+"-"+r.Last.String())
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ListRanges() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ListRanges()[%d] = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	page := repo.ListRanges(domain.RangeQuery{CountryCode: "BR", City: "Rio de Janeiro"})
+	if len(page.Ranges) != 1 || page.Ranges[0].Region != "Rio de Janeiro" || !page.Ranges[0].First.Is4() {
+		t.Errorf("ListRanges(Rio de Janeiro) = %+v", page.Ranges)
+	}
+
+	// A reload builds a new index for the new dataset.
+	content = `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.052230","-118.243680","90001","-07:00"`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if err := repo.Reload(""); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if page := repo.ListRanges(domain.RangeQuery{CountryCode: "BR"}); page.Total != 0 {
+		t.Errorf("ListRanges(BR) after reload total = %d, want 0", page.Total)
+	}
+	page = repo.ListRanges(domain.RangeQuery{CountryCode: "US"})
+	if page.Total != 1 || page.Ranges[0].Last != netip.MustParseAddr("1.0.0.255") {
+		t.Errorf("ListRanges(US) after reload = %+v", page)
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"io"
+	"time"
+
+	"arena-backend-challenge/pkg/logger"
+)
+
+// capacitySampleRows is how many rows are measured before the result slice is
+// sized from the file length.
+const capacitySampleRows = 10_000
+
+// loadProgress reports how far a CSV load got through pkg/logger.
+type loadProgress struct {
+	path     string
+	size     int64
+	interval int
+	rows     int
+	start    time.Time
+}
+
+func newLoadProgress(path string, size int64, interval int) *loadProgress {
+	return &loadProgress{
+		path:     path,
+		size:     size,
+		interval: interval,
+		start:    time.Now(),
+	}
+}
+
+func (p *loadProgress) row(bytesRead int64) {
+	p.rows++
+	if p.interval <= 0 || p.rows%p.interval != 0 {
+		return
+	}
+
+	if p.size > 0 {
+		logger.Infof("Loading %s - %d rows - %.1f%% - Elapsed: %v",
+			p.path, p.rows, float64(bytesRead)*100/float64(p.size), time.Since(p.start).Round(time.Millisecond))
+		return
+	}
+	logger.Infof("Loading %s - %d rows - Elapsed: %v", p.path, p.rows, time.Since(p.start).Round(time.Millisecond))
+}
+
+func (p *loadProgress) done(bytesRead int64, loaded int) {
+	logger.Infof("Loaded %s - %d of %d rows - %d bytes - Duration: %v",
+		p.path, loaded, p.rows, bytesRead, time.Since(p.start).Round(time.Millisecond))
+}
+
+// countingReader counts the bytes read from the underlying file so progress
+// can be reported as a percentage.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// recordSize approximates the on-disk length of a quoted CSV record.
+func recordSize(record []string) int64 {
+	size := int64(len(record)) * 3 // two quotes and a separator per field
+	for _, field := range record {
+		size += int64(len(field))
+	}
+	return size
+}
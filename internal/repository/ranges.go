@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"math"
+	"sort"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// rangeTables maps IP ranges to an index into a side table. The auxiliary
+// datasets (ASN, proxy, Tor) only need one value per range, so they share
+// these instead of the location dataset's wider tables.
+type rangeTables struct {
+	v4 rangeTable4
+	v6 rangeTable6
+}
+
+// add stores a range. IPv4-mapped ranges go to the IPv4 table.
+func (t *rangeTables) add(lower, upper domain.IPv6ID, value uint32) {
+	lower4, lowerIs4 := ipv4ID(lower)
+	upper4, upperIs4 := ipv4ID(upper)
+	if lowerIs4 && upperIs4 {
+		t.v4.lower = append(t.v4.lower, lower4)
+		t.v4.upper = append(t.v4.upper, upper4)
+		t.v4.value = append(t.v4.value, value)
+		return
+	}
+
+	t.v6.lower = append(t.v6.lower, lower)
+	t.v6.upper = append(t.v6.upper, upper)
+	t.v6.value = append(t.v6.value, value)
+}
+
+// sort orders both tables by lower bound; files are usually sorted already.
+func (t *rangeTables) sort() {
+	if !sort.IsSorted(&t.v4) {
+		sort.Stable(&t.v4)
+	}
+	if !sort.IsSorted(&t.v6) {
+		sort.Stable(&t.v6)
+	}
+}
+
+// merge joins overlapping and adjacent ranges of sorted tables, which find
+// needs to be disjoint. The ranges must all carry the same value, as in a
+// set of addresses.
+func (t *rangeTables) merge() {
+	t.v4.merge()
+	t.v6.merge()
+}
+
+func (t *rangeTables) Len() int {
+	return t.v4.Len() + t.v6.Len()
+}
+
+type rangeTable4 struct {
+	lower []uint32
+	upper []uint32
+	value []uint32
+}
+
+func (t *rangeTable4) Len() int           { return len(t.lower) }
+func (t *rangeTable4) Less(i, j int) bool { return t.lower[i] < t.lower[j] }
+func (t *rangeTable4) Swap(i, j int) {
+	t.lower[i], t.lower[j] = t.lower[j], t.lower[i]
+	t.upper[i], t.upper[j] = t.upper[j], t.upper[i]
+	t.value[i], t.value[j] = t.value[j], t.value[i]
+}
+
+// find returns the row containing ipID.
+func (t *rangeTable4) find(ipID uint32) (int, bool) {
+	idx := sort.Search(len(t.upper), func(i int) bool {
+		return t.upper[i] >= ipID
+	})
+	return idx, idx < len(t.upper) && t.lower[idx] <= ipID
+}
+
+func (t *rangeTable4) merge() {
+	n := 0
+	for i := range t.lower {
+		if n > 0 && (t.upper[n-1] == math.MaxUint32 || t.lower[i] <= t.upper[n-1]+1) {
+			t.upper[n-1] = max(t.upper[n-1], t.upper[i])
+			continue
+		}
+		t.lower[n], t.upper[n], t.value[n] = t.lower[i], t.upper[i], t.value[i]
+		n++
+	}
+	t.lower, t.upper, t.value = t.lower[:n], t.upper[:n], t.value[:n]
+}
+
+type rangeTable6 struct {
+	lower []domain.IPv6ID
+	upper []domain.IPv6ID
+	value []uint32
+}
+
+func (t *rangeTable6) Len() int           { return len(t.lower) }
+func (t *rangeTable6) Less(i, j int) bool { return t.lower[i].Less(t.lower[j]) }
+func (t *rangeTable6) Swap(i, j int) {
+	t.lower[i], t.lower[j] = t.lower[j], t.lower[i]
+	t.upper[i], t.upper[j] = t.upper[j], t.upper[i]
+	t.value[i], t.value[j] = t.value[j], t.value[i]
+}
+
+func (t *rangeTable6) merge() {
+	last := domain.IPv6ID{Hi: ^uint64(0), Lo: ^uint64(0)}
+	n := 0
+	for i := range t.lower {
+		if n > 0 && (t.upper[n-1] == last || t.lower[i].Compare(t.upper[n-1].Next()) <= 0) {
+			if t.upper[n-1].Less(t.upper[i]) {
+				t.upper[n-1] = t.upper[i]
+			}
+			continue
+		}
+		t.lower[n], t.upper[n], t.value[n] = t.lower[i], t.upper[i], t.value[i]
+		n++
+	}
+	t.lower, t.upper, t.value = t.lower[:n], t.upper[:n], t.value[:n]
+}
+
+// find returns the row containing ipID.
+func (t *rangeTable6) find(ipID domain.IPv6ID) (int, bool) {
+	idx := sort.Search(len(t.upper), func(i int) bool {
+		return t.upper[i].Compare(ipID) >= 0
+	})
+	return idx, idx < len(t.upper) && t.lower[idx].Compare(ipID) <= 0
+}
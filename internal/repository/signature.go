@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SignatureSuffix names the detached signature next to a dataset, e.g.
+// IP2LOCATION-LITE-DB11.CSV.sig.
+const SignatureSuffix = ".sig"
+
+// ErrSignatureInvalid is returned when a dataset's detached signature is
+// missing or does not verify against the configured public key.
+var ErrSignatureInvalid = errors.New("dataset signature invalid")
+
+// SignDataset signs the dataset at path for verification with
+// WithPublicKey. The signature covers the SHA-256 of the file as stored on
+// disk (the digest reported by DatasetInfo), so compressed files are signed
+// as shipped. It is returned base64 encoded, ready to be written to path +
+// SignatureSuffix.
+func SignDataset(path string, key ed25519.PrivateKey) ([]byte, error) {
+	file, err := openDatasetFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// Reading the content to the end completes the digest, whatever the
+	// compression.
+	if _, err := io.Copy(io.Discard, file); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	signature := ed25519.Sign(key, file.Sum())
+	return []byte(base64.StdEncoding.EncodeToString(signature) + "\n"), nil
+}
+
+// verifySignature checks path's detached signature of sum, the SHA-256 of
+// the file, against key. The signature may be raw or base64 encoded.
+func verifySignature(path string, sum []byte, key ed25519.PublicKey) error {
+	sigPath := path + SignatureSuffix
+
+	signature, err := os.ReadFile(sigPath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return fmt.Errorf("%w: %s is neither raw nor base64", ErrSignatureInvalid, sigPath)
+		}
+		signature = decoded
+	}
+
+	if !ed25519.Verify(key, sum, signature) {
+		return fmt.Errorf("%w: %s does not match %s", ErrSignatureInvalid, sigPath, path)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const signedCSV = `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"134744072","134744072","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"
+`
+
+func TestMemoryRepository_Signature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write([]byte(signedCSV)); err != nil {
+		t.Fatalf("Failed to gzip CSV: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to gzip CSV: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		// sign returns the signature to store, or nil for none.
+		sign    func(path string) []byte
+		wantErr bool
+	}{
+		{
+			name:    "valid base64 signature",
+			file:    "data.csv",
+			content: []byte(signedCSV),
+			sign:    func(path string) []byte { return mustSign(t, path, privateKey) },
+		},
+		{
+			name:    "valid raw signature",
+			file:    "data.csv",
+			content: []byte(signedCSV),
+			sign: func(path string) []byte {
+				raw, _ := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(mustSign(t, path, privateKey))))
+				return raw
+			},
+		},
+		{
+			name:    "gzip file signed as shipped",
+			file:    "data.csv.gz",
+			content: gz.Bytes(),
+			sign:    func(path string) []byte { return mustSign(t, path, privateKey) },
+		},
+		{
+			name:    "missing signature",
+			file:    "data.csv",
+			content: []byte(signedCSV),
+			wantErr: true,
+		},
+		{
+			name:    "signed with another key",
+			file:    "data.csv",
+			content: []byte(signedCSV),
+			sign:    func(path string) []byte { return mustSign(t, path, otherKey) },
+			wantErr: true,
+		},
+		{
+			name:    "file changed after signing",
+			file:    "data.csv",
+			content: []byte(signedCSV),
+			sign: func(path string) []byte {
+				signature := mustSign(t, path, privateKey)
+				tampered := bytes.Replace([]byte(signedCSV), []byte(`"US"`), []byte(`"KP"`), 1)
+				if err := os.WriteFile(path, tampered, 0644); err != nil {
+					t.Fatalf("Failed to tamper with CSV: %v", err)
+				}
+				return signature
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, tt.content, 0644); err != nil {
+				t.Fatalf("Failed to write dataset: %v", err)
+			}
+			if tt.sign != nil {
+				if err := os.WriteFile(path+SignatureSuffix, tt.sign(path), 0644); err != nil {
+					t.Fatalf("Failed to write signature: %v", err)
+				}
+			}
+
+			repo, err := NewMemoryRepository(path, WithPublicKey(publicKey))
+			if tt.wantErr {
+				if !errors.Is(err, ErrSignatureInvalid) {
+					t.Errorf("NewMemoryRepository() error = %v, want ErrSignatureInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewMemoryRepository() error = %v", err)
+			}
+			if repo.Len() != 1 {
+				t.Errorf("Len() = %d, want 1", repo.Len())
+			}
+		})
+	}
+}
+
+func TestMemoryRepository_Reload_Signature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(signedCSV), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if err := os.WriteFile(path+SignatureSuffix, mustSign(t, path, privateKey), 0644); err != nil {
+		t.Fatalf("Failed to write signature: %v", err)
+	}
+
+	repo, err := NewMemoryRepository(path, WithPublicKey(publicKey))
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+
+	// An unsigned replacement is rejected and the signed dataset stays.
+	tampered := signedCSV + `"134744073","134744073","KP","North Korea","Pyongyang","Pyongyang","39.03385","125.75432","-","+09:00"` + "\n"
+	if err := os.WriteFile(path, []byte(tampered), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if err := repo.Reload(""); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("Reload() error = %v, want ErrSignatureInvalid", err)
+	}
+	if _, err := repo.FindByIPID(134744073); err == nil {
+		t.Error("FindByIPID() found a range from the rejected dataset")
+	}
+}
+
+func mustSign(t *testing.T, path string, key ed25519.PrivateKey) []byte {
+	t.Helper()
+
+	signature, err := SignDataset(path, key)
+	if err != nil {
+		t.Fatalf("SignDataset() error = %v", err)
+	}
+	return signature
+}
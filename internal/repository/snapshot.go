@@ -0,0 +1,625 @@
+package repository
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+	"sync"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// Snapshot file layout (all integers little-endian, every section starts on
+// an 8-byte boundary):
+//
+//	header      64 bytes: magic, version, flags, row/table counts, string
+//	            bytes and, in the last 4 bytes, the CRC-32C of the rest
+//	v4 lower    v4Count × uint32
+//	v4 upper    v4Count × uint32
+//	v4 city     v4Count × uint32
+//	v4 country  v4Count × uint16
+//	v6 lower    v6Count × (hi uint64, lo uint64)
+//	v6 upper    v6Count × (hi uint64, lo uint64)
+//	v6 city     v6Count × uint32
+//	v6 country  v6Count × uint16
+//	countries   countryCount × (code off, code len, name off, name len uint32)
+//	cities      cityCount × (region, name, zip, time zone off/len uint32 pairs,
+//	            latitude float64, longitude float64)
+//	strings     stringBytes of UTF-8 referenced by the tables above
+//	checksum    CRC-32C (Castagnoli) of everything before it, uint32
+//
+// Lookups binary-search the mapped range columns directly; nothing is decoded
+// up front. Opening only checks the header, so it costs the same for any file
+// size; WithSnapshotVerification also checks the body.
+const (
+	snapshotMagic      = "IPLOCSNP"
+	snapshotVersion    = 2
+	snapshotHeaderSize = 64
+	countryEntrySize   = 16
+	cityEntrySize      = 48
+	checksumSize       = 4
+	headerChecksum     = snapshotHeaderSize - checksumSize
+)
+
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot file")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type snapshotHeader struct {
+	version      uint32
+	flags        uint32
+	v4Count      uint32
+	v6Count      uint32
+	countryCount uint32
+	cityCount    uint32
+	stringBytes  uint64
+}
+
+// snapshotLayout holds the byte offset of every section, derived from the
+// header counts.
+type snapshotLayout struct {
+	v4Lower, v4Upper, v4City, v4Country int
+	v6Lower, v6Upper, v6City, v6Country int
+	countries, cities, strings          int
+	checksum, size                      int
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+func (h *snapshotHeader) layout() snapshotLayout {
+	var l snapshotLayout
+	off := snapshotHeaderSize
+	next := func(size int) int {
+		start := off
+		off = align8(off + size)
+		return start
+	}
+
+	v4, v6 := int(h.v4Count), int(h.v6Count)
+	l.v4Lower = next(v4 * 4)
+	l.v4Upper = next(v4 * 4)
+	l.v4City = next(v4 * 4)
+	l.v4Country = next(v4 * 2)
+	l.v6Lower = next(v6 * 16)
+	l.v6Upper = next(v6 * 16)
+	l.v6City = next(v6 * 4)
+	l.v6Country = next(v6 * 2)
+	l.countries = next(int(h.countryCount) * countryEntrySize)
+	l.cities = next(int(h.cityCount) * cityEntrySize)
+	l.strings = next(int(h.stringBytes))
+	l.checksum = off
+	l.size = off + checksumSize
+	return l
+}
+
+// WriteSnapshot serialises the dataset currently served by repo.
+func WriteSnapshot(w io.Writer, repo *MemoryRepository) error {
+	return repo.current().writeSnapshot(w)
+}
+
+func (d *dataset) writeSnapshot(w io.Writer) error {
+	strs := newStringTable()
+	countries := make([][4]uint32, len(d.countries))
+	for i, c := range d.countries {
+		countries[i] = [4]uint32{}
+		countries[i][0], countries[i][1] = strs.add(c.code)
+		countries[i][2], countries[i][3] = strs.add(c.name)
+	}
+	cities := make([][8]uint32, len(d.cities))
+	for i, c := range d.cities {
+		cities[i][0], cities[i][1] = strs.add(c.region)
+		cities[i][2], cities[i][3] = strs.add(c.name)
+		cities[i][4], cities[i][5] = strs.add(c.zipCode)
+		cities[i][6], cities[i][7] = strs.add(c.timeZone)
+	}
+
+	if len(strs.data) > math.MaxUint32 {
+		return fmt.Errorf("string table too large: %d bytes", len(strs.data))
+	}
+
+	header := snapshotHeader{
+		version:      snapshotVersion,
+		v4Count:      uint32(d.v4.Len()),
+		v6Count:      uint32(d.v6.Len()),
+		countryCount: uint32(len(d.countries)),
+		cityCount:    uint32(len(d.cities)),
+		stringBytes:  uint64(len(strs.data)),
+	}
+	layout := header.layout()
+
+	crc := crc32.New(crcTable)
+	sw := &snapshotWriter{w: bufio.NewWriterSize(io.MultiWriter(w, crc), 1<<20)}
+
+	var hdr [snapshotHeaderSize]byte
+	copy(hdr[:8], snapshotMagic)
+	binary.LittleEndian.PutUint32(hdr[8:], header.version)
+	binary.LittleEndian.PutUint32(hdr[12:], header.flags)
+	binary.LittleEndian.PutUint32(hdr[16:], header.v4Count)
+	binary.LittleEndian.PutUint32(hdr[20:], header.v6Count)
+	binary.LittleEndian.PutUint32(hdr[24:], header.countryCount)
+	binary.LittleEndian.PutUint32(hdr[28:], header.cityCount)
+	binary.LittleEndian.PutUint64(hdr[32:], header.stringBytes)
+	binary.LittleEndian.PutUint32(hdr[headerChecksum:], crc32.Checksum(hdr[:headerChecksum], crcTable))
+	sw.write(hdr[:])
+
+	sw.padTo(layout.v4Lower)
+	for _, v := range d.v4.lower {
+		sw.u32(v)
+	}
+	sw.padTo(layout.v4Upper)
+	for _, v := range d.v4.upper {
+		sw.u32(v)
+	}
+	sw.padTo(layout.v4City)
+	for _, v := range d.v4.city {
+		sw.u32(v)
+	}
+	sw.padTo(layout.v4Country)
+	for _, v := range d.v4.country {
+		sw.u16(v)
+	}
+
+	sw.padTo(layout.v6Lower)
+	for _, v := range d.v6.lower {
+		sw.u64(v.Hi)
+		sw.u64(v.Lo)
+	}
+	sw.padTo(layout.v6Upper)
+	for _, v := range d.v6.upper {
+		sw.u64(v.Hi)
+		sw.u64(v.Lo)
+	}
+	sw.padTo(layout.v6City)
+	for _, v := range d.v6.city {
+		sw.u32(v)
+	}
+	sw.padTo(layout.v6Country)
+	for _, v := range d.v6.country {
+		sw.u16(v)
+	}
+
+	sw.padTo(layout.countries)
+	for _, c := range countries {
+		for _, v := range c {
+			sw.u32(v)
+		}
+	}
+	sw.padTo(layout.cities)
+	for i, c := range cities {
+		for _, v := range c {
+			sw.u32(v)
+		}
+		sw.u64(math.Float64bits(d.cities[i].latitude))
+		sw.u64(math.Float64bits(d.cities[i].longitude))
+	}
+	sw.padTo(layout.strings)
+	sw.write(strs.data)
+	sw.padTo(layout.checksum)
+
+	if sw.err != nil {
+		return fmt.Errorf("write snapshot: %w", sw.err)
+	}
+	if err := sw.w.Flush(); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	var sum [checksumSize]byte
+	binary.LittleEndian.PutUint32(sum[:], crc.Sum32())
+	if _, err := w.Write(sum[:]); err != nil {
+		return fmt.Errorf("write snapshot checksum: %w", err)
+	}
+	return nil
+}
+
+// stringTable deduplicates the strings written to the snapshot.
+type stringTable struct {
+	data    []byte
+	offsets map[string]uint32
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{offsets: make(map[string]uint32)}
+}
+
+func (t *stringTable) add(s string) (offset, length uint32) {
+	if off, ok := t.offsets[s]; ok {
+		return off, uint32(len(s))
+	}
+	off := uint32(len(t.data))
+	t.data = append(t.data, s...)
+	t.offsets[s] = off
+	return off, uint32(len(s))
+}
+
+// snapshotWriter keeps the first write error so the section loop stays flat.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	n   int
+	err error
+	buf [8]byte
+}
+
+func (w *snapshotWriter) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	var n int
+	n, w.err = w.w.Write(p)
+	w.n += n
+}
+
+func (w *snapshotWriter) padTo(offset int) {
+	for w.n < offset && w.err == nil {
+		w.write([]byte{0})
+	}
+}
+
+func (w *snapshotWriter) u16(v uint16) {
+	binary.LittleEndian.PutUint16(w.buf[:2], v)
+	w.write(w.buf[:2])
+}
+
+func (w *snapshotWriter) u32(v uint32) {
+	binary.LittleEndian.PutUint32(w.buf[:4], v)
+	w.write(w.buf[:4])
+}
+
+func (w *snapshotWriter) u64(v uint64) {
+	binary.LittleEndian.PutUint64(w.buf[:8], v)
+	w.write(w.buf[:8])
+}
+
+// SnapshotRepository serves lookups straight from a memory-mapped snapshot
+// produced by WriteSnapshot.
+type SnapshotRepository struct {
+	// path is only used by Reload, under reloadMu.
+	path     string
+	verify   bool
+	reloadMu sync.Mutex
+
+	mu   sync.RWMutex
+	snap *snapshot
+}
+
+// snapshot is one mapped snapshot file.
+type snapshot struct {
+	data   []byte
+	header snapshotHeader
+	layout snapshotLayout
+
+	// info.SHA256 is filled in by the first DatasetInfo call: hashing the
+	// whole file would undo the cheap open.
+	info     domain.DatasetInfo
+	infoOnce sync.Once
+}
+
+// NewSnapshotRepository maps the snapshot at path. Only WithSnapshotVerification
+// applies; the other options are for CSV datasets.
+func NewSnapshotRepository(path string, opts ...Option) (*SnapshotRepository, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	snap, err := openSnapshot(path, o.verifySnapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SnapshotRepository{
+		path:   path,
+		verify: o.verifySnapshot,
+		snap:   snap,
+	}, nil
+}
+
+// Reload maps path (or the current file when empty), checks it like the first
+// one and swaps it in. The previous mapping is released once no lookup is
+// using it.
+func (r *SnapshotRepository) Reload(path string) error {
+	if !r.reloadMu.TryLock() {
+		return domain.ErrReloadInProgress
+	}
+	defer r.reloadMu.Unlock()
+
+	if path == "" {
+		path = r.path
+	}
+
+	snap, err := openSnapshot(path, r.verify)
+	if err != nil {
+		return err
+	}
+
+	if snap.header.v4Count == 0 && snap.header.v6Count == 0 {
+		_ = snap.close()
+		return fmt.Errorf("validate %s: dataset has no valid rows", path)
+	}
+
+	r.mu.Lock()
+	old := r.snap
+	r.snap = snap
+	r.mu.Unlock()
+	r.path = path
+
+	return old.close()
+}
+
+// Close unmaps the snapshot. The repository must not be used afterwards.
+func (r *SnapshotRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.snap.close()
+}
+
+// DatasetInfo describes the snapshot file currently mapped. Snapshots don't
+// record the rows skipped when they were built.
+func (r *SnapshotRepository) DatasetInfo() domain.DatasetInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.snap.datasetInfo()
+}
+
+// Len returns the number of ranges in the snapshot, IPv4 and IPv6 combined.
+func (r *SnapshotRepository) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int(r.snap.header.v4Count) + int(r.snap.header.v6Count)
+}
+
+func (r *SnapshotRepository) FindByIPID(ipID uint32) (*domain.Location, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	location, err := r.snap.find4(ipID)
+	if err != nil {
+		return nil, fmt.Errorf("search IP ID %d: %w", ipID, err)
+	}
+	return location, nil
+}
+
+// FindByIPv6ID resolves a 128-bit IP number. IPv4-mapped addresses are
+// answered from the IPv4 table.
+func (r *SnapshotRepository) FindByIPv6ID(ipID domain.IPv6ID) (*domain.Location, error) {
+	if ipv4ID, ok := ipID.IPv4(); ok {
+		return r.FindByIPID(ipv4ID)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	location, err := r.snap.find6(ipID)
+	if err != nil {
+		return nil, fmt.Errorf("search IPv6 ID %x%016x: %w", ipID.Hi, ipID.Lo, err)
+	}
+	return location, nil
+}
+
+func openSnapshot(path string, verify bool) (*snapshot, error) {
+	data, err := mmapFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("map snapshot %s: %w", path, err)
+	}
+
+	snap, err := parseSnapshot(data, verify)
+	if err != nil {
+		_ = munmapFile(data)
+		return nil, fmt.Errorf("open snapshot %s: %w", path, err)
+	}
+
+	snap.info = newDatasetInfo(path, nil, int(snap.header.v4Count)+int(snap.header.v6Count), 0)
+	return snap, nil
+}
+
+// parseSnapshot checks the header and that the file is as long as it says.
+// With verify it also checks the body checksum and every table reference;
+// without, lookups check the references of the rows they return.
+func parseSnapshot(data []byte, verify bool) (*snapshot, error) {
+	if len(data) < snapshotHeaderSize+checksumSize || string(data[:8]) != snapshotMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidSnapshot)
+	}
+
+	header := snapshotHeader{
+		version:      binary.LittleEndian.Uint32(data[8:]),
+		flags:        binary.LittleEndian.Uint32(data[12:]),
+		v4Count:      binary.LittleEndian.Uint32(data[16:]),
+		v6Count:      binary.LittleEndian.Uint32(data[20:]),
+		countryCount: binary.LittleEndian.Uint32(data[24:]),
+		cityCount:    binary.LittleEndian.Uint32(data[28:]),
+		stringBytes:  binary.LittleEndian.Uint64(data[32:]),
+	}
+	if header.version != snapshotVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d (want %d)", ErrInvalidSnapshot, header.version, snapshotVersion)
+	}
+	want := binary.LittleEndian.Uint32(data[headerChecksum:])
+	if got := crc32.Checksum(data[:headerChecksum], crcTable); got != want {
+		return nil, fmt.Errorf("%w: header checksum mismatch", ErrInvalidSnapshot)
+	}
+	if header.stringBytes > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: string table larger than file", ErrInvalidSnapshot)
+	}
+
+	layout := header.layout()
+	if layout.size != len(data) {
+		return nil, fmt.Errorf("%w: size %d does not match header (%d)", ErrInvalidSnapshot, len(data), layout.size)
+	}
+
+	snap := &snapshot{data: data, header: header, layout: layout}
+	if !verify {
+		return snap, nil
+	}
+
+	want = binary.LittleEndian.Uint32(data[layout.checksum:])
+	if got := crc32.Checksum(data[:layout.checksum], crcTable); got != want {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+	if err := snap.checkReferences(); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// datasetInfo returns info, hashing the file the first time.
+func (s *snapshot) datasetInfo() domain.DatasetInfo {
+	s.infoOnce.Do(func() {
+		sum := sha256.Sum256(s.data)
+		s.info.SHA256 = hex.EncodeToString(sum[:])
+	})
+	return s.info
+}
+
+// checkReferences makes sure every index and string reference stays inside
+// the file.
+func (s *snapshot) checkReferences() error {
+	h, l := &s.header, &s.layout
+
+	for i := 0; i < int(h.v4Count); i++ {
+		if uint32(s.u16(l.v4Country, i)) >= h.countryCount {
+			return fmt.Errorf("%w: IPv4 row %d references unknown country", ErrInvalidSnapshot, i)
+		}
+		if s.u32(l.v4City, i) >= h.cityCount {
+			return fmt.Errorf("%w: IPv4 row %d references unknown city", ErrInvalidSnapshot, i)
+		}
+	}
+	for i := 0; i < int(h.v6Count); i++ {
+		if uint32(s.u16(l.v6Country, i)) >= h.countryCount {
+			return fmt.Errorf("%w: IPv6 row %d references unknown country", ErrInvalidSnapshot, i)
+		}
+		if s.u32(l.v6City, i) >= h.cityCount {
+			return fmt.Errorf("%w: IPv6 row %d references unknown city", ErrInvalidSnapshot, i)
+		}
+	}
+
+	for i := 0; i < int(h.countryCount); i++ {
+		entry := l.countries + i*countryEntrySize
+		if !s.validString(entry, 0) || !s.validString(entry, 1) {
+			return fmt.Errorf("%w: country %d has an out-of-range string", ErrInvalidSnapshot, i)
+		}
+	}
+	for i := 0; i < int(h.cityCount); i++ {
+		entry := l.cities + i*cityEntrySize
+		for field := 0; field < 4; field++ {
+			if !s.validString(entry, field) {
+				return fmt.Errorf("%w: city %d has an out-of-range string", ErrInvalidSnapshot, i)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) validString(entry, field int) bool {
+	off := uint64(binary.LittleEndian.Uint32(s.data[entry+field*8:]))
+	length := uint64(binary.LittleEndian.Uint32(s.data[entry+field*8+4:]))
+	return off+length <= s.header.stringBytes
+}
+
+func (s *snapshot) close() error {
+	if s.data == nil {
+		return nil
+	}
+	err := munmapFile(s.data)
+	s.data = nil
+	return err
+}
+
+func (s *snapshot) u16(section, i int) uint16 {
+	return binary.LittleEndian.Uint16(s.data[section+i*2:])
+}
+
+func (s *snapshot) u32(section, i int) uint32 {
+	return binary.LittleEndian.Uint32(s.data[section+i*4:])
+}
+
+func (s *snapshot) id6(section, i int) domain.IPv6ID {
+	off := section + i*16
+	return domain.IPv6ID{
+		Hi: binary.LittleEndian.Uint64(s.data[off:]),
+		Lo: binary.LittleEndian.Uint64(s.data[off+8:]),
+	}
+}
+
+func (s *snapshot) find4(ipID uint32) (*domain.Location, error) {
+	n := int(s.header.v4Count)
+	idx := sort.Search(n, func(i int) bool {
+		return s.u32(s.layout.v4Upper, i) >= ipID
+	})
+	if idx >= n || s.u32(s.layout.v4Lower, idx) > ipID {
+		return nil, domain.ErrLocationNotFound
+	}
+
+	location, err := s.attributes(s.u16(s.layout.v4Country, idx), s.u32(s.layout.v4City, idx))
+	if err != nil {
+		return nil, fmt.Errorf("IPv4 row %d: %w", idx, err)
+	}
+	location.LowerIPID = s.u32(s.layout.v4Lower, idx)
+	location.UpperIPID = s.u32(s.layout.v4Upper, idx)
+	return location, nil
+}
+
+func (s *snapshot) find6(ipID domain.IPv6ID) (*domain.Location, error) {
+	n := int(s.header.v6Count)
+	idx := sort.Search(n, func(i int) bool {
+		return s.id6(s.layout.v6Upper, i).Compare(ipID) >= 0
+	})
+	if idx >= n || s.id6(s.layout.v6Lower, idx).Compare(ipID) > 0 {
+		return nil, domain.ErrLocationNotFound
+	}
+
+	location, err := s.attributes(s.u16(s.layout.v6Country, idx), s.u32(s.layout.v6City, idx))
+	if err != nil {
+		return nil, fmt.Errorf("IPv6 row %d: %w", idx, err)
+	}
+	location.LowerIPv6ID = s.id6(s.layout.v6Lower, idx)
+	location.UpperIPv6ID = s.id6(s.layout.v6Upper, idx)
+	return location, nil
+}
+
+func (s *snapshot) str(entry, field int) string {
+	off := int(binary.LittleEndian.Uint32(s.data[entry+field*8:]))
+	length := int(binary.LittleEndian.Uint32(s.data[entry+field*8+4:]))
+	start := s.layout.strings + off
+	return string(s.data[start : start+length])
+}
+
+// attributes resolves a row's country and city. The references are checked
+// here too, since an unverified snapshot may hold any value.
+func (s *snapshot) attributes(countryIdx uint16, cityIdx uint32) (*domain.Location, error) {
+	if uint32(countryIdx) >= s.header.countryCount {
+		return nil, fmt.Errorf("%w: unknown country %d", ErrInvalidSnapshot, countryIdx)
+	}
+	if cityIdx >= s.header.cityCount {
+		return nil, fmt.Errorf("%w: unknown city %d", ErrInvalidSnapshot, cityIdx)
+	}
+	country := s.layout.countries + int(countryIdx)*countryEntrySize
+	city := s.layout.cities + int(cityIdx)*cityEntrySize
+	for field := 0; field < 4; field++ {
+		if (field < 2 && !s.validString(country, field)) || !s.validString(city, field) {
+			return nil, fmt.Errorf("%w: out-of-range string", ErrInvalidSnapshot)
+		}
+	}
+
+	return &domain.Location{
+		CountryCode: s.str(country, 0),
+		Country:     s.str(country, 1),
+		Region:      s.str(city, 0),
+		City:        s.str(city, 1),
+		ZipCode:     s.str(city, 2),
+		TimeZone:    s.str(city, 3),
+		Latitude:    math.Float64frombits(binary.LittleEndian.Uint64(s.data[city+32:])),
+		Longitude:   math.Float64frombits(binary.LittleEndian.Uint64(s.data[city+40:])),
+	}, nil
+}
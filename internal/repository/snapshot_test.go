@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+const snapshotTestCSV = `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"0","281470681743359","-","-","-","-","0.000000","0.000000","-","-"
+"281470698520576","281470698520831","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"281470698520832","281470698521599","CN","China","Fujian","Fuzhou","26.06139","119.30611","-","08:00"
+"281470816487432","281470816487432","US","United States","California","Mountain View","37.405992","-122.078515","94035","-07:00"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","JP","Japan","Tokyo","Tokyo","35.689497","139.692317","100-0001","+09:00"`
+
+func writeTestSnapshot(t *testing.T, dir string) (*MemoryRepository, string) {
+	t.Helper()
+
+	csvPath := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(csvPath, []byte(snapshotTestCSV), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	memRepo, err := NewMemoryRepository(csvPath)
+	if err != nil {
+		t.Fatalf("Failed to create memory repository: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, memRepo); err != nil {
+		t.Fatalf("WriteSnapshot() error = %v", err)
+	}
+
+	snapPath := filepath.Join(dir, "data.snap")
+	if err := os.WriteFile(snapPath, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	return memRepo, snapPath
+}
+
+func TestSnapshotRepository_MatchesMemoryRepository(t *testing.T) {
+	memRepo, snapPath := writeTestSnapshot(t, t.TempDir())
+
+	snapRepo, err := NewSnapshotRepository(snapPath)
+	if err != nil {
+		t.Fatalf("NewSnapshotRepository() error = %v", err)
+	}
+	defer func() {
+		if err := snapRepo.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	}()
+
+	if snapRepo.Len() != memRepo.Len() {
+		t.Errorf("Len() = %d, want %d", snapRepo.Len(), memRepo.Len())
+	}
+	data, err := os.ReadFile(snapPath)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if info := snapRepo.DatasetInfo(); info.Rows != memRepo.Len() || info.Path != snapPath || info.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("DatasetInfo() = %+v, want %d rows of %s with SHA-256 %x", info, memRepo.Len(), snapPath, sum)
+	}
+
+	for _, ipID := range []uint32{0, 16777216, 16777300, 16777471, 16777472, 16778239, 16778240, 134744072, 134744073} {
+		want, wantErr := memRepo.FindByIPID(ipID)
+		got, gotErr := snapRepo.FindByIPID(ipID)
+
+		if (wantErr != nil) != (gotErr != nil) {
+			t.Errorf("FindByIPID(%d) error = %v, memory repository error = %v", ipID, gotErr, wantErr)
+			continue
+		}
+		if gotErr != nil {
+			if !errors.Is(gotErr, domain.ErrLocationNotFound) {
+				t.Errorf("FindByIPID(%d) error should wrap domain.ErrLocationNotFound, got %v", ipID, gotErr)
+			}
+			continue
+		}
+		if *got != *want {
+			t.Errorf("FindByIPID(%d) = %+v, want %+v", ipID, *got, *want)
+		}
+	}
+
+	for _, ipID := range []domain.IPv6ID{
+		{Lo: 5},
+		{Hi: 0x20010db800000000, Lo: 1},
+		{Hi: 0x20010db9ffffffff},
+		domain.IPv4ToIPv6ID(134744072),
+	} {
+		want, wantErr := memRepo.FindByIPv6ID(ipID)
+		got, gotErr := snapRepo.FindByIPv6ID(ipID)
+
+		if (wantErr != nil) != (gotErr != nil) {
+			t.Errorf("FindByIPv6ID(%v) error = %v, memory repository error = %v", ipID, gotErr, wantErr)
+			continue
+		}
+		if gotErr == nil && *got != *want {
+			t.Errorf("FindByIPv6ID(%v) = %+v, want %+v", ipID, *got, *want)
+		}
+	}
+}
+
+func TestNewSnapshotRepository_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	_, snapPath := writeTestSnapshot(t, dir)
+
+	valid, err := os.ReadFile(snapPath)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func([]byte) []byte
+		// verifyOnly failures are only caught by WithSnapshotVerification.
+		verifyOnly bool
+	}{
+		{
+			name:   "flipped header byte fails the header checksum",
+			mutate: func(b []byte) []byte { b[40] ^= 0xff; return b },
+		},
+		{
+			name:       "flipped body byte fails the checksum",
+			mutate:     func(b []byte) []byte { b[snapshotHeaderSize+1] ^= 0xff; return b },
+			verifyOnly: true,
+		},
+		{
+			name:   "truncated file",
+			mutate: func(b []byte) []byte { return b[:len(b)-10] },
+		},
+		{
+			name:   "wrong magic",
+			mutate: func(b []byte) []byte { copy(b, "NOTASNAP"); return b },
+		},
+		{
+			name:   "unsupported version",
+			mutate: func(b []byte) []byte { b[8] = 99; return b },
+		},
+		{
+			name:   "CSV instead of a snapshot",
+			mutate: func(b []byte) []byte { return []byte(snapshotTestCSV) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "broken.snap")
+			data := tt.mutate(append([]byte(nil), valid...))
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatalf("Failed to write snapshot: %v", err)
+			}
+
+			_, err := NewSnapshotRepository(path, WithSnapshotVerification())
+			if !errors.Is(err, ErrInvalidSnapshot) {
+				t.Errorf("NewSnapshotRepository(WithSnapshotVerification()) error = %v, want ErrInvalidSnapshot", err)
+			}
+
+			repo, err := NewSnapshotRepository(path)
+			if tt.verifyOnly {
+				if err != nil {
+					t.Errorf("NewSnapshotRepository() error = %v, want only the header checked", err)
+				} else {
+					_ = repo.Close()
+				}
+			} else if !errors.Is(err, ErrInvalidSnapshot) {
+				t.Errorf("NewSnapshotRepository() error = %v, want ErrInvalidSnapshot", err)
+			}
+		})
+	}
+
+	if _, err := NewSnapshotRepository(filepath.Join(dir, "missing.snap")); err == nil {
+		t.Error("NewSnapshotRepository() with a missing file should fail")
+	}
+}
+
+// Without verification a bad reference is only found by the lookup that
+// reaches it, which must fail instead of reading outside the tables.
+func TestSnapshotRepository_CorruptRow(t *testing.T) {
+	dir := t.TempDir()
+	_, snapPath := writeTestSnapshot(t, dir)
+
+	data, err := os.ReadFile(snapPath)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	snap, err := parseSnapshot(data, true)
+	if err != nil {
+		t.Fatalf("parseSnapshot() error = %v", err)
+	}
+	// Row 0 is Los Angeles (16777216); the other rows stay intact.
+	binary.LittleEndian.PutUint32(data[snap.layout.v4City:], 0xffffffff)
+	if err := os.WriteFile(snapPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	if _, err := NewSnapshotRepository(snapPath, WithSnapshotVerification()); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("NewSnapshotRepository(WithSnapshotVerification()) error = %v, want ErrInvalidSnapshot", err)
+	}
+
+	repo, err := NewSnapshotRepository(snapPath)
+	if err != nil {
+		t.Fatalf("NewSnapshotRepository() error = %v", err)
+	}
+	defer func() {
+		_ = repo.Close()
+	}()
+
+	if _, err := repo.FindByIPID(16777216); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("FindByIPID() on the corrupt row error = %v, want ErrInvalidSnapshot", err)
+	}
+	if location, err := repo.FindByIPID(16778000); err != nil || location.City != "Fuzhou" {
+		t.Errorf("FindByIPID() on an intact row = %+v, %v, want Fuzhou", location, err)
+	}
+}
+
+func TestSnapshotRepository_Reload(t *testing.T) {
+	dir := t.TempDir()
+	_, snapPath := writeTestSnapshot(t, dir)
+
+	repo, err := NewSnapshotRepository(snapPath)
+	if err != nil {
+		t.Fatalf("NewSnapshotRepository() error = %v", err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	brokenPath := filepath.Join(dir, "broken.snap")
+	if err := os.WriteFile(brokenPath, []byte("garbage"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := repo.Reload(brokenPath); err == nil {
+		t.Error("Reload() with a broken snapshot should fail")
+	}
+
+	if err := repo.Reload(""); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	got, err := repo.FindByIPID(134744072)
+	if err != nil {
+		t.Fatalf("FindByIPID() after reload error = %v", err)
+	}
+	if got.City != "Mountain View" {
+		t.Errorf("FindByIPID() after reload City = %v, want Mountain View", got.City)
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := repo.Reload(""); err != nil && !errors.Is(err, domain.ErrReloadInProgress) {
+				t.Errorf("concurrent Reload() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkSnapshotRepository_FindByIPID(b *testing.B) {
+	dir := b.TempDir()
+	csvPath := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(csvPath, []byte(snapshotTestCSV), 0644); err != nil {
+		b.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	memRepo, err := NewMemoryRepository(csvPath)
+	if err != nil {
+		b.Fatalf("Failed to create memory repository: %v", err)
+	}
+
+	snapPath := filepath.Join(dir, "data.snap")
+	file, err := os.Create(snapPath)
+	if err != nil {
+		b.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if err := WriteSnapshot(file, memRepo); err != nil {
+		b.Fatalf("WriteSnapshot() error = %v", err)
+	}
+	if err := file.Close(); err != nil {
+		b.Fatalf("Failed to close snapshot: %v", err)
+	}
+
+	repo, err := NewSnapshotRepository(snapPath)
+	if err != nil {
+		b.Fatalf("NewSnapshotRepository() error = %v", err)
+	}
+	defer func() { _ = repo.Close() }()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = repo.FindByIPID(134744072)
+	}
+}
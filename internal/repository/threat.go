@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// IP2Proxy column positions. Every edition starts with ip_from and ip_to;
+// PX2 and up add proxy_type, PX6 and up usage_type and PX11 provider.
+const (
+	proxyTypeColumn = 2
+	usageTypeColumn = 9
+	providerColumn  = 14
+)
+
+// ThreatRepository classifies addresses from an IP2Proxy CSV and a Tor exit
+// list, either of which may be left out. Both are held in memory and
+// reloaded together.
+type ThreatRepository struct {
+	proxyPath string
+	torPath   string
+	opts      options
+	data      atomic.Pointer[threatDataset]
+	reloadMu  sync.Mutex
+}
+
+// threatDataset holds the proxy ranges, each pointing into the deduplicated
+// proxies table, and the Tor exit addresses.
+type threatDataset struct {
+	proxyRanges rangeTables
+	proxies     []proxyInfo
+	tor         rangeTables
+}
+
+type proxyInfo struct {
+	proxyType string
+	usageType string
+	provider  string
+}
+
+// NewThreatRepository loads proxyCSVPath and torListPath. An empty path
+// leaves that source out, but at least one is required.
+func NewThreatRepository(proxyCSVPath, torListPath string, opts ...Option) (*ThreatRepository, error) {
+	if proxyCSVPath == "" && torListPath == "" {
+		return nil, fmt.Errorf("no proxy CSV or Tor exit list given")
+	}
+
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	data, err := loadThreats(proxyCSVPath, torListPath, o)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &ThreatRepository{
+		proxyPath: proxyCSVPath,
+		torPath:   torListPath,
+		opts:      o,
+	}
+	repo.data.Store(data)
+
+	return repo, nil
+}
+
+// Reload re-reads both sources and atomically replaces the served data. A
+// non-empty path replaces the proxy CSV. On any error the previous data
+// keeps being served.
+func (r *ThreatRepository) Reload(path string) error {
+	if !r.reloadMu.TryLock() {
+		return domain.ErrReloadInProgress
+	}
+	defer r.reloadMu.Unlock()
+
+	proxyPath := r.proxyPath
+	if path != "" {
+		proxyPath = path
+	}
+
+	data, err := loadThreats(proxyPath, r.torPath, r.opts)
+	if err != nil {
+		return err
+	}
+
+	r.data.Store(data)
+	r.proxyPath = proxyPath
+
+	return nil
+}
+
+func (r *ThreatRepository) FindThreatByIPID(ipID uint32) (*domain.Threat, error) {
+	data := r.data.Load()
+
+	threat := &domain.Threat{}
+	if idx, ok := data.proxyRanges.v4.find(ipID); ok {
+		data.proxy(threat, data.proxyRanges.v4.value[idx])
+	}
+	if _, ok := data.tor.v4.find(ipID); ok {
+		threat.IsTor = true
+	}
+	return threat, nil
+}
+
+// FindThreatByIPv6ID resolves a 128-bit IP number. IPv4-mapped addresses are
+// answered from the IPv4 tables.
+func (r *ThreatRepository) FindThreatByIPv6ID(ipID domain.IPv6ID) (*domain.Threat, error) {
+	if ipv4ID, ok := ipID.IPv4(); ok {
+		return r.FindThreatByIPID(ipv4ID)
+	}
+
+	data := r.data.Load()
+
+	threat := &domain.Threat{}
+	if idx, ok := data.proxyRanges.v6.find(ipID); ok {
+		data.proxy(threat, data.proxyRanges.v6.value[idx])
+	}
+	if _, ok := data.tor.v6.find(ipID); ok {
+		threat.IsTor = true
+	}
+	return threat, nil
+}
+
+// proxy fills threat from a listed proxy range. IP2Proxy marks Tor exits
+// with the TOR proxy type and data centers with DCH as proxy or usage type.
+func (d *threatDataset) proxy(threat *domain.Threat, idx uint32) {
+	p := &d.proxies[idx]
+
+	threat.IsProxy = true
+	threat.ProxyType = p.proxyType
+	threat.UsageType = p.usageType
+	threat.Provider = p.provider
+
+	switch p.proxyType {
+	case "VPN":
+		threat.IsVPN = true
+	case "TOR":
+		threat.IsTor = true
+	case "DCH":
+		threat.IsHosting = true
+	}
+	for _, usage := range strings.Split(p.usageType, "/") {
+		if usage == "DCH" {
+			threat.IsHosting = true
+		}
+	}
+}
+
+func loadThreats(proxyCSVPath, torListPath string, opts options) (*threatDataset, error) {
+	data := &threatDataset{}
+
+	if proxyCSVPath != "" {
+		if err := data.loadProxyCSV(proxyCSVPath, opts); err != nil {
+			return nil, fmt.Errorf("load proxy CSV: %w", err)
+		}
+		if data.proxyRanges.Len() == 0 {
+			return nil, fmt.Errorf("validate %s: dataset has no valid rows", proxyCSVPath)
+		}
+	}
+
+	if torListPath != "" {
+		if err := data.loadTorList(torListPath); err != nil {
+			return nil, fmt.Errorf("load Tor exit list: %w", err)
+		}
+	}
+
+	return data, nil
+}
+
+// loadProxyCSV streams an IP2Proxy CSV of any edition (PX1 to PX11, IPv4 or
+// IPv6). Malformed rows and rows with proxy type "-" are skipped.
+func (d *threatDataset) loadProxyCSV(csvPath string, opts options) error {
+	file, err := openDatasetFile(csvPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to close proxy CSV file: %v\n", closeErr)
+		}
+	}()
+
+	fileSize := file.size
+	counter := &countingReader{r: file}
+	reader := csv.NewReader(bufio.NewReaderSize(counter, 1<<20))
+	reader.ReuseRecord = true
+	reader.FieldsPerRecord = -1
+
+	progress := newLoadProgress(csvPath, fileSize, opts.progressInterval)
+
+	proxies := make(map[proxyInfo]uint32)
+	names := newInterner()
+
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			if line == 0 {
+				return fmt.Errorf("CSV file is empty")
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read CSV: %w", err)
+		}
+
+		if line == 0 {
+			continue
+		}
+
+		progress.row(counter.n)
+
+		if len(record) < 4 {
+			continue
+		}
+		lower, err := parseIPNumber(record[0])
+		if err != nil {
+			continue
+		}
+		upper, err := parseIPNumber(record[1])
+		if err != nil {
+			continue
+		}
+
+		// PX1 has only the country after the range; everything is a proxy.
+		var p proxyInfo
+		if len(record) > 4 {
+			p.proxyType = proxyColumn(record, proxyTypeColumn)
+			if p.proxyType == "" {
+				continue
+			}
+		}
+		p.usageType = proxyColumn(record, usageTypeColumn)
+		p.provider = proxyColumn(record, providerColumn)
+
+		idx, ok := proxies[p]
+		if !ok {
+			p.proxyType = names.intern(p.proxyType)
+			p.usageType = names.intern(p.usageType)
+			p.provider = names.intern(p.provider)
+			idx = uint32(len(d.proxies))
+			d.proxies = append(d.proxies, p)
+			proxies[p] = idx
+		}
+
+		d.proxyRanges.add(lower, upper, idx)
+	}
+
+	d.proxyRanges.sort()
+	progress.done(counter.n, d.proxyRanges.Len())
+
+	return nil
+}
+
+// proxyColumn returns column i of record, or "" when the edition doesn't
+// have it or IP2Proxy left it blank ("-").
+func proxyColumn(record []string, i int) string {
+	if i >= len(record) || record[i] == "-" {
+		return ""
+	}
+	return record[i]
+}
+
+// loadTorList reads a Tor exit list: one address, range or CIDR per line as
+// in the Tor Project's bulk exit list, or the "ExitAddress <ip> <date>"
+// lines of its exit-addresses file. Blank lines, comments and other
+// exit-addresses fields are ignored; anything else malformed is an error.
+func (d *threatDataset) loadTorList(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open file %s: %w", path, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		switch fields[0] {
+		case "ExitAddress":
+			if len(fields) < 2 {
+				return fmt.Errorf("line %d: ExitAddress without an address", line)
+			}
+			text = fields[1]
+		case "ExitNode", "Published", "LastStatus":
+			continue
+		}
+
+		first, last, err := parseNetwork(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		d.tor.add(addrKey(first), addrKey(last), 0)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	// Lists may repeat addresses or nest them in ranges and CIDRs.
+	d.tor.sort()
+	d.tor.merge()
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+const threatProxyCSV = `"ip_from","ip_to","proxy_type","country_code","country_name","region_name","city_name","isp","domain","usage_type","asn","as","last_seen","threat","provider"
+"16777216","16777471","VPN","US","United States","California","Los Angeles","Example Hosting","example.com","DCH","64500","Example Hosting","10","-","ExampleVPN"
+"83886080","83886335","TOR","DE","Germany","Berlin","Berlin","-","-","-","-","-","3","-","-"
+"134744064","134744319","PUB","US","United States","California","Mountain View","Example Mobile","example.net","MOB/ISP","64501","Example Mobile","1","SPAM","-"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","DCH","JP","Japan","Tokyo","Tokyo","Example Cloud","example.jp","DCH/CDN","64502","Example Cloud","7","-","-"
+"not-a-number","1","VPN","US","United States","-","-","-","-","-","-","-","-","-","-"`
+
+const threatTorList = `# Tor exit addresses
+10.0.0.1
+ExitNode 0011BD2485AD45D984EC4159C88FC066E5E3300E
+Published 2024-01-01 00:00:00
+ExitAddress 10.0.0.2 2024-01-01 00:10:00
+LastStatus 2024-01-01 01:00:00
+2001:db8:1::5
+`
+
+func newTestThreatRepository(t *testing.T, proxyCSV, torList string) (*ThreatRepository, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		if data == "" {
+			return ""
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	proxyPath := write("proxy.csv", proxyCSV)
+	torPath := write("tor.txt", torList)
+	repo, err := NewThreatRepository(proxyPath, torPath)
+	if err != nil {
+		t.Fatalf("NewThreatRepository() error = %v", err)
+	}
+	return repo, proxyPath, torPath
+}
+
+func TestThreatRepository_FindThreatByIPID(t *testing.T) {
+	repo, _, _ := newTestThreatRepository(t, threatProxyCSV, threatTorList)
+
+	tests := []struct {
+		name string
+		ipID uint32
+		want domain.Threat
+	}{
+		{
+			name: "VPN in a data center",
+			ipID: 16777217,
+			want: domain.Threat{IsProxy: true, IsVPN: true, IsHosting: true, ProxyType: "VPN", UsageType: "DCH", Provider: "ExampleVPN"},
+		},
+		{
+			name: "Tor proxy type",
+			ipID: 83886081,
+			want: domain.Threat{IsProxy: true, IsTor: true, ProxyType: "TOR"},
+		},
+		{
+			name: "public proxy on a mobile network",
+			ipID: 134744072,
+			want: domain.Threat{IsProxy: true, ProxyType: "PUB", UsageType: "MOB/ISP"},
+		},
+		{
+			name: "Tor exit list address",
+			ipID: 167772161,
+			want: domain.Threat{IsTor: true},
+		},
+		{
+			name: "Tor exit list ExitAddress line",
+			ipID: 167772162,
+			want: domain.Threat{IsTor: true},
+		},
+		{
+			name: "unlisted",
+			ipID: 167772163,
+			want: domain.Threat{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.FindThreatByIPID(tt.ipID)
+			if err != nil {
+				t.Fatalf("FindThreatByIPID() error = %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("FindThreatByIPID() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreatRepository_FindThreatByIPv6ID(t *testing.T) {
+	repo, _, _ := newTestThreatRepository(t, threatProxyCSV, threatTorList)
+
+	got, err := repo.FindThreatByIPv6ID(domain.IPv6ID{Hi: 0x20010db8_00010000, Lo: 5})
+	if err != nil {
+		t.Fatalf("FindThreatByIPv6ID() error = %v", err)
+	}
+	want := domain.Threat{IsProxy: true, IsTor: true, IsHosting: true, ProxyType: "DCH", UsageType: "DCH/CDN"}
+	if *got != want {
+		t.Errorf("FindThreatByIPv6ID() = %+v, want %+v", *got, want)
+	}
+
+	got, err = repo.FindThreatByIPv6ID(domain.IPv4ToIPv6ID(16777217))
+	if err != nil || !got.IsVPN {
+		t.Errorf("FindThreatByIPv6ID() for an IPv4-mapped address = %+v, %v, want a VPN", got, err)
+	}
+}
+
+func TestThreatRepository_OverlappingTorList(t *testing.T) {
+	torList := `1.0.0.0/8
+1.2.3.4
+1.255.255.255
+2.0.0.5-2.0.0.20
+2.0.0.0-2.0.0.10
+2.0.0.21
+2001:db8:1::5
+2001:db8::/32
+`
+	repo, _, _ := newTestThreatRepository(t, "", torList)
+
+	// 1.0.0.0/8 and 2.0.0.0-2.0.0.21 are adjacent, so one range is left
+	// per family.
+	if got := repo.data.Load().tor.Len(); got != 2 {
+		t.Errorf("tor ranges = %d, want 2 after merging", got)
+	}
+
+	for _, tt := range []struct {
+		ip   string
+		want bool
+	}{
+		{"1.0.0.0", true},
+		{"1.100.0.0", true},
+		{"1.255.255.255", true},
+		{"2.0.0.15", true},
+		{"2.0.0.21", true},
+		{"2.0.0.22", false},
+		{"0.255.255.255", false},
+		{"2001:db8:ffff::1", true},
+		{"2001:db9::", false},
+	} {
+		got, err := repo.FindThreatByIPv6ID(addrKey(netip.MustParseAddr(tt.ip)))
+		if err != nil {
+			t.Fatalf("FindThreatByIPv6ID(%s) error = %v", tt.ip, err)
+		}
+		if got.IsTor != tt.want {
+			t.Errorf("FindThreatByIPv6ID(%s).IsTor = %v, want %v", tt.ip, got.IsTor, tt.want)
+		}
+	}
+}
+
+func TestThreatRepository_Editions(t *testing.T) {
+	px1 := `"ip_from","ip_to","country_code","country_name"
+"16777216","16777471","US","United States"`
+	repo, _, _ := newTestThreatRepository(t, px1, "")
+	got, err := repo.FindThreatByIPID(16777217)
+	if err != nil || *got != (domain.Threat{IsProxy: true}) {
+		t.Errorf("FindThreatByIPID() with PX1 = %+v, %v, want a proxy without details", got, err)
+	}
+
+	px2 := `"ip_from","ip_to","proxy_type","country_code","country_name"
+"16777216","16777471","VPN","US","United States"
+"16777472","16777727","-","US","United States"`
+	repo, _, _ = newTestThreatRepository(t, px2, "")
+	got, err = repo.FindThreatByIPID(16777217)
+	if err != nil || *got != (domain.Threat{IsProxy: true, IsVPN: true, ProxyType: "VPN"}) {
+		t.Errorf("FindThreatByIPID() with PX2 = %+v, %v, want a VPN", got, err)
+	}
+	got, err = repo.FindThreatByIPID(16777473)
+	if err != nil || *got != (domain.Threat{}) {
+		t.Errorf("FindThreatByIPID() for proxy type \"-\" = %+v, %v, want unlisted", got, err)
+	}
+}
+
+func TestThreatRepository_Reload(t *testing.T) {
+	repo, _, torPath := newTestThreatRepository(t, "", "10.0.0.1\n")
+
+	if err := os.WriteFile(torPath, []byte("10.0.0.9\n"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite Tor exit list: %v", err)
+	}
+	if err := repo.Reload(""); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got, _ := repo.FindThreatByIPID(167772161); got.IsTor {
+		t.Error("FindThreatByIPID() after reload still lists the old exit")
+	}
+	if got, _ := repo.FindThreatByIPID(167772169); !got.IsTor {
+		t.Error("FindThreatByIPID() after reload doesn't list the new exit")
+	}
+
+	if err := os.WriteFile(torPath, []byte("not-an-address\n"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite Tor exit list: %v", err)
+	}
+	if err := repo.Reload(""); err == nil {
+		t.Error("Reload() of a malformed Tor exit list should fail")
+	}
+	if got, _ := repo.FindThreatByIPID(167772169); !got.IsTor {
+		t.Error("FindThreatByIPID() after failed reload should keep the previous list")
+	}
+}
+
+func TestNewThreatRepository_NoSources(t *testing.T) {
+	if _, err := NewThreatRepository("", ""); err == nil {
+		t.Error("NewThreatRepository() without sources should fail")
+	}
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// ErrValidationFailed is returned in strict mode when a dataset has more
+// data-quality issues than its limits allow.
+var ErrValidationFailed = errors.New("dataset failed validation")
+
+// maxSampleLines is how many offending line numbers each issue keeps.
+const maxSampleLines = 10
+
+// Issue counts one kind of data-quality problem and keeps the CSV line
+// numbers of the first occurrences.
+type Issue struct {
+	Count       int   `json:"count"`
+	SampleLines []int `json:"sample_lines,omitempty"`
+}
+
+func (i *Issue) add(line int) {
+	i.Count++
+	if len(i.SampleLines) < maxSampleLines {
+		i.SampleLines = append(i.SampleLines, line)
+	}
+}
+
+// ValidationReport describes the data quality of a loaded CSV.
+//
+// Skipped lines and inverted ranges are not loaded. Overlapping ranges,
+// duplicate rows and invalid country codes are loaded as they are, and rows
+// with invalid coordinates are loaded at 0,0, so the report is the only place
+// they show up. Gaps between consecutive ranges are
+// informational: lookups inside them simply return not found.
+type ValidationReport struct {
+	Rows                int   `json:"rows"`
+	Loaded              int   `json:"loaded"`
+	SkippedLines        Issue `json:"skipped_lines"`
+	InvertedRanges      Issue `json:"inverted_ranges"`
+	OverlappingRanges   Issue `json:"overlapping_ranges"`
+	DuplicateRows       Issue `json:"duplicate_rows"`
+	InvalidCountryCodes Issue `json:"invalid_country_codes"`
+	InvalidCoordinates  Issue `json:"invalid_coordinates"`
+	Gaps                Issue `json:"gaps"`
+}
+
+// ValidationLimits is the largest number of each issue a dataset may have in
+// strict mode.
+type ValidationLimits struct {
+	SkippedLines        int
+	InvertedRanges      int
+	OverlappingRanges   int
+	DuplicateRows       int
+	InvalidCountryCodes int
+	InvalidCoordinates  int
+}
+
+// UniformValidationLimits allows up to n issues of every kind.
+func UniformValidationLimits(n int) ValidationLimits {
+	return ValidationLimits{
+		SkippedLines:        n,
+		InvertedRanges:      n,
+		OverlappingRanges:   n,
+		DuplicateRows:       n,
+		InvalidCountryCodes: n,
+		InvalidCoordinates:  n,
+	}
+}
+
+// Check returns an error wrapping ErrValidationFailed that lists every issue
+// over its limit.
+func (r *ValidationReport) Check(limits ValidationLimits) error {
+	var exceeded []string
+	check := func(name string, issue Issue, limit int) {
+		if issue.Count > limit {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %d > %d (lines %v)", name, issue.Count, limit, issue.SampleLines))
+		}
+	}
+
+	check("skipped lines", r.SkippedLines, limits.SkippedLines)
+	check("inverted ranges", r.InvertedRanges, limits.InvertedRanges)
+	check("overlapping ranges", r.OverlappingRanges, limits.OverlappingRanges)
+	check("duplicate rows", r.DuplicateRows, limits.DuplicateRows)
+	check("invalid country codes", r.InvalidCountryCodes, limits.InvalidCountryCodes)
+	check("invalid coordinates", r.InvalidCoordinates, limits.InvalidCoordinates)
+
+	if len(exceeded) > 0 {
+		return fmt.Errorf("%w: %s", ErrValidationFailed, strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+// HasIssues reports whether anything other than gaps was found.
+func (r *ValidationReport) HasIssues() bool {
+	return r.SkippedLines.Count+r.InvertedRanges.Count+r.OverlappingRanges.Count+
+		r.DuplicateRows.Count+r.InvalidCountryCodes.Count+r.InvalidCoordinates.Count > 0
+}
+
+func (r *ValidationReport) String() string {
+	return fmt.Sprintf("rows=%d loaded=%d skipped=%d inverted=%d overlaps=%d duplicates=%d invalid_country_codes=%d invalid_coordinates=%d gaps=%d",
+		r.Rows, r.Loaded, r.SkippedLines.Count, r.InvertedRanges.Count, r.OverlappingRanges.Count,
+		r.DuplicateRows.Count, r.InvalidCountryCodes.Count, r.InvalidCoordinates.Count, r.Gaps.Count)
+}
+
+// validCountryCode accepts ISO 3166-1 alpha-2 codes and the "-" IP2Location
+// uses for reserved and unassigned ranges.
+func validCountryCode(code string) bool {
+	if code == "-" {
+		return true
+	}
+	return len(code) == 2 &&
+		code[0] >= 'A' && code[0] <= 'Z' &&
+		code[1] >= 'A' && code[1] <= 'Z'
+}
+
+// isInverted reports whether a parsed row ends before it starts.
+func isInverted(location *domain.Location) bool {
+	if location.IsIPv6() {
+		return location.UpperIPv6ID.Less(location.LowerIPv6ID)
+	}
+	return location.UpperIPID < location.LowerIPID
+}
+
+// checkRanges scans the sorted tables for overlaps, duplicates and gaps. It
+// needs the line column, which is dropped afterwards.
+func (d *dataset) checkRanges(report *ValidationReport) {
+	t4 := &d.v4
+	var maxUpper4 uint32
+	for i := range t4.Len() {
+		if i > 0 {
+			switch {
+			case t4.lower[i] == t4.lower[i-1] && t4.upper[i] == t4.upper[i-1] &&
+				t4.country[i] == t4.country[i-1] && t4.city[i] == t4.city[i-1]:
+				report.DuplicateRows.add(int(t4.line[i]))
+			case t4.lower[i] <= maxUpper4:
+				report.OverlappingRanges.add(int(t4.line[i]))
+			case t4.lower[i] > maxUpper4+1:
+				report.Gaps.add(int(t4.line[i]))
+			}
+		}
+		maxUpper4 = max(maxUpper4, t4.upper[i])
+	}
+
+	t6 := &d.v6
+	var maxUpper6 domain.IPv6ID
+	for i := range t6.Len() {
+		if i > 0 {
+			switch {
+			case t6.lower[i] == t6.lower[i-1] && t6.upper[i] == t6.upper[i-1] &&
+				t6.country[i] == t6.country[i-1] && t6.city[i] == t6.city[i-1]:
+				report.DuplicateRows.add(int(t6.line[i]))
+			case t6.lower[i].Compare(maxUpper6) <= 0:
+				report.OverlappingRanges.add(int(t6.line[i]))
+			case t6.lower[i].Compare(maxUpper6.Next()) > 0:
+				report.Gaps.add(int(t6.line[i]))
+			}
+		}
+		if maxUpper6.Less(t6.upper[i]) {
+			maxUpper6 = t6.upper[i]
+		}
+	}
+
+	t4.line = nil
+	t6.line = nil
+}
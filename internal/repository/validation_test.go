@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const validationTestCSV = `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777400","16777500","CN","China","Fujian","Fuzhou","26.06139","119.30611","-","08:00"
+"16778239","16777472","CN","China","Fujian","Fuzhou","26.06139","119.30611","-","08:00"
+"not a number","16779263","AU","Australia","Queensland","Brisbane","-27.46794","153.02809","4000","10:00"
+"16779264","16779300","xx","Nowhere","-","-","0","0","-","-"
+"42540766411282592856903984951653826560","42540766411282592856903984951653826815","JP","Japan","Tokyo","Tokyo","35.689497","139.692317","100-0001","+09:00"
+"42540766411282592856903984951653826600","42540766411282592856903984951653826700","JP","Japan","Tokyo","Tokyo","35.689497","139.692317","100-0001","+09:00"
+"16779301","16779400","AU","Australia","Victoria","Melbourne","","144.963171","3000","+10:00"`
+
+func writeValidationCSV(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(validationTestCSV), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	return path
+}
+
+func TestNewMemoryRepository_ValidationReport(t *testing.T) {
+	repo, err := NewMemoryRepository(writeValidationCSV(t))
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+
+	want := ValidationReport{
+		Rows:                9,
+		Loaded:              7,
+		SkippedLines:        Issue{Count: 1, SampleLines: []int{6}},
+		InvertedRanges:      Issue{Count: 1, SampleLines: []int{5}},
+		OverlappingRanges:   Issue{Count: 2, SampleLines: []int{4, 9}},
+		DuplicateRows:       Issue{Count: 1, SampleLines: []int{3}},
+		InvalidCountryCodes: Issue{Count: 1, SampleLines: []int{7}},
+		InvalidCoordinates:  Issue{Count: 1, SampleLines: []int{10}},
+		Gaps:                Issue{Count: 1, SampleLines: []int{7}},
+	}
+	if got := repo.ValidationReport(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidationReport() = %+v, want %+v", got, want)
+	}
+
+	// A row with invalid coordinates still locates its range.
+	location, err := repo.FindByIPID(16779350)
+	if err != nil {
+		t.Fatalf("FindByIPID() error = %v", err)
+	}
+	if location.City != "Melbourne" || location.Latitude != 0 || location.Longitude != 0 {
+		t.Errorf("FindByIPID() = %s at %v,%v, want Melbourne at 0,0", location.City, location.Latitude, location.Longitude)
+	}
+}
+
+func TestNewMemoryRepository_StrictValidation(t *testing.T) {
+	path := writeValidationCSV(t)
+
+	if _, err := NewMemoryRepository(path, WithStrictValidation(ValidationLimits{})); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("NewMemoryRepository() error = %v, want ErrValidationFailed", err)
+	}
+
+	if _, err := NewMemoryRepository(path, WithStrictValidation(UniformValidationLimits(2))); err != nil {
+		t.Errorf("NewMemoryRepository() with lenient limits error = %v", err)
+	}
+}
+
+func TestMemoryRepository_Reload_StrictValidation(t *testing.T) {
+	dir := t.TempDir()
+	goodPath := filepath.Join(dir, "good.csv")
+	goodData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"`
+	if err := os.WriteFile(goodPath, []byte(goodData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+
+	repo, err := NewMemoryRepository(goodPath, WithStrictValidation(ValidationLimits{}))
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+
+	if err := repo.Reload(writeValidationCSV(t)); !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("Reload() error = %v, want ErrValidationFailed", err)
+	}
+	if repo.Len() != 1 {
+		t.Errorf("Len() = %d after failed reload, want the previous 1", repo.Len())
+	}
+}
+
+func TestValidCountryCode(t *testing.T) {
+	for code, want := range map[string]bool{"US": true, "-": true, "us": false, "USA": false, "": false, "U1": false} {
+		if got := validCountryCode(code); got != want {
+			t.Errorf("validCountryCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
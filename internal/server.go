@@ -1,17 +1,26 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	v1 "arena-backend-challenge/api/v1"
 	"arena-backend-challenge/config"
 	_ "arena-backend-challenge/docs"
+	"arena-backend-challenge/internal/domain"
 	"arena-backend-challenge/internal/handler"
+	"arena-backend-challenge/internal/middleware"
 	"arena-backend-challenge/internal/repository"
 	"arena-backend-challenge/internal/service"
+	"arena-backend-challenge/internal/updater"
+	"arena-backend-challenge/pkg/filewatch"
 	"arena-backend-challenge/pkg/logger"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
@@ -19,61 +28,290 @@ import (
 const Version = "1.0.0"
 
 type Server struct {
-	config          *config.Config
-	locationHandler *handler.LocationHandler
-	startTime       time.Time
+	config *config.Config
+	// reloader reloads every dataset; watched lists each file served with
+	// the reloader that re-reads it when only that file changes.
+	reloader         domain.Reloader
+	watched          []watchedFile
+	dataset          domain.DatasetInfoProvider
+	locationHandler  *handler.LocationHandler
+	datasetHandler   *handler.DatasetHandler
+	rangesHandler    *handler.RangesHandler
+	blocklistHandler *handler.BlocklistHandler
+	adminHandler     *handler.AdminHandler
+	// updater is nil unless UPDATE_URL is set.
+	updater   *updater.Updater
+	startTime time.Time
 }
 
 func NewServer(cfg *config.Config) (*Server, error) {
 	logger.Info("Initializing server...")
 
-	repo, err := repository.NewMemoryRepository(cfg.CSVFilePath)
+	repo, err := newRepository(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize repository: %w", err)
 	}
 
-	locationService := service.NewLocationService(repo)
-	locationHandler := handler.NewLocationHandler(locationService)
+	var (
+		serviceOpts    []service.Option
+		reloader       = reloaders{repo}
+		watched        []watchedFile
+		datasetUpdater *updater.Updater
+	)
+	// The updater owns the dataset file when enabled and reloads after each
+	// install, so only the override layers are watched.
+	watchedPaths := cfg.OverrideFiles
+	if cfg.UpdateURL != "" {
+		// A release the repository verifies on load must also pass the
+		// updater's check, which then installs its signature with it.
+		publicKey := cfg.UpdatePublicKey
+		if publicKey == nil {
+			publicKey = cfg.DatasetPublicKey
+		}
+		datasetUpdater = updater.New(updater.Options{
+			URL:         cfg.UpdateURL,
+			ChecksumURL: cfg.UpdateChecksumURL,
+			PublicKey:   publicKey,
+			Path:        cfg.DataFilePath(),
+			Interval:    cfg.UpdateInterval,
+		}, repo)
+	} else {
+		// A signed dataset is watched together with its signature, so
+		// installing the signature after the file reloads it again.
+		dataset := watchedFile{paths: []string{cfg.DataFilePath()}, reloader: repo}
+		if cfg.DatasetPublicKey != nil {
+			dataset.paths = append(dataset.paths, cfg.DataFilePath()+repository.SignatureSuffix)
+		}
+		watched = append(watched, dataset)
+	}
+	for _, path := range watchedPaths {
+		watched = append(watched, watchedFile{paths: []string{path}, reloader: repo})
+	}
+
+	if cfg.ASNCSVFilePath != "" {
+		logger.Infof("Loading ASN CSV %s", cfg.ASNCSVFilePath)
+		asnRepo, err := repository.NewASNRepository(cfg.ASNCSVFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize ASN repository: %w", err)
+		}
+		serviceOpts = append(serviceOpts, service.WithASN(asnRepo))
+		reloader = append(reloader, asnRepo)
+		watched = append(watched, watchedFile{paths: []string{cfg.ASNCSVFilePath}, reloader: asnRepo})
+	}
+
+	if cfg.ProxyCSVFilePath != "" || cfg.TorExitListPath != "" {
+		logger.Infof("Loading threat data - Proxy CSV: %q - Tor exit list: %q", cfg.ProxyCSVFilePath, cfg.TorExitListPath)
+		threatRepo, err := repository.NewThreatRepository(cfg.ProxyCSVFilePath, cfg.TorExitListPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize threat repository: %w", err)
+		}
+		serviceOpts = append(serviceOpts, service.WithThreat(threatRepo))
+		reloader = append(reloader, threatRepo)
+		for _, path := range []string{cfg.ProxyCSVFilePath, cfg.TorExitListPath} {
+			if path != "" {
+				watched = append(watched, watchedFile{paths: []string{path}, reloader: threatRepo})
+			}
+		}
+	}
+
+	locationService := service.NewLocationService(repo, serviceOpts...)
+	locationHandler := handler.NewLocationHandler(locationService,
+		handler.WithBatchLimits(cfg.BatchMaxSize, int64(cfg.BatchMaxBodyKB)<<10))
+	adminHandler := handler.NewAdminHandler(reloader, cfg.AdminToken)
+
+	// Reverse lookups need the CSV repository; override layers would make
+	// its answers incomplete, so they are only served without them.
+	rangeLister, _ := repo.(domain.RangeLister)
 
 	return &Server{
-		config:          cfg,
-		locationHandler: locationHandler,
-		startTime:       time.Now(),
+		config:           cfg,
+		reloader:         reloader,
+		watched:          watched,
+		dataset:          repo,
+		locationHandler:  locationHandler,
+		datasetHandler:   handler.NewDatasetHandler(repo),
+		rangesHandler:    handler.NewRangesHandler(rangeLister),
+		blocklistHandler: handler.NewBlocklistHandler(rangeLister, repo),
+		adminHandler:     adminHandler,
+		updater:          datasetUpdater,
+		startTime:        time.Now(),
 	}, nil
 }
 
+// dataSource is what the server needs from a repository: lookups, reload
+// and a description of the dataset served.
+type dataSource interface {
+	domain.Repository
+	domain.Reloader
+	domain.DatasetInfoProvider
+}
+
+// watchedFile is a file, or files used together, whose change reloads
+// reloader.
+type watchedFile struct {
+	paths    []string
+	reloader domain.Reloader
+}
+
+// reloaders reloads several datasets in order, stopping at the first error.
+// The path passed to Reload applies to the first one only; the others reload
+// their current file.
+type reloaders []domain.Reloader
+
+func (r reloaders) Reload(path string) error {
+	for i, reloader := range r {
+		if i > 0 {
+			path = ""
+		}
+		if err := reloader.Reload(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newRepository(cfg *config.Config) (dataSource, error) {
+	base, err := newBaseRepository(cfg)
+	if err != nil || len(cfg.OverrideFiles) == 0 {
+		return base, err
+	}
+
+	logger.Infof("Loading override layers %v", cfg.OverrideFiles)
+	return repository.NewLayeredRepository(base, filepath.Base(cfg.DataFilePath()), cfg.OverrideFiles...)
+}
+
+func newBaseRepository(cfg *config.Config) (dataSource, error) {
+	switch cfg.DataSource {
+	case config.DataSourceSnapshot:
+		logger.Infof("Loading snapshot %s", cfg.SnapshotFilePath)
+		return repository.NewSnapshotRepository(cfg.SnapshotFilePath, cfg.SnapshotOptions()...)
+	case config.DataSourceMMDB:
+		logger.Infof("Loading MMDB %s", cfg.MMDBFilePath)
+		return repository.NewMMDBRepository(cfg.MMDBFilePath)
+	default:
+		opts := cfg.CSVOptions()
+		if cfg.CSVStrictValidation {
+			opts = append(opts, repository.WithStrictValidation(
+				repository.UniformValidationLimits(cfg.CSVValidationMaxIssues)))
+		}
+		return repository.NewMemoryRepository(cfg.CSVFilePath, opts...)
+	}
+}
+
 func (s *Server) Start() error {
 	s.registerRoutes()
+	s.startReloadTriggers(context.Background())
+
+	if len(s.config.TrustedProxies) > 0 {
+		logger.Infof("Trusting forwarding headers from %v", s.config.TrustedProxies)
+	}
+	clientIP := middleware.NewClientIPResolver(s.config.TrustedProxies)
 
 	logger.Infof("Server starting on %s (version %s)", s.config.HTTPServerAddress, Version)
-	return http.ListenAndServe(s.config.HTTPServerAddress, nil)
+	return http.ListenAndServe(s.config.HTTPServerAddress, clientIP.Middleware(http.DefaultServeMux))
 }
 
 func (s *Server) registerRoutes() {
 	http.HandleFunc("/ip/location", s.locationHandler.GetLocation)
+	http.HandleFunc("/ip/me", s.locationHandler.GetMyLocation)
+	http.HandleFunc("/ip/locations", s.locationHandler.GetLocations)
+	http.HandleFunc("/ip/locations/stream", s.locationHandler.StreamLocations)
 	http.HandleFunc("/health", s.handleHealth)
+	http.HandleFunc("/dataset", s.datasetHandler.GetDataset)
+	http.HandleFunc("/ranges", s.rangesHandler.GetRanges)
+	http.HandleFunc("/blocklist", s.blocklistHandler.GetBlocklist)
+	http.HandleFunc("/admin/reload", s.adminHandler.ReloadDataset)
 
 	// Serve swagger files from docs directory
 	http.HandleFunc("/swagger/", httpSwagger.WrapHandler)
 
 	logger.Info("Routes registered:")
 	logger.Info("  GET /ip/location?ip=<address>")
+	logger.Info("  GET /ip/me")
+	logger.Info("  POST /ip/locations (JSON array of addresses)")
+	logger.Info("  POST /ip/locations/stream[?field=<member>][&into=<member>] (NDJSON)")
 	logger.Info("  GET /health")
+	logger.Info("  GET /dataset")
+	logger.Info("  GET /ranges?country=<code>[&city=<name>][&version=4|6][&offset=<n>][&limit=<n>]")
+	logger.Info("  GET /blocklist?countries=<codes>&format=ipset|nftables|nginx|haproxy|apache")
+	logger.Info("  POST /admin/reload (Authorization: Bearer <ADMIN_TOKEN>)")
 	logger.Info("  GET /swagger/swagger.json")
 	logger.Info("  GET /docs (redirects to Swagger)")
 }
 
+// startReloadTriggers reloads the dataset in the background on SIGHUP and,
+// when CSV_WATCH_INTERVAL is set, whenever one of the served files changes on
+// disk. With UPDATE_URL set it also starts polling the mirror.
+func (s *Server) startReloadTriggers(ctx context.Context) {
+	if s.updater != nil {
+		go s.updater.Run(ctx)
+		logger.Infof("Polling %s for dataset updates every %v", s.config.UpdateURL, s.config.UpdateInterval)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				signal.Stop(signals)
+				return
+			case <-signals:
+				s.reloadDataset("SIGHUP", s.reloader)
+			}
+		}
+	}()
+	logger.Info("Dataset reload on SIGHUP enabled")
+
+	if s.config.CSVWatchInterval > 0 {
+		for _, file := range s.watched {
+			go filewatch.WatchAll(ctx, file.paths, s.config.CSVWatchInterval, func() {
+				s.reloadDataset("change of "+filepath.Base(file.paths[0]), file.reloader)
+			})
+			logger.Infof("Watching %v for changes every %v", file.paths, s.config.CSVWatchInterval)
+		}
+	}
+}
+
+func (s *Server) reloadDataset(trigger string, reloader domain.Reloader) {
+	start := time.Now()
+	logger.Infof("Dataset reload started - Trigger: %s", trigger)
+
+	if err := reloader.Reload(""); err != nil {
+		logger.Errorf("Dataset reload failed, keeping previous dataset - Trigger: %s - Duration: %v - Error chain: %v",
+			trigger, time.Since(start), err)
+		return
+	}
+
+	logger.Infof("Dataset reload finished - Trigger: %s - Duration: %v", trigger, time.Since(start))
+}
+
 // handleHealth godoc
 // @Summary Health check
-// @Description Returns the health status of the API
+// @Description Returns the health status of the API, the dataset it serves and the dataset updater. The status is degraded while the last update check failed.
 // @Tags Health
 // @Produce json
 // @Success 200 {object} v1.HealthResponse "Service is healthy"
 // @Router /health [get]
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	dataset := handler.NewDatasetResponse(s.dataset.DatasetInfo())
 	response := v1.HealthResponse{
 		Status:    "healthy",
 		Timestamp: time.Now(),
+		Dataset:   &dataset,
+	}
+	if s.updater != nil {
+		status := s.updater.Status()
+		response.Updater = &v1.UpdaterResponse{
+			LastCheck:           status.LastCheck,
+			LastSuccess:         status.LastSuccess,
+			LastUpdate:          status.LastUpdate,
+			LastError:           status.LastError,
+			ConsecutiveFailures: status.ConsecutiveFailures,
+		}
+		if status.LastError != "" {
+			response.Status = "degraded"
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
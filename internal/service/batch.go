@@ -0,0 +1,49 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// GetLocationsByIPs looks up a batch of addresses and returns one result per
+// address, in the order given. The addresses are sorted first so that
+// neighbours falling in the range found for the previous one skip the
+// repository; duplicates and addresses of the same network cost one lookup.
+func (s *LocationService) GetLocationsByIPs(ips []string) []domain.LookupResult {
+	results := make([]domain.LookupResult, len(ips))
+	ids := make([]domain.IPv6ID, len(ips))
+	order := make([]int, 0, len(ips))
+	for i, ip := range ips {
+		ipID, err := parseIP(ip)
+		if err != nil {
+			results[i].Err = fmt.Errorf("%w: %w", domain.ErrInvalidIP, err)
+			continue
+		}
+		ids[i] = ipID
+		order = append(order, i)
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return ids[a].Compare(ids[b])
+	})
+
+	var (
+		location     *domain.Location
+		lower, upper domain.IPv6ID
+	)
+	for _, i := range order {
+		ipID := ids[i]
+		if location == nil || ipID.Less(lower) || upper.Less(ipID) {
+			var err error
+			if location, err = s.find(ipID); err != nil {
+				results[i].Err = err
+				continue
+			}
+			lower, upper = locationBounds(location)
+		}
+		results[i].Location, results[i].Err = s.enrich(location, ipID)
+	}
+
+	return results
+}
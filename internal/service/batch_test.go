@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/repository"
+)
+
+func TestLocationService_GetLocationsByIPs(t *testing.T) {
+	// 10.0.0.0-10.0.0.255 US and 2001:db8::/32 JP.
+	us := &domain.Location{LowerIPID: 167772160, UpperIPID: 167772415, CountryCode: "US"}
+	jp := &domain.Location{
+		LowerIPv6ID: domain.IPv6ID{Hi: 0x20010db800000000},
+		UpperIPv6ID: domain.IPv6ID{Hi: 0x20010db8ffffffff, Lo: ^uint64(0)},
+		CountryCode: "JP",
+	}
+
+	var v4Lookups, v6Lookups int
+	repo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			v4Lookups++
+			if ipID >= us.LowerIPID && ipID <= us.UpperIPID {
+				return us, nil
+			}
+			return nil, domain.ErrLocationNotFound
+		},
+		FindByIPv6IDFunc: func(ipID domain.IPv6ID) (*domain.Location, error) {
+			v6Lookups++
+			if ipID.Compare(jp.LowerIPv6ID) >= 0 && ipID.Compare(jp.UpperIPv6ID) <= 0 {
+				return jp, nil
+			}
+			return nil, domain.ErrLocationNotFound
+		},
+	}
+	s := NewLocationService(repo)
+
+	ips := []string{"10.0.0.200", "2001:db8::1", "192.168.1.1", "not-an-ip", "10.0.0.1", "::ffff:10.0.0.7", "2001:db8:ffff::1", "10.0.0.200"}
+	results := s.GetLocationsByIPs(ips)
+	if len(results) != len(ips) {
+		t.Fatalf("GetLocationsByIPs() returned %d results, want %d", len(results), len(ips))
+	}
+
+	wantCountries := []string{"US", "JP", "", "", "US", "US", "JP", "US"}
+	for i, result := range results {
+		switch {
+		case wantCountries[i] != "":
+			if result.Err != nil || result.Location.CountryCode != wantCountries[i] {
+				t.Errorf("result %d (%s) = %+v, %v, want %s", i, ips[i], result.Location, result.Err, wantCountries[i])
+			}
+		case ips[i] == "not-an-ip":
+			if !errors.Is(result.Err, domain.ErrInvalidIP) {
+				t.Errorf("result %d (%s) error = %v, want ErrInvalidIP", i, ips[i], result.Err)
+			}
+		default:
+			if !errors.Is(result.Err, domain.ErrLocationNotFound) {
+				t.Errorf("result %d (%s) error = %v, want ErrLocationNotFound", i, ips[i], result.Err)
+			}
+		}
+	}
+
+	// One lookup per range, plus one for the address outside both.
+	if v4Lookups != 2 || v6Lookups != 1 {
+		t.Errorf("repository lookups = %d IPv4, %d IPv6, want 2, 1", v4Lookups, v6Lookups)
+	}
+}
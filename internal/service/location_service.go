@@ -1,32 +1,139 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"arena-backend-challenge/internal/domain"
 	"arena-backend-challenge/pkg/iputil"
 )
 
 type LocationService struct {
-	repo domain.Repository
+	repo   domain.Repository
+	asn    domain.ASNRepository
+	threat domain.ThreatRepository
 }
 
-func NewLocationService(repo domain.Repository) *LocationService {
-	return &LocationService{
+// Option customises a LocationService.
+type Option func(*LocationService)
+
+// WithASN enriches every location found with the autonomous system from
+// repo. Addresses without ASN data are returned without it.
+func WithASN(repo domain.ASNRepository) Option {
+	return func(s *LocationService) {
+		s.asn = repo
+	}
+}
+
+// WithThreat classifies every location found as proxy, VPN, Tor or hosting
+// with repo.
+func WithThreat(repo domain.ThreatRepository) Option {
+	return func(s *LocationService) {
+		s.threat = repo
+	}
+}
+
+func NewLocationService(repo domain.Repository, opts ...Option) *LocationService {
+	s := &LocationService{
 		repo: repo,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *LocationService) GetLocationByIP(ip string) (*domain.Location, error) {
-	ipID, err := iputil.IPToID(ip)
+	ipID, err := parseIP(ip)
 	if err != nil {
-		return nil, fmt.Errorf("convert IP to ID: %w", err)
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidIP, err)
 	}
 
-	location, err := s.repo.FindByIPID(ipID)
+	location, err := s.find(ipID)
 	if err != nil {
-		return nil, fmt.Errorf("find location by IP ID: %w", err)
+		return nil, err
+	}
+
+	return s.enrich(location, ipID)
+}
+
+// parseIP converts an IPv4 or IPv6 address to an IP number, IPv4 addresses
+// mapped into ::ffff:0:0/96.
+func parseIP(ip string) (domain.IPv6ID, error) {
+	if strings.Contains(ip, ":") {
+		hi, lo, err := iputil.IPv6ToID(ip)
+		if err != nil {
+			return domain.IPv6ID{}, err
+		}
+		return domain.IPv6ID{Hi: hi, Lo: lo}, nil
 	}
 
+	ipID, err := iputil.IPToID(ip)
+	if err != nil {
+		return domain.IPv6ID{}, err
+	}
+	return domain.IPv4ToIPv6ID(ipID), nil
+}
+
+// find looks ipID up in the IPv4 table when it is IPv4-mapped and in the
+// IPv6 table otherwise.
+func (s *LocationService) find(ipID domain.IPv6ID) (*domain.Location, error) {
+	if ipv4ID, ok := ipID.IPv4(); ok {
+		location, err := s.repo.FindByIPID(ipv4ID)
+		if err != nil {
+			return nil, fmt.Errorf("find location by IP ID: %w", err)
+		}
+		return location, nil
+	}
+
+	location, err := s.repo.FindByIPv6ID(ipID)
+	if err != nil {
+		return nil, fmt.Errorf("find location by IPv6 ID: %w", err)
+	}
 	return location, nil
 }
+
+// enrich returns a copy of location carrying the optional datasets, so
+// locations shared by a repository are never modified. A missing ASN is not
+// an error.
+func (s *LocationService) enrich(location *domain.Location, ipID domain.IPv6ID) (*domain.Location, error) {
+	if s.asn == nil && s.threat == nil {
+		return location, nil
+	}
+
+	enriched := *location
+	ipv4ID, isIPv4 := ipID.IPv4()
+
+	if s.asn != nil {
+		var asn *domain.ASN
+		var err error
+		if isIPv4 {
+			asn, err = s.asn.FindASNByIPID(ipv4ID)
+		} else {
+			asn, err = s.asn.FindASNByIPv6ID(ipID)
+		}
+		switch {
+		case err == nil:
+			enriched.ASN = asn
+		case !errors.Is(err, domain.ErrASNNotFound):
+			return nil, fmt.Errorf("find ASN: %w", err)
+		}
+	}
+
+	if s.threat != nil {
+		var threat *domain.Threat
+		var err error
+		if isIPv4 {
+			threat, err = s.threat.FindThreatByIPID(ipv4ID)
+		} else {
+			threat, err = s.threat.FindThreatByIPv6ID(ipID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("find threat: %w", err)
+		}
+		enriched.Threat = threat
+	}
+
+	return &enriched, nil
+}
@@ -136,6 +136,72 @@ func TestLocationService_GetLocationByIP(t *testing.T) {
 	}
 }
 
+func TestLocationService_GetLocationByIP_IPv6(t *testing.T) {
+	mockRepo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			if ipID != 134744072 {
+				t.Errorf("FindByIPID() called with %d, want 134744072", ipID)
+			}
+			return &domain.Location{Country: "United States", City: "Mountain View"}, nil
+		},
+		FindByIPv6IDFunc: func(ipID domain.IPv6ID) (*domain.Location, error) {
+			if ipID == (domain.IPv6ID{Hi: 0x20010db800000000, Lo: 1}) {
+				return &domain.Location{Country: "Japan", City: "Tokyo"}, nil
+			}
+			return nil, domain.ErrLocationNotFound
+		},
+	}
+
+	service := NewLocationService(mockRepo)
+
+	tests := []struct {
+		name        string
+		ip          string
+		wantCountry string
+		wantErr     error
+	}{
+		{
+			name:        "native IPv6",
+			ip:          "2001:db8::1",
+			wantCountry: "Japan",
+		},
+		{
+			name:        "IPv4-mapped IPv6 goes to the IPv4 table",
+			ip:          "::ffff:8.8.8.8",
+			wantCountry: "United States",
+		},
+		{
+			name:    "IPv6 not found",
+			ip:      "2001:db9::1",
+			wantErr: domain.ErrLocationNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.GetLocationByIP(tt.ip)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetLocationByIP() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("GetLocationByIP() unexpected error = %v", err)
+			}
+			if got.Country != tt.wantCountry {
+				t.Errorf("GetLocationByIP() Country = %v, want %v", got.Country, tt.wantCountry)
+			}
+		})
+	}
+
+	if _, err := service.GetLocationByIP("2001:db8:::1"); err == nil || errors.Is(err, domain.ErrLocationNotFound) {
+		t.Errorf("GetLocationByIP() with malformed IPv6 should fail with a conversion error, got %v", err)
+	}
+}
+
 func BenchmarkLocationService_GetLocationByIP(b *testing.B) {
 	mockRepo := &repository.MockRepository{
 		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
@@ -155,3 +221,112 @@ func BenchmarkLocationService_GetLocationByIP(b *testing.B) {
 		_, _ = service.GetLocationByIP(testIP)
 	}
 }
+
+func TestLocationService_GetLocationByIP_ASN(t *testing.T) {
+	mockRepo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			return &domain.Location{Country: "United States"}, nil
+		},
+		FindByIPv6IDFunc: func(ipID domain.IPv6ID) (*domain.Location, error) {
+			return &domain.Location{Country: "Japan"}, nil
+		},
+	}
+	asnErr := errors.New("asn backend down")
+	asnRepo := &repository.MockASNRepository{
+		FindASNByIPIDFunc: func(ipID uint32) (*domain.ASN, error) {
+			switch ipID {
+			case 134744072:
+				return &domain.ASN{Number: 15169, Name: "Google LLC", Network: "8.8.8.0/24"}, nil
+			case 16843009:
+				return nil, asnErr
+			}
+			return nil, domain.ErrASNNotFound
+		},
+	}
+
+	service := NewLocationService(mockRepo, WithASN(asnRepo))
+
+	tests := []struct {
+		name       string
+		ip         string
+		wantNumber uint32
+		wantErr    error
+	}{
+		{name: "IPv4 with ASN", ip: "8.8.8.8", wantNumber: 15169},
+		{name: "IPv4-mapped IPv6 with ASN", ip: "::ffff:8.8.8.8", wantNumber: 15169},
+		{name: "IPv4 without ASN", ip: "9.9.9.9"},
+		{name: "IPv6 without ASN", ip: "2001:db8::1"},
+		{name: "ASN lookup error", ip: "1.1.1.1", wantErr: asnErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.GetLocationByIP(tt.ip)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetLocationByIP() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetLocationByIP() unexpected error = %v", err)
+			}
+
+			if tt.wantNumber == 0 {
+				if got.ASN != nil {
+					t.Errorf("GetLocationByIP() ASN = %+v, want nil", got.ASN)
+				}
+				return
+			}
+			if got.ASN == nil || got.ASN.Number != tt.wantNumber {
+				t.Errorf("GetLocationByIP() ASN = %+v, want number %d", got.ASN, tt.wantNumber)
+			}
+		})
+	}
+}
+
+func TestLocationService_GetLocationByIP_Threat(t *testing.T) {
+	mockRepo := &repository.MockRepository{
+		FindByIPIDFunc: func(ipID uint32) (*domain.Location, error) {
+			return &domain.Location{Country: "Germany"}, nil
+		},
+		FindByIPv6IDFunc: func(ipID domain.IPv6ID) (*domain.Location, error) {
+			return &domain.Location{Country: "Japan"}, nil
+		},
+	}
+	threatErr := errors.New("threat backend down")
+	threatRepo := &repository.MockThreatRepository{
+		FindThreatByIPIDFunc: func(ipID uint32) (*domain.Threat, error) {
+			switch ipID {
+			case 2130706433:
+				return &domain.Threat{IsProxy: true, IsTor: true, ProxyType: "TOR"}, nil
+			case 16843009:
+				return nil, threatErr
+			}
+			return &domain.Threat{}, nil
+		},
+	}
+
+	service := NewLocationService(mockRepo, WithThreat(threatRepo))
+
+	got, err := service.GetLocationByIP("127.0.0.1")
+	if err != nil {
+		t.Fatalf("GetLocationByIP() unexpected error = %v", err)
+	}
+	if got.Threat == nil || !got.Threat.IsTor || !got.Threat.IsProxy {
+		t.Errorf("GetLocationByIP() Threat = %+v, want a Tor proxy", got.Threat)
+	}
+
+	got, err = service.GetLocationByIP("2001:db8::1")
+	if err != nil {
+		t.Fatalf("GetLocationByIP() unexpected error = %v", err)
+	}
+	if got.Threat == nil || *got.Threat != (domain.Threat{}) {
+		t.Errorf("GetLocationByIP() Threat = %+v, want an empty classification", got.Threat)
+	}
+
+	if _, err := service.GetLocationByIP("1.1.1.1"); !errors.Is(err, threatErr) {
+		t.Errorf("GetLocationByIP() error = %v, want %v", err, threatErr)
+	}
+}
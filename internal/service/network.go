@@ -0,0 +1,185 @@
+package service
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"slices"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// maxNetworkRanges caps the ranges listed in a NetworkReport; the totals and
+// the country summary still cover every range.
+const maxNetworkRanges = 1000
+
+// The shortest prefixes accepted keep one query from walking a large part
+// of the dataset.
+const (
+	minIPv4PrefixBits = 8
+	minIPv6PrefixBits = 16
+)
+
+// GetLocationsByCIDR reports every range overlapping the block cidr, with
+// the addresses each covers and the share of the block per country. Ranges
+// are not enriched with ASN or threat data, which are per address.
+func (s *LocationService) GetLocationsByCIDR(cidr string) (*domain.NetworkReport, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("parse CIDR: %w", err)
+	}
+	prefix = prefix.Masked()
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+
+	minBits := minIPv6PrefixBits
+	if prefix.Addr().Is4() {
+		minBits = minIPv4PrefixBits
+	}
+	if prefix.Bits() < minBits {
+		return nil, fmt.Errorf("CIDR %s is too large: the shortest prefix accepted is /%d", prefix, minBits)
+	}
+
+	searcher, ok := s.repo.(domain.NetworkSearcher)
+	if !ok {
+		return nil, domain.ErrNetworkSearchUnsupported
+	}
+
+	first, last := prefixBounds(prefix)
+	report := &domain.NetworkReport{
+		Prefix:  prefix,
+		Size:    new(big.Int).Lsh(big.NewInt(1), uint(prefix.Addr().BitLen()-prefix.Bits())),
+		Covered: new(big.Int),
+	}
+	countries := make(map[string]*domain.CountryShare)
+
+	collect := func(location *domain.Location) bool {
+		lower, upper := locationBounds(location)
+		covered := rangeSize(maxID(lower, first), minID(upper, last))
+
+		report.Covered.Add(report.Covered, covered)
+		report.TotalRanges++
+		if len(report.Ranges) < maxNetworkRanges {
+			report.Ranges = append(report.Ranges, domain.NetworkRange{
+				First:     idToAddr(lower),
+				Last:      idToAddr(upper),
+				Location:  location,
+				Addresses: covered,
+			})
+		}
+
+		share, ok := countries[location.CountryCode]
+		if !ok {
+			share = &domain.CountryShare{
+				CountryCode: location.CountryCode,
+				Country:     location.Country,
+				Addresses:   new(big.Int),
+			}
+			countries[location.CountryCode] = share
+		}
+		share.Addresses.Add(share.Addresses, covered)
+		return true
+	}
+
+	// IPv4 ranges sit in ::ffff:0:0/96, so any block reaching into it gets
+	// them too; the IPv6 table never holds IPv4-mapped ranges.
+	mappedFirst, mappedLast := domain.IPv4ToIPv6ID(0), domain.IPv4ToIPv6ID(math.MaxUint32)
+	if first.Compare(mappedLast) <= 0 && last.Compare(mappedFirst) >= 0 {
+		v4First, _ := maxID(first, mappedFirst).IPv4()
+		v4Last, _ := minID(last, mappedLast).IPv4()
+		searcher.FindByIPIDRange(v4First, v4Last, collect)
+	}
+	if !prefix.Addr().Is4() {
+		searcher.FindByIPv6IDRange(first, last, collect)
+	}
+
+	for _, share := range countries {
+		share.Share, _ = new(big.Rat).SetFrac(share.Addresses, report.Size).Float64()
+		report.Countries = append(report.Countries, *share)
+	}
+	slices.SortFunc(report.Countries, func(a, b domain.CountryShare) int {
+		if c := b.Addresses.Cmp(a.Addresses); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.CountryCode, b.CountryCode)
+	})
+
+	return report, nil
+}
+
+// prefixBounds returns the first and last address of prefix as IP numbers;
+// IPv4 prefixes are mapped into ::ffff:0:0/96.
+func prefixBounds(prefix netip.Prefix) (first, last domain.IPv6ID) {
+	first = addrToID(prefix.Addr())
+
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+	last = first
+	if bits < 64 {
+		last.Hi |= ^uint64(0) >> bits
+		last.Lo = ^uint64(0)
+	} else if bits < 128 {
+		last.Lo |= ^uint64(0) >> (bits - 64)
+	}
+	return first, last
+}
+
+// locationBounds returns the range of location as IP numbers.
+func locationBounds(location *domain.Location) (lower, upper domain.IPv6ID) {
+	if location.IsIPv6() {
+		return location.LowerIPv6ID, location.UpperIPv6ID
+	}
+	return domain.IPv4ToIPv6ID(location.LowerIPID), domain.IPv4ToIPv6ID(location.UpperIPID)
+}
+
+func addrToID(addr netip.Addr) domain.IPv6ID {
+	b := addr.As16()
+	return domain.IPv6ID{Hi: binary.BigEndian.Uint64(b[:8]), Lo: binary.BigEndian.Uint64(b[8:])}
+}
+
+// idToAddr turns an IP number back into an address, IPv4 for the
+// IPv4-mapped block.
+func idToAddr(id domain.IPv6ID) netip.Addr {
+	if ipv4ID, ok := id.IPv4(); ok {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], ipv4ID)
+		return netip.AddrFrom4(b)
+	}
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], id.Hi)
+	binary.BigEndian.PutUint64(b[8:], id.Lo)
+	return netip.AddrFrom16(b)
+}
+
+// rangeSize returns the number of addresses from first to last inclusive.
+func rangeSize(first, last domain.IPv6ID) *big.Int {
+	n := idToBig(last)
+	n.Sub(n, idToBig(first))
+	return n.Add(n, big.NewInt(1))
+}
+
+func idToBig(id domain.IPv6ID) *big.Int {
+	n := new(big.Int).SetUint64(id.Hi)
+	n.Lsh(n, 64)
+	return n.Or(n, new(big.Int).SetUint64(id.Lo))
+}
+
+func maxID(a, b domain.IPv6ID) domain.IPv6ID {
+	if a.Less(b) {
+		return b
+	}
+	return a
+}
+
+func minID(a, b domain.IPv6ID) domain.IPv6ID {
+	if a.Less(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/internal/repository"
+)
+
+func TestLocationService_GetLocationsByCIDR(t *testing.T) {
+	// 1.0.0.0/24 US, 1.0.1.0-1.0.3.255 CN, gap, 1.0.8.0-1.0.15.255 AU and
+	// 2001:db8::/32 JP.
+	csvData := `"ip_from","ip_to","country_code","country_name","region_name","city_name","latitude","longitude","zip_code","time_zone"
+"16777216","16777471","US","United States","California","Los Angeles","34.05223","-118.24368","90001","-07:00"
+"16777472","16778239","CN","China","Fujian","Fuzhou","26.06139","119.30611","-","08:00"
+"16779264","16781311","AU","Australia","Victoria","Melbourne","-37.814007","144.963171","3000","+10:00"
+"42540766411282592856903984951653826560","42540766490510755371168322545197776895","JP","Japan","Tokyo","Tokyo","35.689497","139.692317","100-0001","+09:00"`
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(csvData), 0644); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	repo, err := repository.NewMemoryRepository(path)
+	if err != nil {
+		t.Fatalf("NewMemoryRepository() error = %v", err)
+	}
+	s := NewLocationService(repo)
+
+	type share struct {
+		code      string
+		addresses string
+		share     float64
+	}
+	tests := []struct {
+		name       string
+		cidr       string
+		wantPrefix string
+		wantSize   string
+		wantRanges []string
+		wantShares []share
+	}{
+		{
+			name:       "block spanning ranges and a gap",
+			cidr:       "1.0.0.0/20",
+			wantPrefix: "1.0.0.0/20",
+			wantSize:   "4096",
+			wantRanges: []string{"1.0.0.0-1.0.0.255", "1.0.1.0-1.0.3.255", "1.0.8.0-1.0.15.255"},
+			wantShares: []share{{"AU", "2048", 0.5}, {"CN", "768", 0.1875}, {"US", "256", 0.0625}},
+		},
+		{
+			name:       "block inside one range, host bits masked",
+			cidr:       "1.0.2.77/23",
+			wantPrefix: "1.0.2.0/23",
+			wantSize:   "512",
+			wantRanges: []string{"1.0.1.0-1.0.3.255"},
+			wantShares: []share{{"CN", "512", 1}},
+		},
+		{
+			name:       "IPv4-mapped block",
+			cidr:       "::ffff:1.0.0.0/120",
+			wantPrefix: "1.0.0.0/24",
+			wantSize:   "256",
+			wantRanges: []string{"1.0.0.0-1.0.0.255"},
+			wantShares: []share{{"US", "256", 1}},
+		},
+		{
+			name:       "IPv6 block",
+			cidr:       "2001:db8:1::/48",
+			wantPrefix: "2001:db8:1::/48",
+			wantSize:   "1208925819614629174706176",
+			wantRanges: []string{"2001:db8::-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+			wantShares: []share{{"JP", "1208925819614629174706176", 1}},
+		},
+		{
+			name:       "no ranges",
+			cidr:       "10.0.0.0/8",
+			wantPrefix: "10.0.0.0/8",
+			wantSize:   "16777216",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := s.GetLocationsByCIDR(tt.cidr)
+			if err != nil {
+				t.Fatalf("GetLocationsByCIDR() error = %v", err)
+			}
+			if report.Prefix.String() != tt.wantPrefix || report.Size.String() != tt.wantSize {
+				t.Errorf("GetLocationsByCIDR() prefix, size = %s, %s, want %s, %s",
+					report.Prefix, report.Size, tt.wantPrefix, tt.wantSize)
+			}
+
+			if len(report.Ranges) != len(tt.wantRanges) || report.TotalRanges != len(tt.wantRanges) {
+				t.Fatalf("GetLocationsByCIDR() ranges = %+v, want %v", report.Ranges, tt.wantRanges)
+			}
+			for i, r := range report.Ranges {
+				if got := r.First.String() + "-" + r.Last.String(); got != tt.wantRanges[i] {
+					t.Errorf("range %d = %s, want %s", i, got, tt.wantRanges[i])
+				}
+			}
+
+			if len(report.Countries) != len(tt.wantShares) {
+				t.Fatalf("GetLocationsByCIDR() countries = %+v, want %+v", report.Countries, tt.wantShares)
+			}
+			for i, c := range report.Countries {
+				want := tt.wantShares[i]
+				if c.CountryCode != want.code || c.Addresses.String() != want.addresses || c.Share != want.share {
+					t.Errorf("country %d = %s %s %v, want %+v", i, c.CountryCode, c.Addresses, c.Share, want)
+				}
+			}
+		})
+	}
+
+	for _, cidr := range []string{"1.0.0.0", "1.0.0.0/33", "0.0.0.0/7", "2000::/15"} {
+		if _, err := s.GetLocationsByCIDR(cidr); err == nil {
+			t.Errorf("GetLocationsByCIDR(%q) should fail", cidr)
+		}
+	}
+
+	unsupported := NewLocationService(&repository.MockRepository{})
+	if _, err := unsupported.GetLocationsByCIDR("1.0.0.0/24"); !errors.Is(err, domain.ErrNetworkSearchUnsupported) {
+		t.Errorf("GetLocationsByCIDR() without a NetworkSearcher error = %v, want ErrNetworkSearchUnsupported", err)
+	}
+}
@@ -0,0 +1,423 @@
+// Package updater keeps a dataset file in sync with an HTTP mirror.
+package updater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"arena-backend-challenge/internal/domain"
+	"arena-backend-challenge/pkg/logger"
+)
+
+// maxSidecarSize bounds the checksum and signature downloads.
+const maxSidecarSize = 64 << 10
+
+// signatureSuffix matches repository.SignatureSuffix: a verified signature is
+// installed next to the dataset, where a repository checking signatures
+// expects it.
+const signatureSuffix = ".sig"
+
+// A reload started elsewhere (SIGHUP, the admin endpoint) holds the
+// repository for a while; installs wait for it with a doubling backoff
+// rather than rolling back a verified release.
+const (
+	reloadRetries = 8
+	reloadBackoff = 100 * time.Millisecond
+)
+
+// Options configures an Updater.
+type Options struct {
+	// URL serves the dataset. It is fetched with If-None-Match and
+	// If-Modified-Since, so an unchanged release costs one 304.
+	URL string
+	// ChecksumURL serves the SHA-256 of the dataset as hex, optionally
+	// followed by a file name as written by sha256sum. Defaults to URL +
+	// ".sha256".
+	ChecksumURL string
+	// PublicKey, when set, also requires an Ed25519 signature of the raw
+	// SHA-256 digest at SignatureURL (URL + ".sig" by default), either raw
+	// or base64 encoded. The signature is installed as Path + ".sig".
+	PublicKey    ed25519.PublicKey
+	SignatureURL string
+	// Path is the dataset file the repository loads; updates replace it.
+	Path     string
+	Interval time.Duration
+	Client   *http.Client
+}
+
+// Status describes the outcome of the latest checks.
+type Status struct {
+	LastCheck time.Time
+	// LastSuccess is the last check that ended without error, whether or
+	// not it found a new release; LastUpdate the last release installed.
+	LastSuccess         time.Time
+	LastUpdate          time.Time
+	LastError           string
+	ConsecutiveFailures int
+}
+
+// Updater polls a mirror for a new release of a dataset file, verifies it
+// and swaps it in through the repository's Reloader. Any failure leaves the
+// previous file on disk and the previous dataset in memory.
+type Updater struct {
+	opts     Options
+	reloader domain.Reloader
+
+	checkMu sync.Mutex
+	// etag and lastModified identify the release installed last.
+	etag         string
+	lastModified string
+
+	statusMu sync.RWMutex
+	status   Status
+}
+
+// New creates an Updater that installs releases at opts.Path and hands them
+// to reloader.
+func New(opts Options, reloader domain.Reloader) *Updater {
+	if opts.ChecksumURL == "" {
+		opts.ChecksumURL = opts.URL + ".sha256"
+	}
+	if opts.SignatureURL == "" {
+		opts.SignatureURL = opts.URL + ".sig"
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Minute}
+	}
+
+	u := &Updater{
+		opts:     opts,
+		reloader: reloader,
+	}
+	// Until a release has been installed, the file on disk stands in for it.
+	if info, err := os.Stat(opts.Path); err == nil {
+		u.lastModified = info.ModTime().UTC().Format(http.TimeFormat)
+	}
+	return u
+}
+
+// Run checks the mirror right away and then every Interval until ctx is
+// cancelled.
+func (u *Updater) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		updated, err := u.Check(ctx)
+		switch {
+		case err != nil:
+			logger.Errorf("Dataset update failed, keeping previous dataset - URL: %s - Duration: %v - Error chain: %v",
+				u.opts.URL, time.Since(start), err)
+		case updated:
+			logger.Infof("Dataset updated from mirror - URL: %s - Duration: %v", u.opts.URL, time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the outcome of the latest checks.
+func (u *Updater) Status() Status {
+	u.statusMu.RLock()
+	defer u.statusMu.RUnlock()
+
+	return u.status
+}
+
+// Check asks the mirror for a newer release and installs it. It reports
+// whether a new file was installed.
+func (u *Updater) Check(ctx context.Context) (updated bool, err error) {
+	u.checkMu.Lock()
+	defer u.checkMu.Unlock()
+
+	defer func() {
+		u.record(updated, err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.opts.URL, nil)
+	if err != nil {
+		return false, fmt.Errorf("build request: %w", err)
+	}
+	if u.etag != "" {
+		req.Header.Set("If-None-Match", u.etag)
+	}
+	if u.lastModified != "" {
+		req.Header.Set("If-Modified-Since", u.lastModified)
+	}
+
+	resp, err := u.opts.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("GET %s: %w", u.opts.URL, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("GET %s: unexpected status %s", u.opts.URL, resp.Status)
+	}
+
+	tmpPath, sum, err := u.download(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = os.Remove(tmpPath)
+	}()
+
+	signature, err := u.verify(ctx, sum)
+	if err != nil {
+		return false, err
+	}
+
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		_ = os.Chtimes(tmpPath, lastModified, lastModified)
+	}
+
+	if err := u.install(ctx, tmpPath, signature); err != nil {
+		return false, err
+	}
+
+	u.etag = resp.Header.Get("ETag")
+	u.lastModified = resp.Header.Get("Last-Modified")
+	return true, nil
+}
+
+// download writes body to a temporary file next to Path, so installing it is
+// a rename on the same file system.
+func (u *Updater) download(body io.Reader) (string, []byte, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(u.opts.Path), "."+filepath.Base(u.opts.Path)+".download-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("create temp file: %w", err)
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", nil, fmt.Errorf("download %s: %w", u.opts.URL, err)
+	}
+
+	return tmp.Name(), hash.Sum(nil), nil
+}
+
+// verify checks the downloaded digest against the published checksum and,
+// when a public key is configured, the published signature, which it
+// returns as published.
+func (u *Updater) verify(ctx context.Context, sum []byte) ([]byte, error) {
+	published, err := u.fetch(ctx, u.opts.ChecksumURL)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(published))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("verify checksum: %s is empty", u.opts.ChecksumURL)
+	}
+	want, err := hex.DecodeString(fields[0])
+	if err != nil || len(want) != sha256.Size {
+		return nil, fmt.Errorf("verify checksum: %s does not hold a SHA-256", u.opts.ChecksumURL)
+	}
+	if !strings.EqualFold(fields[0], hex.EncodeToString(sum)) {
+		return nil, fmt.Errorf("verify checksum: got %x, %s publishes %s", sum, u.opts.ChecksumURL, fields[0])
+	}
+
+	if u.opts.PublicKey == nil {
+		return nil, nil
+	}
+
+	published, err = u.fetch(ctx, u.opts.SignatureURL)
+	if err != nil {
+		return nil, err
+	}
+	signature := published
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return nil, fmt.Errorf("verify signature: %s is neither raw nor base64: %w", u.opts.SignatureURL, err)
+		}
+		signature = decoded
+	}
+	if !ed25519.Verify(u.opts.PublicKey, sum, signature) {
+		return nil, fmt.Errorf("verify signature: %s does not match the dataset", u.opts.SignatureURL)
+	}
+	return published, nil
+}
+
+func (u *Updater) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := u.opts.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSidecarSize))
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", url, err)
+	}
+	return data, nil
+}
+
+// install moves the verified download (and its signature, if any) over Path
+// and reloads. If the repository rejects it, the previous files are put back
+// so a restart keeps serving what is served now.
+func (u *Updater) install(ctx context.Context, tmpPath string, signature []byte) error {
+	previous := u.opts.Path + ".previous"
+
+	restoreSignature := func() {}
+	if signature != nil {
+		restore, err := replaceFile(u.opts.Path+signatureSuffix, signature)
+		if err != nil {
+			return fmt.Errorf("install signature: %w", err)
+		}
+		restoreSignature = restore
+	}
+
+	hadPrevious := true
+	if err := os.Rename(u.opts.Path, previous); err != nil {
+		if !os.IsNotExist(err) {
+			restoreSignature()
+			return fmt.Errorf("keep previous dataset: %w", err)
+		}
+		hadPrevious = false
+	}
+
+	if err := os.Rename(tmpPath, u.opts.Path); err != nil {
+		if hadPrevious {
+			_ = os.Rename(previous, u.opts.Path)
+		}
+		restoreSignature()
+		return fmt.Errorf("install dataset: %w", err)
+	}
+
+	if err := u.reload(ctx); err != nil {
+		if hadPrevious {
+			_ = os.Rename(previous, u.opts.Path)
+		} else {
+			_ = os.Remove(u.opts.Path)
+		}
+		restoreSignature()
+		return fmt.Errorf("reload: %w", err)
+	}
+
+	if hadPrevious {
+		_ = os.Remove(previous)
+	}
+	return nil
+}
+
+// reload asks the repository to load Path, retrying while another reload is
+// in progress.
+func (u *Updater) reload(ctx context.Context) error {
+	backoff := reloadBackoff
+	for attempt := 0; ; attempt++ {
+		err := u.reloader.Reload("")
+		if !errors.Is(err, domain.ErrReloadInProgress) || attempt == reloadRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// replaceFile atomically replaces path with data and returns a func that
+// puts the previous content back, or removes path if there was none.
+func replaceFile(path string, data []byte) (func(), error) {
+	previous, err := os.ReadFile(path)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := writeFile(path, data); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if existed {
+			_ = writeFile(path, previous)
+		} else {
+			_ = os.Remove(path)
+		}
+	}, nil
+}
+
+// writeFile writes data to a temporary file next to path and renames it into
+// place.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".download-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", tmp.Name(), err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (u *Updater) record(updated bool, err error) {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+
+	now := time.Now()
+	u.status.LastCheck = now
+	if err != nil {
+		u.status.LastError = err.Error()
+		u.status.ConsecutiveFailures++
+		return
+	}
+
+	u.status.LastSuccess = now
+	u.status.LastError = ""
+	u.status.ConsecutiveFailures = 0
+	if updated {
+		u.status.LastUpdate = now
+	}
+}
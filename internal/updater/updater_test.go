@@ -0,0 +1,316 @@
+package updater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"arena-backend-challenge/internal/domain"
+)
+
+// mirror stands in for the dataset mirror: it serves one release through
+// http.ServeContent, so conditional requests work, plus its checksum and
+// signature.
+type mirror struct {
+	mu        sync.Mutex
+	data      string
+	etag      string
+	checksum  string
+	signature string
+	modTime   time.Time
+	downloads int
+}
+
+func (m *mirror) publish(data string, key ed25519.PrivateKey) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(data))
+	m.data = data
+	m.etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+	m.checksum = hex.EncodeToString(sum[:]) + "  dataset.csv\n"
+	if key != nil {
+		m.signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, sum[:]))
+	}
+	m.modTime = m.modTime.Add(time.Hour)
+}
+
+func (m *mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/dataset.csv":
+		rec := httptest.NewRecorder()
+		rec.Header().Set("ETag", m.etag)
+		http.ServeContent(rec, r, "dataset.csv", m.modTime, strings.NewReader(m.data))
+		if rec.Code == http.StatusOK {
+			m.downloads++
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+	case "/dataset.csv.sha256":
+		_, _ = w.Write([]byte(m.checksum))
+	case "/dataset.csv.sig":
+		_, _ = w.Write([]byte(m.signature))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+type fileReloader struct {
+	path string
+	err  error
+	// busy fails that many calls as if another reload held the repository.
+	busy   int
+	loaded []string
+}
+
+func (r *fileReloader) Reload(path string) error {
+	if r.busy > 0 {
+		r.busy--
+		return domain.ErrReloadInProgress
+	}
+	if r.err != nil {
+		return r.err
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	r.loaded = append(r.loaded, string(data))
+	return nil
+}
+
+func newTestUpdater(t *testing.T, m *mirror, publicKey ed25519.PublicKey) (*Updater, *fileReloader, string) {
+	t.Helper()
+
+	server := httptest.NewServer(m)
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "dataset.csv")
+	if err := os.WriteFile(path, []byte("v0"), 0644); err != nil {
+		t.Fatalf("Failed to write dataset: %v", err)
+	}
+	// Older than anything the mirror publishes.
+	old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Failed to age dataset: %v", err)
+	}
+
+	reloader := &fileReloader{path: path}
+	u := New(Options{
+		URL:       server.URL + "/dataset.csv",
+		PublicKey: publicKey,
+		Path:      path,
+		Interval:  time.Hour,
+	}, reloader)
+	return u, reloader, path
+}
+
+func TestUpdater_Check(t *testing.T) {
+	m := &mirror{modTime: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)}
+	m.publish("v1", nil)
+	u, reloader, path := newTestUpdater(t, m, nil)
+
+	updated, err := u.Check(context.Background())
+	if err != nil || !updated {
+		t.Fatalf("Check() = %v, %v, want an update", updated, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "v1" {
+		t.Errorf("dataset file = %q, want v1", data)
+	}
+	if len(reloader.loaded) != 1 || reloader.loaded[0] != "v1" {
+		t.Errorf("reloaded %v, want [v1]", reloader.loaded)
+	}
+
+	updated, err = u.Check(context.Background())
+	if err != nil || updated {
+		t.Errorf("Check() of an unchanged release = %v, %v, want no update", updated, err)
+	}
+	if m.downloads != 1 {
+		t.Errorf("mirror served %d downloads, want 1", m.downloads)
+	}
+
+	m.publish("v2", nil)
+	if updated, err := u.Check(context.Background()); err != nil || !updated {
+		t.Fatalf("Check() after a new release = %v, %v, want an update", updated, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "v2" {
+		t.Errorf("dataset file = %q, want v2", data)
+	}
+
+	status := u.Status()
+	if status.LastError != "" || status.LastUpdate.IsZero() || status.ConsecutiveFailures != 0 {
+		t.Errorf("Status() = %+v, want a clean update", status)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".dataset.csv.download-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary downloads left behind: %v", leftovers)
+	}
+}
+
+func TestUpdater_Check_IfModifiedSince(t *testing.T) {
+	m := &mirror{modTime: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)}
+	m.publish("v1", nil)
+	u, _, path := newTestUpdater(t, m, nil)
+
+	// A file already newer than the release, e.g. from the previous run.
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		t.Fatalf("Failed to touch dataset: %v", err)
+	}
+	u = New(u.opts, u.reloader)
+
+	if updated, err := u.Check(context.Background()); err != nil || updated {
+		t.Errorf("Check() = %v, %v, want not modified", updated, err)
+	}
+	if m.downloads != 0 {
+		t.Errorf("mirror served %d downloads, want 0", m.downloads)
+	}
+}
+
+func TestUpdater_Check_ReloadInProgress(t *testing.T) {
+	m := &mirror{modTime: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)}
+	m.publish("v1", nil)
+	u, reloader, path := newTestUpdater(t, m, nil)
+	reloader.busy = 2
+
+	updated, err := u.Check(context.Background())
+	if err != nil || !updated {
+		t.Fatalf("Check() = %v, %v, want an update once the other reload is done", updated, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "v1" {
+		t.Errorf("dataset file = %q, want v1", data)
+	}
+	if len(reloader.loaded) != 1 || reloader.loaded[0] != "v1" {
+		t.Errorf("reloaded %v, want [v1]", reloader.loaded)
+	}
+	if status := u.Status(); status.ConsecutiveFailures != 0 || status.LastError != "" {
+		t.Errorf("Status() = %+v, want no failure", status)
+	}
+}
+
+func TestUpdater_Check_ReloadInProgress_Cancelled(t *testing.T) {
+	m := &mirror{modTime: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)}
+	m.publish("v1", nil)
+	u, reloader, path := newTestUpdater(t, m, nil)
+	reloader.busy = reloadRetries + 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*reloadBackoff)
+	defer cancel()
+
+	updated, err := u.Check(ctx)
+	if updated || !errors.Is(err, domain.ErrReloadInProgress) {
+		t.Fatalf("Check() = %v, %v, want %v", updated, err, domain.ErrReloadInProgress)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "v0" {
+		t.Errorf("dataset file = %q, want the previous v0", data)
+	}
+}
+
+func TestUpdater_Check_Failures(t *testing.T) {
+	tests := []struct {
+		name      string
+		tamper    func(m *mirror)
+		reloadErr error
+		wantErr   string
+	}{
+		{
+			name:    "checksum mismatch",
+			tamper:  func(m *mirror) { m.data = "corrupted" },
+			wantErr: "verify checksum",
+		},
+		{
+			name:    "checksum missing",
+			tamper:  func(m *mirror) { m.checksum = "" },
+			wantErr: "verify checksum",
+		},
+		{
+			name:      "repository rejects the file",
+			reloadErr: errors.New("dataset has no valid rows"),
+			wantErr:   "reload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mirror{modTime: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)}
+			m.publish("v1", nil)
+			if tt.tamper != nil {
+				tt.tamper(m)
+			}
+			u, reloader, path := newTestUpdater(t, m, nil)
+			reloader.err = tt.reloadErr
+
+			updated, err := u.Check(context.Background())
+			if updated || err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Check() = %v, %v, want error %q", updated, err, tt.wantErr)
+			}
+
+			if data, _ := os.ReadFile(path); string(data) != "v0" {
+				t.Errorf("dataset file = %q, want the previous v0", data)
+			}
+			status := u.Status()
+			if status.LastError == "" || status.ConsecutiveFailures != 1 || !status.LastSuccess.IsZero() {
+				t.Errorf("Status() = %+v, want one reported failure", status)
+			}
+		})
+	}
+}
+
+func TestUpdater_Check_Signature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	m := &mirror{modTime: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)}
+	m.publish("v1", otherKey)
+	u, reloader, path := newTestUpdater(t, m, publicKey)
+
+	if _, err := u.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "verify signature") {
+		t.Fatalf("Check() with a foreign signature error = %v, want a signature error", err)
+	}
+	if _, err := os.Stat(path + signatureSuffix); !os.IsNotExist(err) {
+		t.Errorf("signature installed for a rejected release: %v", err)
+	}
+
+	m.publish("v1", privateKey)
+	if updated, err := u.Check(context.Background()); err != nil || !updated {
+		t.Fatalf("Check() with a valid signature = %v, %v, want an update", updated, err)
+	}
+	installed, err := os.ReadFile(path + signatureSuffix)
+	if err != nil || string(installed) != m.signature {
+		t.Errorf("installed signature = %q, %v, want %q", installed, err, m.signature)
+	}
+
+	// A release the repository rejects leaves the installed signature.
+	m.publish("v2", privateKey)
+	reloader.err = errors.New("dataset has no valid rows")
+	if _, err := u.Check(context.Background()); err == nil {
+		t.Fatal("Check() with a failing reload should fail")
+	}
+	if got, _ := os.ReadFile(path + signatureSuffix); string(got) != string(installed) {
+		t.Errorf("signature after a failed reload = %q, want the previous %q", got, installed)
+	}
+}
@@ -0,0 +1,77 @@
+package filewatch
+
+import (
+	"context"
+	"os"
+	"slices"
+	"time"
+)
+
+// Watch polls path every interval until ctx is cancelled and calls onChange
+// when the file's size or modification time changed and then stayed the same
+// for one more interval, so a file that is still being copied is not reported
+// half-written. A missing file is treated as "no change".
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	WatchAll(ctx, []string{path}, interval, onChange)
+}
+
+// WatchAll is Watch over files that are only used together, such as a
+// dataset and its signature: onChange is called once when any of them
+// changed and then none changed for one more interval. While any of them is
+// missing nothing is reported.
+func WatchAll(ctx context.Context, paths []string, interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := statAll(paths)
+	var pending []fileState
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, ok := statAll(paths)
+		if !ok {
+			pending = nil
+			continue
+		}
+
+		if slices.Equal(current, last) {
+			pending = nil
+			continue
+		}
+
+		if pending == nil || !slices.Equal(pending, current) {
+			pending = current
+			continue
+		}
+
+		last = current
+		pending = nil
+		onChange()
+	}
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// statAll reports the state of every path, and false when one is missing.
+// The states of the files found are returned either way.
+func statAll(paths []string) ([]fileState, bool) {
+	states := make([]fileState, len(paths))
+	found := true
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			found = false
+			continue
+		}
+		states[i] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+	return states, found
+}
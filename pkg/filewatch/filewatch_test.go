@@ -0,0 +1,105 @@
+package filewatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	go Watch(ctx, path, 10*time.Millisecond, func() { calls.Add(1) })
+
+	time.Sleep(50 * time.Millisecond)
+	if got := calls.Load(); got != 0 {
+		t.Fatalf("onChange called %d times for an unchanged file, want 0", got)
+	}
+
+	if err := os.WriteFile(path, []byte("version two"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite file: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("onChange called %d times after one change, want 1", got)
+	}
+}
+
+func TestWatch_StopsOnCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.csv")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Watch(ctx, path, 10*time.Millisecond, func() {})
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Watch() did not return after context cancellation")
+	}
+}
+
+func TestWatchAll(t *testing.T) {
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "data.csv")
+	sigPath := filepath.Join(dir, "data.csv.sig")
+	if err := os.WriteFile(dataPath, []byte("v1"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	go WatchAll(ctx, []string{dataPath, sigPath}, 10*time.Millisecond, func() { calls.Add(1) })
+
+	waitForCalls := func(want int32) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for calls.Load() < want && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond)
+		if got := calls.Load(); got != want {
+			t.Fatalf("onChange called %d times, want %d", got, want)
+		}
+	}
+
+	// Nothing is reported while the signature is missing.
+	if err := os.WriteFile(dataPath, []byte("version two"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite file: %v", err)
+	}
+	waitForCalls(0)
+
+	// The signature arriving later is a change of the group.
+	if err := os.WriteFile(sigPath, []byte("signature"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	waitForCalls(1)
+
+	if err := os.WriteFile(sigPath, []byte("new signature"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite file: %v", err)
+	}
+	waitForCalls(2)
+}
@@ -0,0 +1,54 @@
+package iputil
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// Range is an inclusive span of addresses of one family.
+type Range struct {
+	First netip.Addr
+	Last  netip.Addr
+}
+
+// MergeRanges returns ranges sorted, IPv4 before IPv6, with overlapping and
+// adjacent ranges of the same family joined. The input is not modified.
+func MergeRanges(ranges []Range) []Range {
+	sorted := slices.Clone(ranges)
+	slices.SortFunc(sorted, func(a, b Range) int {
+		return a.First.Compare(b.First)
+	})
+
+	var merged []Range
+	for _, r := range sorted {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			// Next is invalid past the end of the family, where nothing can
+			// follow anyway.
+			if next := last.Last.Next(); last.Last.Is4() == r.First.Is4() &&
+				(!next.IsValid() || r.First.Compare(next) <= 0) {
+				if last.Last.Less(r.Last) {
+					last.Last = r.Last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// AggregateRanges returns the fewest CIDR prefixes that exactly cover the
+// union of ranges, IPv4 first and each family in ascending order.
+func AggregateRanges(ranges []Range) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, r := range MergeRanges(ranges) {
+		p, err := RangeToPrefixes(r.First, r.Last)
+		if err != nil {
+			return nil, fmt.Errorf("aggregate ranges: %w", err)
+		}
+		prefixes = append(prefixes, p...)
+	}
+	return prefixes, nil
+}
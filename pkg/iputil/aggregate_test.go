@@ -0,0 +1,68 @@
+package iputil
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestAggregateRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges [][2]string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			ranges: nil,
+			want:   nil,
+		},
+		{
+			name:   "adjacent ranges join into one block",
+			ranges: [][2]string{{"10.0.1.0", "10.0.1.255"}, {"10.0.0.0", "10.0.0.255"}},
+			want:   []string{"10.0.0.0/23"},
+		},
+		{
+			name:   "overlapping and nested ranges",
+			ranges: [][2]string{{"10.0.0.0", "10.0.0.200"}, {"10.0.0.100", "10.0.0.255"}, {"10.0.0.5", "10.0.0.6"}},
+			want:   []string{"10.0.0.0/24"},
+		},
+		{
+			name:   "gap keeps ranges apart",
+			ranges: [][2]string{{"10.0.0.0", "10.0.0.255"}, {"10.0.2.0", "10.0.2.255"}},
+			want:   []string{"10.0.0.0/24", "10.0.2.0/24"},
+		},
+		{
+			name:   "families stay apart, IPv4 first",
+			ranges: [][2]string{{"2001:db8::", "2001:db8::ff"}, {"255.255.255.0", "255.255.255.255"}, {"::", "::ff"}},
+			want:   []string{"255.255.255.0/24", "::/120", "2001:db8::/120"},
+		},
+		{
+			name:   "end of the IPv6 space",
+			ranges: [][2]string{{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}, {"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff80", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}},
+			want:   []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/120"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []Range
+			for _, r := range tt.ranges {
+				ranges = append(ranges, Range{First: netip.MustParseAddr(r[0]), Last: netip.MustParseAddr(r[1])})
+			}
+
+			prefixes, err := AggregateRanges(ranges)
+			if err != nil {
+				t.Fatalf("AggregateRanges() error = %v", err)
+			}
+
+			var got []string
+			for _, p := range prefixes {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AggregateRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package iputil
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math/bits"
+	"net/netip"
 	"strconv"
 	"strings"
 )
@@ -32,3 +35,60 @@ func IPToID(ip string) (uint32, error) {
 
 	return ipID, nil
 }
+
+// IPv6ToID converts an IPv6 address (including IPv4-mapped forms such as
+// ::ffff:8.8.8.8) into its 128-bit IP number, split into high and low halves.
+func IPv6ToID(ip string) (hi, lo uint64, err error) {
+	ip = strings.TrimSpace(ip)
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid IPv6 address '%s': %w", ip, err)
+	}
+	if !addr.Is6() {
+		return 0, 0, fmt.Errorf("invalid IPv6 address '%s': not an IPv6 address", ip)
+	}
+	if addr.Zone() != "" {
+		return 0, 0, fmt.Errorf("invalid IPv6 address '%s': zones are not supported", ip)
+	}
+
+	b := addr.As16()
+	return binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:]), nil
+}
+
+// ParseDecimal128 parses an unsigned decimal number of up to 128 bits, the
+// format IP2Location uses for ip_from/ip_to in its IPv6 datasets.
+func ParseDecimal128(s string) (hi, lo uint64, err error) {
+	if s == "" {
+		return 0, 0, fmt.Errorf("parse decimal '%s': empty value", s)
+	}
+
+	// Fast path: every IPv4 row and most IPv6 rows below 2^64.
+	if len(s) <= 19 {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse decimal '%s': %w", s, err)
+		}
+		return 0, v, nil
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return 0, 0, fmt.Errorf("parse decimal '%s': invalid digit %q", s, c)
+		}
+
+		// (hi, lo) = (hi, lo) * 10 + digit
+		carry, newLo := bits.Mul64(lo, 10)
+		overflow, newHi := bits.Mul64(hi, 10)
+		newHi, c2 := bits.Add64(newHi, carry, 0)
+		newLo, c1 := bits.Add64(newLo, uint64(c-'0'), 0)
+		newHi, c3 := bits.Add64(newHi, c1, 0)
+		if overflow != 0 || c2 != 0 || c3 != 0 {
+			return 0, 0, fmt.Errorf("parse decimal '%s': value out of 128-bit range", s)
+		}
+		hi, lo = newHi, newLo
+	}
+
+	return hi, lo, nil
+}
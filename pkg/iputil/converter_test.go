@@ -107,6 +107,133 @@ func TestIPToID(t *testing.T) {
 	}
 }
 
+func TestIPv6ToID(t *testing.T) {
+	tests := []struct {
+		name    string
+		ip      string
+		wantHi  uint64
+		wantLo  uint64
+		wantErr bool
+	}{
+		{
+			name:   "documentation prefix",
+			ip:     "2001:db8::1",
+			wantHi: 0x20010db800000000,
+			wantLo: 1,
+		},
+		{
+			name:   "IPv4-mapped address",
+			ip:     "::ffff:8.8.8.8",
+			wantHi: 0,
+			wantLo: 0xffff08080808,
+		},
+		{
+			name:   "all ones",
+			ip:     "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			wantHi: 0xffffffffffffffff,
+			wantLo: 0xffffffffffffffff,
+		},
+		{
+			name:   "with spaces",
+			ip:     "  2001:db8::1  ",
+			wantHi: 0x20010db800000000,
+			wantLo: 1,
+		},
+		{
+			name:    "plain IPv4 is rejected",
+			ip:      "8.8.8.8",
+			wantErr: true,
+		},
+		{
+			name:    "zone is rejected",
+			ip:      "fe80::1%eth0",
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			ip:      "2001:db8:::1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hi, lo, err := IPv6ToID(tt.ip)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("IPv6ToID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if hi != tt.wantHi || lo != tt.wantLo {
+				t.Errorf("IPv6ToID() = %x:%x, want %x:%x", hi, lo, tt.wantHi, tt.wantLo)
+			}
+		})
+	}
+}
+
+func TestParseDecimal128(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		wantHi  uint64
+		wantLo  uint64
+		wantErr bool
+	}{
+		{
+			name:   "IPv4 sized value",
+			s:      "134744072",
+			wantLo: 134744072,
+		},
+		{
+			name:   "IPv4-mapped value",
+			s:      "281470816487432",
+			wantLo: 0xffff08080808,
+		},
+		{
+			name:   "2001:db8::",
+			s:      "42540766411282592856903984951653826560",
+			wantHi: 0x20010db800000000,
+		},
+		{
+			name:   "max 128-bit value",
+			s:      "340282366920938463463374607431768211455",
+			wantHi: 0xffffffffffffffff,
+			wantLo: 0xffffffffffffffff,
+		},
+		{
+			name:    "overflow",
+			s:       "340282366920938463463374607431768211456",
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric",
+			s:       "4254076641128259285690398495165382656x",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			s:       "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hi, lo, err := ParseDecimal128(tt.s)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDecimal128() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if hi != tt.wantHi || lo != tt.wantLo {
+				t.Errorf("ParseDecimal128() = %x:%x, want %x:%x", hi, lo, tt.wantHi, tt.wantLo)
+			}
+		})
+	}
+}
+
 func BenchmarkIPToID(b *testing.B) {
 	testIP := "192.168.1.1"
 
@@ -0,0 +1,101 @@
+package iputil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net/netip"
+)
+
+// RangeToPrefixes returns the smallest list of CIDR prefixes that exactly
+// covers first..last (inclusive), in ascending order. Both addresses must be
+// of the same family; IPv4-mapped IPv6 addresses are treated as IPv6.
+func RangeToPrefixes(first, last netip.Addr) ([]netip.Prefix, error) {
+	if !first.IsValid() || !last.IsValid() {
+		return nil, fmt.Errorf("invalid range %s-%s", first, last)
+	}
+	if first.Is4() != last.Is4() {
+		return nil, fmt.Errorf("range %s-%s mixes IPv4 and IPv6", first, last)
+	}
+	if last.Less(first) {
+		return nil, fmt.Errorf("range %s-%s: start is after end", first, last)
+	}
+
+	bitLen := first.BitLen()
+	start, end := toUint128(first), toUint128(last)
+
+	var prefixes []netip.Prefix
+	for {
+		// Grow the block while start stays aligned to it and it ends by end.
+		hostBits := min(start.trailingZeros(), bitLen)
+		for hostBits > 0 && end.less(start.or(mask128(hostBits))) {
+			hostBits--
+		}
+
+		prefixes = append(prefixes, netip.PrefixFrom(start.addr(bitLen), bitLen-hostBits))
+
+		blockEnd := start.or(mask128(hostBits))
+		if blockEnd == end {
+			return prefixes, nil
+		}
+		start = blockEnd.inc()
+	}
+}
+
+// uint128 is an address as a 128-bit number; IPv4 addresses use the low 32
+// bits.
+type uint128 struct {
+	hi, lo uint64
+}
+
+func toUint128(addr netip.Addr) uint128 {
+	if addr.Is4() {
+		b := addr.As4()
+		return uint128{lo: uint64(binary.BigEndian.Uint32(b[:]))}
+	}
+	b := addr.As16()
+	return uint128{hi: binary.BigEndian.Uint64(b[:8]), lo: binary.BigEndian.Uint64(b[8:])}
+}
+
+func (u uint128) addr(bitLen int) netip.Addr {
+	if bitLen == 32 {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(u.lo))
+		return netip.AddrFrom4(b)
+	}
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.hi)
+	binary.BigEndian.PutUint64(b[8:], u.lo)
+	return netip.AddrFrom16(b)
+}
+
+// mask128 returns a number with the low n bits set.
+func mask128(n int) uint128 {
+	switch {
+	case n >= 128:
+		return uint128{hi: ^uint64(0), lo: ^uint64(0)}
+	case n >= 64:
+		return uint128{hi: 1<<(n-64) - 1, lo: ^uint64(0)}
+	}
+	return uint128{lo: 1<<n - 1}
+}
+
+func (u uint128) trailingZeros() int {
+	if u.lo != 0 {
+		return bits.TrailingZeros64(u.lo)
+	}
+	return 64 + bits.TrailingZeros64(u.hi)
+}
+
+func (u uint128) or(v uint128) uint128 {
+	return uint128{hi: u.hi | v.hi, lo: u.lo | v.lo}
+}
+
+func (u uint128) less(v uint128) bool {
+	return u.hi < v.hi || (u.hi == v.hi && u.lo < v.lo)
+}
+
+func (u uint128) inc() uint128 {
+	lo, carry := bits.Add64(u.lo, 1, 0)
+	return uint128{hi: u.hi + carry, lo: lo}
+}
@@ -0,0 +1,98 @@
+package iputil
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   string
+		last    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "single address",
+			first: "8.8.8.8",
+			last:  "8.8.8.8",
+			want:  []string{"8.8.8.8/32"},
+		},
+		{
+			name:  "aligned block",
+			first: "8.8.8.0",
+			last:  "8.8.8.255",
+			want:  []string{"8.8.8.0/24"},
+		},
+		{
+			name:  "unaligned range",
+			first: "1.0.0.1",
+			last:  "1.0.0.10",
+			want:  []string{"1.0.0.1/32", "1.0.0.2/31", "1.0.0.4/30", "1.0.0.8/31", "1.0.0.10/32"},
+		},
+		{
+			name:  "whole IPv4 space",
+			first: "0.0.0.0",
+			last:  "255.255.255.255",
+			want:  []string{"0.0.0.0/0"},
+		},
+		{
+			name:  "ends at the top of the IPv4 space",
+			first: "255.255.255.254",
+			last:  "255.255.255.255",
+			want:  []string{"255.255.255.254/31"},
+		},
+		{
+			name:  "IPv6 block",
+			first: "2001:db8::",
+			last:  "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff",
+			want:  []string{"2001:db8::/32"},
+		},
+		{
+			name:  "IPv6 range crossing the 64-bit boundary",
+			first: "2001:db8:0:0:ffff:ffff:ffff:ffff",
+			last:  "2001:db8:0:1::",
+			want:  []string{"2001:db8::ffff:ffff:ffff:ffff/128", "2001:db8:0:1::/128"},
+		},
+		{
+			name:  "whole IPv6 space",
+			first: "::",
+			last:  "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			want:  []string{"::/0"},
+		},
+		{
+			name:    "start after end",
+			first:   "8.8.8.9",
+			last:    "8.8.8.8",
+			wantErr: true,
+		},
+		{
+			name:    "mixed families",
+			first:   "8.8.8.8",
+			last:    "2001:db8::",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RangeToPrefixes(netip.MustParseAddr(tt.first), netip.MustParseAddr(tt.last))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RangeToPrefixes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var gotStrings []string
+			for _, p := range got {
+				gotStrings = append(gotStrings, p.String())
+			}
+			if !reflect.DeepEqual(gotStrings, tt.want) {
+				t.Errorf("RangeToPrefixes() = %v, want %v", gotStrings, tt.want)
+			}
+		})
+	}
+}
@@ -148,3 +148,9 @@ func Debugf(format string, v ...interface{}) {
 func SetLevel(level Level) {
 	defaultLogger.minLevel = level
 }
+
+// SetDefault substitui o logger global, por exemplo para enviar os logs de
+// uma ferramenta de linha de comando ao stderr
+func SetDefault(l *Logger) {
+	defaultLogger = l
+}
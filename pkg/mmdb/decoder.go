@@ -0,0 +1,236 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Data section field types, as numbered by the MaxMind DB format spec.
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDepth bounds nesting so a malicious file cannot exhaust the stack.
+const maxDepth = 64
+
+// decoder reads values from a data section. Pointers are offsets relative to
+// the start of buf.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset just past it.
+//
+// Values map to Go types as follows: map → map[string]any, array → []any,
+// string → string, double → float64, float → float32, bytes → []byte,
+// uint16/uint32/uint64 → uint64, int32 → int32, uint128 → *big.Int,
+// boolean → bool.
+func (d *decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("%w: data nested deeper than %d", ErrInvalidDatabase, maxDepth)
+	}
+
+	typeNum, size, offset, err := d.decodeControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typeNum == typePointer {
+		pointer, next, err := d.decodePointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// A pointer's target is never another pointer, but it may be any
+		// other value, including containers full of pointers.
+		value, _, err := d.decode(pointer, depth+1)
+		return value, next, err
+	}
+
+	return d.decodeValue(typeNum, size, offset, depth)
+}
+
+func (d *decoder) decodeControl(offset uint) (typeNum int, size uint, next uint, err error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, fmt.Errorf("%w: offset %d past end of data", ErrInvalidDatabase, offset)
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+
+	typeNum = int(ctrl >> 5)
+	if typeNum == typePointer {
+		return typeNum, uint(ctrl & 0x1f), offset, nil
+	}
+
+	if typeNum == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, fmt.Errorf("%w: truncated extended type", ErrInvalidDatabase)
+		}
+		typeNum = 7 + int(d.buf[offset])
+		offset++
+		if typeNum < typeInt32 || typeNum > typeFloat {
+			return 0, 0, 0, fmt.Errorf("%w: unknown extended type %d", ErrInvalidDatabase, typeNum)
+		}
+	}
+
+	size = uint(ctrl & 0x1f)
+	if size >= 29 {
+		extra := size - 28
+		if offset+extra > uint(len(d.buf)) {
+			return 0, 0, 0, fmt.Errorf("%w: truncated size", ErrInvalidDatabase)
+		}
+		n := uint(uintFromBytes(d.buf[offset : offset+extra]))
+		offset += extra
+
+		switch size {
+		case 29:
+			size = 29 + n
+		case 30:
+			size = 285 + n
+		default:
+			size = 65821 + n
+		}
+	}
+
+	return typeNum, size, offset, nil
+}
+
+// decodePointer resolves a pointer whose control byte carried ctrlBits.
+func (d *decoder) decodePointer(ctrlBits uint, offset uint) (uint, uint, error) {
+	pointerSize := ((ctrlBits >> 3) & 0x3) + 1
+	if offset+pointerSize > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("%w: truncated pointer", ErrInvalidDatabase)
+	}
+
+	value := uint(uintFromBytes(d.buf[offset : offset+pointerSize]))
+	if pointerSize != 4 {
+		value |= (ctrlBits & 0x7) << (8 * pointerSize)
+	}
+
+	switch pointerSize {
+	case 2:
+		value += 2048
+	case 3:
+		value += 526336
+	}
+
+	return value, offset + pointerSize, nil
+}
+
+func (d *decoder) decodeValue(typeNum int, size uint, offset uint, depth int) (any, uint, error) {
+	switch typeNum {
+	case typeMap:
+		return d.decodeMap(size, offset, depth)
+	case typeArray:
+		return d.decodeArray(size, offset, depth)
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("%w: boolean with size %d", ErrInvalidDatabase, size)
+		}
+		return size == 1, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, 0, fmt.Errorf("%w: unexpected type %d in data", ErrInvalidDatabase, typeNum)
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("%w: value of %d bytes past end of data", ErrInvalidDatabase, size)
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+
+	switch typeNum {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: double with size %d", ErrInvalidDatabase, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: float with size %d", ErrInvalidDatabase, size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		maxSize := map[int]uint{typeUint16: 2, typeUint32: 4, typeUint64: 8}[typeNum]
+		if size > maxSize {
+			return nil, 0, fmt.Errorf("%w: type %d with size %d", ErrInvalidDatabase, typeNum, size)
+		}
+		return uintFromBytes(b), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: int32 with size %d", ErrInvalidDatabase, size)
+		}
+		return int32(uint32(uintFromBytes(b))), next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("%w: uint128 with size %d", ErrInvalidDatabase, size)
+		}
+		return new(big.Int).SetBytes(b), next, nil
+	}
+
+	return nil, 0, fmt.Errorf("%w: unknown type %d", ErrInvalidDatabase, typeNum)
+}
+
+func (d *decoder) decodeMap(size uint, offset uint, depth int) (any, uint, error) {
+	// size comes from the file; entries are only allocated as they decode.
+	m := make(map[string]any, min(size, 1024))
+	for i := uint(0); i < size; i++ {
+		key, next, err := d.decode(offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			return nil, 0, fmt.Errorf("%w: map key of type %T", ErrInvalidDatabase, key)
+		}
+
+		value, next, err := d.decode(next, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		m[k] = value
+		offset = next
+	}
+	return m, offset, nil
+}
+
+func (d *decoder) decodeArray(size uint, offset uint, depth int) (any, uint, error) {
+	a := make([]any, 0, min(size, 1024))
+	for i := uint(0); i < size; i++ {
+		value, next, err := d.decode(offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		a = append(a, value)
+		offset = next
+	}
+	return a, offset, nil
+}
+
+func uintFromBytes(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
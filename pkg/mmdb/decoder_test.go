@@ -0,0 +1,189 @@
+package mmdb
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		want any
+	}{
+		{
+			name: "short string",
+			buf:  []byte{0x43, 'f', 'o', 'o'},
+			want: "foo",
+		},
+		{
+			name: "empty string",
+			buf:  []byte{0x40},
+			want: "",
+		},
+		{
+			name: "string with size 29 encoding",
+			buf:  append([]byte{0x5d, 0x01}, []byte(strings.Repeat("a", 30))...),
+			want: strings.Repeat("a", 30),
+		},
+		{
+			name: "string with size 30 encoding",
+			buf:  append([]byte{0x5e, 0x00, 0x0f}, []byte(strings.Repeat("b", 300))...),
+			want: strings.Repeat("b", 300),
+		},
+		{
+			name: "double",
+			buf:  []byte{0x68, 0x40, 0x42, 0xc0, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want: 37.5,
+		},
+		{
+			name: "uint16",
+			buf:  []byte{0xa2, 0x01, 0xbb},
+			want: uint64(443),
+		},
+		{
+			name: "uint32 zero size",
+			buf:  []byte{0xc0},
+			want: uint64(0),
+		},
+		{
+			name: "int32 negative",
+			buf:  []byte{0x04, 0x01, 0xff, 0xff, 0xff, 0xfe},
+			want: int32(-2),
+		},
+		{
+			name: "uint64",
+			buf:  []byte{0x08, 0x02, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			want: uint64(1) << 56,
+		},
+		{
+			name: "uint128",
+			buf:  []byte{0x01, 0x03, 0x01},
+			want: big.NewInt(1),
+		},
+		{
+			name: "boolean true",
+			buf:  []byte{0x01, 0x07},
+			want: true,
+		},
+		{
+			name: "float",
+			buf:  []byte{0x04, 0x08, 0x3f, 0x80, 0x00, 0x00},
+			want: float32(1),
+		},
+		{
+			name: "array",
+			buf:  []byte{0x02, 0x04, 0x42, 'e', 'n', 0x42, 'p', 't'},
+			want: []any{"en", "pt"},
+		},
+		{
+			name: "map",
+			buf:  []byte{0xe1, 0x42, 'e', 'n', 0x43, 'f', 'o', 'o'},
+			want: map[string]any{"en": "foo"},
+		},
+		{
+			name: "map with pointer value",
+			// {"a": ptr(0)} where offset 0 holds "foo"
+			buf:  []byte{0x43, 'f', 'o', 'o', 0xe1, 0x41, 'a', 0x20, 0x00},
+			want: "foo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := decoder{buf: tt.buf}
+
+			offset := uint(0)
+			if tt.name == "map with pointer value" {
+				offset = 4
+			}
+
+			got, _, err := d.decode(offset, 0)
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+
+			if tt.name == "map with pointer value" {
+				got = got.(map[string]any)["a"]
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decode() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoder_DecodePointerSizes(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctrlBits uint
+		bytes    []byte
+		want     uint
+	}{
+		{name: "size 1", ctrlBits: 0x05, bytes: []byte{0x01}, want: 0x501},
+		{name: "size 2", ctrlBits: 0x08 | 0x01, bytes: []byte{0x00, 0x00}, want: 0x10000 + 2048},
+		{name: "size 3", ctrlBits: 0x10, bytes: []byte{0x00, 0x00, 0x01}, want: 1 + 526336},
+		{name: "size 4", ctrlBits: 0x18 | 0x07, bytes: []byte{0x00, 0x00, 0x01, 0x00}, want: 256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := decoder{buf: tt.bytes}
+			got, next, err := d.decodePointer(tt.ctrlBits, 0)
+			if err != nil {
+				t.Fatalf("decodePointer() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("decodePointer() = %d, want %d", got, tt.want)
+			}
+			if next != uint(len(tt.bytes)) {
+				t.Errorf("decodePointer() next = %d, want %d", next, len(tt.bytes))
+			}
+		})
+	}
+}
+
+func TestDecoder_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{name: "truncated string", buf: []byte{0x45, 'a'}},
+		{name: "double with wrong size", buf: []byte{0x64, 0, 0, 0, 0}},
+		{name: "unknown extended type", buf: []byte{0x00, 0x20}},
+		{name: "map with non-string key", buf: []byte{0xe1, 0xa1, 0x01, 0x40}},
+		{name: "map declaring 16M entries", buf: []byte{0xff, 0xff, 0xff, 0xff}},
+		{name: "pointer loop", buf: []byte{0x20, 0x00}},
+		{name: "empty buffer", buf: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := decoder{buf: tt.buf}
+			if _, _, err := d.decode(0, 0); !errors.Is(err, ErrInvalidDatabase) {
+				t.Errorf("decode() error = %v, want ErrInvalidDatabase", err)
+			}
+		})
+	}
+}
+
+// A declared size is not trusted before the entries are there.
+func TestDecoder_HugeMapSize(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	d := decoder{buf: []byte{0xff, 0xff, 0xff, 0xff}}
+	_, _, err := d.decode(0, 0)
+	runtime.ReadMemStats(&after)
+
+	if !errors.Is(err, ErrInvalidDatabase) {
+		t.Errorf("decode() error = %v, want ErrInvalidDatabase", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("decode() allocated %d bytes for a truncated map", allocated)
+	}
+}
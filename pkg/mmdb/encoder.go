@@ -0,0 +1,230 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// maxValueSize is the largest payload a control byte can describe.
+const maxValueSize = 65821 + 1<<24 - 1
+
+// encoder appends values to a data section. When pointers is set, strings
+// that were already written are replaced by a pointer to the first copy
+// whenever that is shorter.
+type encoder struct {
+	buf      []byte
+	pointers bool
+	strings  map[string]uint
+}
+
+// encode appends value. Supported Go types are the ones the decoder returns
+// plus a few conveniences: map[string]string, []string, uint16, uint32, uint,
+// bool, int32 and *big.Int (uint128).
+func (e *encoder) encode(value any) error {
+	switch v := value.(type) {
+	case string:
+		return e.encodeString(v)
+	case float64:
+		e.control(typeDouble, 8)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+	case float32:
+		e.control(typeFloat, 4)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(v))
+	case []byte:
+		if err := e.checkSize(len(v)); err != nil {
+			return err
+		}
+		e.control(typeBytes, len(v))
+		e.buf = append(e.buf, v...)
+	case uint16:
+		e.encodeUint(typeUint16, uint64(v))
+	case uint32:
+		e.encodeUint(typeUint32, uint64(v))
+	case uint64:
+		e.encodeUint(typeUint64, v)
+	case uint:
+		e.encodeUint(typeUint64, uint64(v))
+	case int32:
+		e.encodeUint(typeInt32, uint64(uint32(v)))
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		e.control(typeBool, size)
+	case *big.Int:
+		if v.Sign() < 0 || v.BitLen() > 128 {
+			return fmt.Errorf("uint128 out of range: %s", v)
+		}
+		b := v.Bytes()
+		e.control(typeUint128, len(b))
+		e.buf = append(e.buf, b...)
+	case []any:
+		if err := e.checkSize(len(v)); err != nil {
+			return err
+		}
+		e.control(typeArray, len(v))
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+	case []string:
+		if err := e.checkSize(len(v)); err != nil {
+			return err
+		}
+		e.control(typeArray, len(v))
+		for _, item := range v {
+			if err := e.encodeString(item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		return encodeMap(e, v)
+	case map[string]string:
+		return encodeMap(e, v)
+	default:
+		return fmt.Errorf("cannot encode value of type %T", value)
+	}
+	return nil
+}
+
+// encodeMap writes keys in sorted order so equal maps encode identically,
+// which is what record deduplication relies on.
+func encodeMap[V any](e *encoder, m map[string]V) error {
+	if err := e.checkSize(len(m)); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	e.control(typeMap, len(keys))
+	for _, k := range keys {
+		if err := e.encodeString(k); err != nil {
+			return err
+		}
+		if err := e.encode(m[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) encodeString(s string) error {
+	if err := e.checkSize(len(s)); err != nil {
+		return err
+	}
+
+	if e.pointers {
+		if offset, ok := e.strings[s]; ok && pointerSize(offset) < controlSize(len(s))+len(s) {
+			e.pointer(offset)
+			return nil
+		}
+		if e.strings == nil {
+			e.strings = make(map[string]uint)
+		}
+		if _, ok := e.strings[s]; !ok {
+			e.strings[s] = uint(len(e.buf))
+		}
+	}
+
+	e.control(typeString, len(s))
+	e.buf = append(e.buf, s...)
+	return nil
+}
+
+// encodeUint writes v with leading zero bytes stripped.
+func (e *encoder) encodeUint(typeNum int, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	i := 0
+	for i < len(b) && b[i] == 0 {
+		i++
+	}
+	e.control(typeNum, len(b)-i)
+	e.buf = append(e.buf, b[i:]...)
+}
+
+func (e *encoder) checkSize(size int) error {
+	if size > maxValueSize {
+		return fmt.Errorf("value of %d bytes or items exceeds the format limit", size)
+	}
+	return nil
+}
+
+// control writes the control byte(s) for a value of typeNum and size.
+func (e *encoder) control(typeNum int, size int) {
+	var sizeBits byte
+	var extra []byte
+	switch {
+	case size < 29:
+		sizeBits = byte(size)
+	case size < 285:
+		sizeBits = 29
+		extra = []byte{byte(size - 29)}
+	case size < 65821:
+		sizeBits = 30
+		n := size - 285
+		extra = []byte{byte(n >> 8), byte(n)}
+	default:
+		sizeBits = 31
+		n := size - 65821
+		extra = []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+
+	if typeNum > typeMap {
+		e.buf = append(e.buf, sizeBits, byte(typeNum-7))
+	} else {
+		e.buf = append(e.buf, byte(typeNum)<<5|sizeBits)
+	}
+	e.buf = append(e.buf, extra...)
+}
+
+func (e *encoder) pointer(offset uint) {
+	switch {
+	case offset < 2048:
+		e.buf = append(e.buf, typePointer<<5|byte(offset>>8), byte(offset))
+	case offset < 526336:
+		n := offset - 2048
+		e.buf = append(e.buf, typePointer<<5|0x08|byte(n>>16), byte(n>>8), byte(n))
+	case offset < 526336+1<<27:
+		n := offset - 526336
+		e.buf = append(e.buf, typePointer<<5|0x10|byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		e.buf = append(e.buf, typePointer<<5|0x18)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(offset))
+	}
+}
+
+// pointerSize is the encoded length of a pointer to offset.
+func pointerSize(offset uint) int {
+	switch {
+	case offset < 2048:
+		return 2
+	case offset < 526336:
+		return 3
+	case offset < 526336+1<<27:
+		return 4
+	}
+	return 5
+}
+
+// controlSize is the length of the control bytes of a string of size bytes.
+func controlSize(size int) int {
+	switch {
+	case size < 29:
+		return 1
+	case size < 285:
+		return 2
+	case size < 65821:
+		return 3
+	}
+	return 4
+}
@@ -0,0 +1,248 @@
+// Package mmdb reads and writes MaxMind DB (.mmdb) files, the binary format
+// used by GeoLite2/GeoIP2 and by the nginx and Envoy geo modules.
+//
+// See https://maxmind.github.io/MaxMind-DB/ for the format specification.
+package mmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// metadataStartMarker precedes the metadata map at the end of every file.
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparatorSize is the 16 zero bytes between tree and data.
+const dataSectionSeparatorSize = 16
+
+var ErrInvalidDatabase = errors.New("invalid MaxMind DB file")
+
+// Metadata describes the database. Field names follow the spec.
+type Metadata struct {
+	NodeCount                uint
+	RecordSize               uint
+	IPVersion                uint
+	DatabaseType             string
+	Languages                []string
+	BinaryFormatMajorVersion uint
+	BinaryFormatMinorVersion uint
+	BuildEpoch               uint64
+	Description              map[string]string
+}
+
+// Reader looks up addresses in an MMDB file held in memory (typically an mmap).
+type Reader struct {
+	Metadata Metadata
+
+	buffer       []byte
+	data         decoder
+	nodeByteSize uint
+	treeSize     uint
+	ipv4Start    uint
+}
+
+// Result is the outcome of a lookup. Network is the most specific prefix of
+// the tree that contains the address, whether or not a record was found.
+type Result struct {
+	Found   bool
+	Record  any
+	Network netip.Prefix
+}
+
+// FromBytes parses buffer as an MMDB file. The reader keeps using buffer, so it
+// must not be modified or unmapped while the reader is in use.
+func FromBytes(buffer []byte) (*Reader, error) {
+	markerIdx := bytes.LastIndex(buffer, metadataStartMarker)
+	if markerIdx == -1 {
+		return nil, fmt.Errorf("%w: metadata marker not found", ErrInvalidDatabase)
+	}
+
+	metadataStart := markerIdx + len(metadataStartMarker)
+	metaDecoder := decoder{buf: buffer[metadataStart:]}
+	raw, _, err := metaDecoder.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("decode metadata: %w", err)
+	}
+
+	metadata, err := parseMetadata(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata.BinaryFormatMajorVersion != 2 {
+		return nil, fmt.Errorf("%w: unsupported binary format version %d", ErrInvalidDatabase, metadata.BinaryFormatMajorVersion)
+	}
+	switch metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, metadata.RecordSize)
+	}
+	if metadata.IPVersion != 4 && metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", ErrInvalidDatabase, metadata.IPVersion)
+	}
+
+	nodeByteSize := metadata.RecordSize / 4
+	treeSize := metadata.NodeCount * nodeByteSize
+	dataStart := treeSize + dataSectionSeparatorSize
+	if dataStart > uint(markerIdx) {
+		return nil, fmt.Errorf("%w: search tree larger than file", ErrInvalidDatabase)
+	}
+
+	r := &Reader{
+		Metadata:     metadata,
+		buffer:       buffer,
+		data:         decoder{buf: buffer[dataStart:markerIdx]},
+		nodeByteSize: nodeByteSize,
+		treeSize:     treeSize,
+	}
+
+	// IPv4 addresses live under ::/96 in an IPv6 tree; find that node once.
+	if metadata.IPVersion == 6 {
+		node := uint(0)
+		for depth := 0; depth < 96 && node < metadata.NodeCount; depth++ {
+			node, err = r.readNode(node, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Lookup finds the record for ip. IPv4 (and IPv4-mapped IPv6) addresses are
+// looked up in the IPv4 subtree.
+func (r *Reader) Lookup(ip netip.Addr) (Result, error) {
+	if ip.Is4In6() {
+		ip = ip.Unmap()
+	}
+	if ip.Is6() && r.Metadata.IPVersion == 4 {
+		return Result{}, fmt.Errorf("cannot look up IPv6 address %s in an IPv4-only database", ip)
+	}
+
+	raw := ip.AsSlice()
+	bitCount := len(raw) * 8
+
+	node := uint(0)
+	if ip.Is4() && r.Metadata.IPVersion == 6 {
+		node = r.ipv4Start
+		// The ::/96 walk may already have ended in a data record or empty
+		// slot; in that case the whole IPv4 space shares it.
+		if node >= r.Metadata.NodeCount {
+			return r.result(node, ip, 0)
+		}
+	}
+
+	var err error
+	depth := 0
+	for ; depth < bitCount && node < r.Metadata.NodeCount; depth++ {
+		bit := uint(raw[depth>>3]>>(7-uint(depth&7))) & 1
+		node, err = r.readNode(node, bit)
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	return r.result(node, ip, depth)
+}
+
+func (r *Reader) result(node uint, ip netip.Addr, prefixLen int) (Result, error) {
+	network, err := ip.Prefix(prefixLen)
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch {
+	case node == r.Metadata.NodeCount:
+		return Result{Network: network}, nil
+	case node < r.Metadata.NodeCount:
+		return Result{}, fmt.Errorf("%w: search tree deeper than address", ErrInvalidDatabase)
+	}
+
+	if node-r.Metadata.NodeCount < dataSectionSeparatorSize {
+		return Result{}, fmt.Errorf("%w: record points into the data section separator", ErrInvalidDatabase)
+	}
+
+	record, _, err := r.data.decode(node-r.Metadata.NodeCount-dataSectionSeparatorSize, 0)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Found: true, Record: record, Network: network}, nil
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of node.
+func (r *Reader) readNode(node uint, bit uint) (uint, error) {
+	offset := node * r.nodeByteSize
+	if offset+r.nodeByteSize > r.treeSize {
+		return 0, fmt.Errorf("%w: node %d outside search tree", ErrInvalidDatabase, node)
+	}
+	b := r.buffer[offset : offset+r.nodeByteSize]
+
+	switch r.Metadata.RecordSize {
+	case 24:
+		if bit == 0 {
+			return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return uint(b[3])<<16 | uint(b[4])<<8 | uint(b[5]), nil
+	case 28:
+		if bit == 0 {
+			return uint(b[3]>>4)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		if bit == 0 {
+			return uint(binary.BigEndian.Uint32(b[:4])), nil
+		}
+		return uint(binary.BigEndian.Uint32(b[4:])), nil
+	}
+}
+
+func parseMetadata(raw any) (Metadata, error) {
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return Metadata{}, fmt.Errorf("%w: metadata is %T, not a map", ErrInvalidDatabase, raw)
+	}
+
+	var md Metadata
+	var err error
+	uintField := func(key string) uint64 {
+		v, ok := m[key].(uint64)
+		if !ok && err == nil {
+			err = fmt.Errorf("%w: metadata field %q missing or not an unsigned integer", ErrInvalidDatabase, key)
+		}
+		return v
+	}
+
+	md.NodeCount = uint(uintField("node_count"))
+	md.RecordSize = uint(uintField("record_size"))
+	md.IPVersion = uint(uintField("ip_version"))
+	md.BinaryFormatMajorVersion = uint(uintField("binary_format_major_version"))
+	md.BinaryFormatMinorVersion = uint(uintField("binary_format_minor_version"))
+	md.BuildEpoch = uintField("build_epoch")
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	md.DatabaseType, _ = m["database_type"].(string)
+	if languages, ok := m["languages"].([]any); ok {
+		for _, l := range languages {
+			if s, ok := l.(string); ok {
+				md.Languages = append(md.Languages, s)
+			}
+		}
+	}
+	if description, ok := m["description"].(map[string]any); ok {
+		md.Description = make(map[string]string, len(description))
+		for k, v := range description {
+			if s, ok := v.(string); ok {
+				md.Description[k] = s
+			}
+		}
+	}
+
+	return md, nil
+}
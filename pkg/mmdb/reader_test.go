@@ -0,0 +1,236 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/netip"
+	"sort"
+	"testing"
+)
+
+// testDB assembles a small IPv6 database with 24-bit records. IPv4 networks
+// are inserted under ::/96 like the official databases do.
+type testDB struct {
+	nodes   [][2]int // child node index per side, -1 when there is none
+	records [][2]int // data record index per side, -1 when empty
+	data    []any
+}
+
+func newTestDB() *testDB {
+	return &testDB{nodes: [][2]int{{-1, -1}}, records: [][2]int{{-1, -1}}}
+}
+
+func (db *testDB) insert(t *testing.T, cidr string, record any) {
+	t.Helper()
+
+	prefix := netip.MustParsePrefix(cidr)
+	raw := prefix.Addr().As16()
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		// As16 yields ::ffff:a.b.c.d; the tree wants ::a.b.c.d.
+		raw[10], raw[11] = 0, 0
+		bits += 96
+	}
+
+	db.data = append(db.data, record)
+	dataIdx := len(db.data) - 1
+
+	node := 0
+	for depth := 0; depth < bits; depth++ {
+		bit := int(raw[depth/8]>>(7-depth%8)) & 1
+		if depth == bits-1 {
+			db.records[node][bit] = dataIdx
+			return
+		}
+		if db.nodes[node][bit] == -1 {
+			db.nodes = append(db.nodes, [2]int{-1, -1})
+			db.records = append(db.records, [2]int{-1, -1})
+			db.nodes[node][bit] = len(db.nodes) - 1
+		}
+		node = db.nodes[node][bit]
+	}
+}
+
+func (db *testDB) bytes(t *testing.T) []byte {
+	t.Helper()
+
+	var section []byte
+	offsets := make([]int, len(db.data))
+	for i, record := range db.data {
+		offsets[i] = len(section)
+		section = encodeTest(t, section, record)
+	}
+
+	nodeCount := len(db.nodes)
+	var out []byte
+	for i, children := range db.nodes {
+		for side := 0; side < 2; side++ {
+			value := nodeCount
+			switch {
+			case children[side] != -1:
+				value = children[side]
+			case db.records[i][side] != -1:
+				value = nodeCount + dataSectionSeparatorSize + offsets[db.records[i][side]]
+			}
+			out = append(out, byte(value>>16), byte(value>>8), byte(value))
+		}
+	}
+
+	out = append(out, make([]byte, dataSectionSeparatorSize)...)
+	out = append(out, section...)
+	out = append(out, metadataStartMarker...)
+	return encodeTest(t, out, map[string]any{
+		"node_count":                  uint64(nodeCount),
+		"record_size":                 uint64(24),
+		"ip_version":                  uint64(6),
+		"database_type":               "Test-City",
+		"languages":                   []any{"en"},
+		"binary_format_major_version": uint64(2),
+		"binary_format_minor_version": uint64(0),
+		"build_epoch":                 uint64(1700000000),
+		"description":                 map[string]any{"en": "test database"},
+	})
+}
+
+// encodeTest encodes the handful of types the tests need.
+func encodeTest(t *testing.T, out []byte, value any) []byte {
+	t.Helper()
+
+	control := func(typeNum, size int) {
+		if size >= 29 {
+			t.Fatalf("encodeTest: size %d not supported", size)
+		}
+		if typeNum <= 7 {
+			out = append(out, byte(typeNum<<5|size))
+			return
+		}
+		out = append(out, byte(size), byte(typeNum-7))
+	}
+
+	switch v := value.(type) {
+	case string:
+		control(typeString, len(v))
+		out = append(out, v...)
+	case float64:
+		control(typeDouble, 8)
+		out = binary.BigEndian.AppendUint64(out, math.Float64bits(v))
+	case uint64:
+		var raw []byte
+		for n := v; n > 0; n >>= 8 {
+			raw = append([]byte{byte(n)}, raw...)
+		}
+		control(typeUint64, len(raw))
+		out = append(out, raw...)
+	case []any:
+		control(typeArray, len(v))
+		for _, item := range v {
+			out = encodeTest(t, out, item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		control(typeMap, len(keys))
+		for _, k := range keys {
+			out = encodeTest(t, out, k)
+			out = encodeTest(t, out, v[k])
+		}
+	default:
+		t.Fatalf("encodeTest: unsupported type %T", value)
+	}
+	return out
+}
+
+func cityRecord(isoCode, country, city string) map[string]any {
+	return map[string]any{
+		"country": map[string]any{
+			"iso_code": isoCode,
+			"names":    map[string]any{"en": country},
+		},
+		"city": map[string]any{
+			"names": map[string]any{"en": city},
+		},
+	}
+}
+
+func TestReader_Lookup(t *testing.T) {
+	db := newTestDB()
+	db.insert(t, "1.0.0.0/24", cityRecord("AU", "Australia", "Brisbane"))
+	db.insert(t, "8.8.8.0/24", cityRecord("US", "United States", "Mountain View"))
+	db.insert(t, "2001:db8::/32", cityRecord("BR", "Brazil", "Sao Paulo"))
+
+	reader, err := FromBytes(db.bytes(t))
+	if err != nil {
+		t.Fatalf("FromBytes() error = %v", err)
+	}
+
+	if reader.Metadata.DatabaseType != "Test-City" || reader.Metadata.IPVersion != 6 {
+		t.Errorf("Metadata = %+v", reader.Metadata)
+	}
+
+	tests := []struct {
+		name        string
+		ip          string
+		wantFound   bool
+		wantCity    string
+		wantNetwork string
+	}{
+		{name: "IPv4 first block", ip: "1.0.0.1", wantFound: true, wantCity: "Brisbane", wantNetwork: "1.0.0.0/24"},
+		{name: "IPv4 last address of block", ip: "8.8.8.255", wantFound: true, wantCity: "Mountain View", wantNetwork: "8.8.8.0/24"},
+		{name: "IPv4-mapped IPv6", ip: "::ffff:8.8.8.8", wantFound: true, wantCity: "Mountain View", wantNetwork: "8.8.8.0/24"},
+		{name: "IPv6", ip: "2001:db8::1", wantFound: true, wantCity: "Sao Paulo", wantNetwork: "2001:db8::/32"},
+		{name: "IPv4 not in database", ip: "9.9.9.9", wantFound: false},
+		{name: "IPv6 not in database", ip: "2001:db9::1", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := reader.Lookup(netip.MustParseAddr(tt.ip))
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if result.Found != tt.wantFound {
+				t.Fatalf("Lookup() found = %v, want %v", result.Found, tt.wantFound)
+			}
+			if !tt.wantFound {
+				return
+			}
+
+			record := result.Record.(map[string]any)
+			city := record["city"].(map[string]any)["names"].(map[string]any)["en"]
+			if city != tt.wantCity {
+				t.Errorf("city = %v, want %v", city, tt.wantCity)
+			}
+			if result.Network.String() != tt.wantNetwork {
+				t.Errorf("network = %v, want %v", result.Network, tt.wantNetwork)
+			}
+		})
+	}
+}
+
+func TestFromBytes_Invalid(t *testing.T) {
+	db := newTestDB()
+	db.insert(t, "1.0.0.0/24", cityRecord("AU", "Australia", "Brisbane"))
+	valid := db.bytes(t)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "no metadata marker", data: []byte("not a database")},
+		{name: "metadata is not a map", data: append(append([]byte{}, metadataStartMarker...), 0x41, 'x')},
+		{name: "tree truncated", data: valid[len(valid)-len(metadataStartMarker)-300:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := FromBytes(tt.data); !errors.Is(err, ErrInvalidDatabase) {
+				t.Errorf("FromBytes() error = %v, want ErrInvalidDatabase", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package mmdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+)
+
+// ErrNetworkOverlap is returned by Insert when a network overlaps one that
+// was already inserted.
+var ErrNetworkOverlap = errors.New("network overlaps an existing network")
+
+// WriterOptions sets the descriptive metadata of a written database.
+type WriterOptions struct {
+	DatabaseType string
+	Description  map[string]string
+	Languages    []string
+	// BuildEpoch defaults to the time WriteTo is called.
+	BuildEpoch uint64
+}
+
+// Writer builds an IPv6 MaxMind DB in memory. IPv4 networks are stored under
+// ::/96, the layout the official databases and the nginx/Envoy modules use,
+// and ::ffff:0:0/96 (IPv4-mapped) and 2002::/16 (6to4) are aliased to them
+// when the database is written, as in MaxMind's own IPv6 databases.
+type Writer struct {
+	options WriterOptions
+
+	nodes []writerNode
+	data  encoder
+	// records maps the pointer-free encoding of a record to its offset in
+	// the data section, so identical records are stored once.
+	records map[string]uint
+}
+
+// writerNode holds the left (bit 0) and right (bit 1) slots of a tree node.
+// A slot is empty (0, the root is never a child), a node index, or a data
+// section offset tagged with dataFlag. Four bytes per slot keeps trees with
+// tens of millions of nodes affordable.
+type writerNode [2]uint32
+
+const (
+	dataFlag     = 1 << 31
+	maxSlotValue = dataFlag - 1
+	emptySlot    = 0
+)
+
+func NewWriter(options WriterOptions) *Writer {
+	return &Writer{
+		options: options,
+		nodes:   []writerNode{{}},
+		data:    encoder{pointers: true},
+		records: make(map[string]uint),
+	}
+}
+
+// DataRecord is a record already stored in the data section.
+type DataRecord struct {
+	offset uint
+}
+
+// Insert stores record for every address in prefix. Supported record types
+// are those accepted by the data section encoder.
+func (w *Writer) Insert(prefix netip.Prefix, record any) error {
+	ref, err := w.AddRecord(record)
+	if err != nil {
+		return fmt.Errorf("encode record for %s: %w", prefix, err)
+	}
+	return w.InsertRecord(prefix, ref)
+}
+
+// InsertRecord stores a record returned by AddRecord for every address in
+// prefix. Callers inserting many prefixes per record use it to skip encoding
+// the record again for each one.
+func (w *Writer) InsertRecord(prefix netip.Prefix, record DataRecord) error {
+	if !prefix.IsValid() {
+		return fmt.Errorf("invalid network %s", prefix)
+	}
+	prefix = prefix.Masked()
+
+	offset := record.offset
+
+	raw := prefix.Addr().As16()
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		// As16 yields ::ffff:a.b.c.d; the tree wants ::a.b.c.d.
+		raw[10], raw[11] = 0, 0
+		bits += 96
+	}
+	if bits == 0 {
+		return fmt.Errorf("network %s: cannot store a record for the whole address space", prefix)
+	}
+
+	node, bit, err := w.slot(raw, bits)
+	if err != nil {
+		return fmt.Errorf("network %s: %w", prefix, err)
+	}
+	if w.nodes[node][bit] != emptySlot {
+		return fmt.Errorf("%s: %w", prefix, ErrNetworkOverlap)
+	}
+	w.nodes[node][bit] = dataFlag | uint32(offset)
+	return nil
+}
+
+// slot walks the first bits of raw from the root, adding the missing nodes,
+// and returns the node and side of the slot for the last bit.
+func (w *Writer) slot(raw [16]byte, bits int) (node, bit int, err error) {
+	for depth := 0; ; depth++ {
+		bit = int(raw[depth>>3]>>(7-uint(depth&7))) & 1
+		if depth == bits-1 {
+			return node, bit, nil
+		}
+
+		s := w.nodes[node][bit]
+		switch {
+		case s&dataFlag != 0:
+			return 0, 0, ErrNetworkOverlap
+		case s == emptySlot:
+			if len(w.nodes) > maxSlotValue {
+				return 0, 0, fmt.Errorf("search tree larger than %d nodes", maxSlotValue)
+			}
+			w.nodes = append(w.nodes, writerNode{})
+			s = uint32(len(w.nodes) - 1)
+			w.nodes[node][bit] = s
+		}
+		node = int(s)
+	}
+}
+
+// ipv4Aliases are the IPv6 networks that embed an IPv4 address right after
+// their prefix, so pointing them at the ::/96 subtree answers them.
+var ipv4Aliases = []netip.Prefix{
+	netip.MustParsePrefix("::ffff:0:0/96"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// aliasIPv4 points the ipv4Aliases at the IPv4 subtree. A network that
+// already holds records of its own is left as it is.
+func (w *Writer) aliasIPv4() error {
+	// The IPv4 subtree is the left slot of the node 95 zero bits down.
+	// Without IPv4 networks, or with ::/96 covered by one record, there is
+	// nothing to share.
+	node := uint32(0)
+	for range 95 {
+		node = w.nodes[node][0]
+		if node == emptySlot || node&dataFlag != 0 {
+			return nil
+		}
+	}
+	ipv4 := w.nodes[node][0]
+	if ipv4 == emptySlot {
+		return nil
+	}
+
+	for _, alias := range ipv4Aliases {
+		node, bit, err := w.slot(alias.Addr().As16(), alias.Bits())
+		if errors.Is(err, ErrNetworkOverlap) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("alias %s: %w", alias, err)
+		}
+		if w.nodes[node][bit] == emptySlot {
+			w.nodes[node][bit] = ipv4
+		}
+	}
+	return nil
+}
+
+// AddRecord stores record in the data section, encoding it only the first
+// time an equal record is seen.
+func (w *Writer) AddRecord(record any) (DataRecord, error) {
+	key := encoder{}
+	if err := key.encode(record); err != nil {
+		return DataRecord{}, err
+	}
+	if offset, ok := w.records[string(key.buf)]; ok {
+		return DataRecord{offset: offset}, nil
+	}
+
+	offset := uint(len(w.data.buf))
+	if offset > maxSlotValue {
+		return DataRecord{}, fmt.Errorf("data section larger than %d bytes", maxSlotValue)
+	}
+	if err := w.data.encode(record); err != nil {
+		return DataRecord{}, err
+	}
+	w.records[string(key.buf)] = offset
+	return DataRecord{offset: offset}, nil
+}
+
+// WriteTo writes the search tree, data section and metadata to out. The
+// smallest record size that can address the whole file is used. The IPv4
+// aliases are added first, so networks inserted afterwards under them land
+// in the IPv4 subtree.
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	if err := w.aliasIPv4(); err != nil {
+		return 0, err
+	}
+
+	nodeCount := uint(len(w.nodes))
+	maxValue := nodeCount + dataSectionSeparatorSize + uint(len(w.data.buf))
+
+	var recordSize uint
+	switch {
+	case maxValue < 1<<24:
+		recordSize = 24
+	case maxValue < 1<<28:
+		recordSize = 28
+	case uint64(maxValue) < 1<<32:
+		recordSize = 32
+	default:
+		return 0, fmt.Errorf("database too large: %d nodes and %d data bytes", nodeCount, len(w.data.buf))
+	}
+
+	buildEpoch := w.options.BuildEpoch
+	if buildEpoch == 0 {
+		buildEpoch = uint64(time.Now().Unix())
+	}
+	languages := w.options.Languages
+	if languages == nil {
+		languages = []string{}
+	}
+	description := w.options.Description
+	if description == nil {
+		description = map[string]string{}
+	}
+
+	metadata := encoder{}
+	if err := metadata.encode(map[string]any{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+		"ip_version":                  uint16(6),
+		"database_type":               w.options.DatabaseType,
+		"languages":                   languages,
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 buildEpoch,
+		"description":                 description,
+	}); err != nil {
+		return 0, fmt.Errorf("encode metadata: %w", err)
+	}
+
+	cw := &countingWriter{w: out}
+	bw := bufio.NewWriterSize(cw, 1<<20)
+
+	record := func(s uint32) uint {
+		switch {
+		case s == emptySlot:
+			return nodeCount
+		case s&dataFlag != 0:
+			return nodeCount + dataSectionSeparatorSize + uint(s&^dataFlag)
+		}
+		return uint(s)
+	}
+
+	nodeBytes := make([]byte, recordSize/4)
+	for _, n := range w.nodes {
+		left, right := record(n[0]), record(n[1])
+		switch recordSize {
+		case 24:
+			nodeBytes[0], nodeBytes[1], nodeBytes[2] = byte(left>>16), byte(left>>8), byte(left)
+			nodeBytes[3], nodeBytes[4], nodeBytes[5] = byte(right>>16), byte(right>>8), byte(right)
+		case 28:
+			nodeBytes[0], nodeBytes[1], nodeBytes[2] = byte(left>>16), byte(left>>8), byte(left)
+			nodeBytes[3] = byte(left>>24)<<4 | byte(right>>24)&0x0f
+			nodeBytes[4], nodeBytes[5], nodeBytes[6] = byte(right>>16), byte(right>>8), byte(right)
+		default:
+			nodeBytes[0], nodeBytes[1], nodeBytes[2], nodeBytes[3] = byte(left>>24), byte(left>>16), byte(left>>8), byte(left)
+			nodeBytes[4], nodeBytes[5], nodeBytes[6], nodeBytes[7] = byte(right>>24), byte(right>>16), byte(right>>8), byte(right)
+		}
+		_, _ = bw.Write(nodeBytes)
+	}
+
+	_, _ = bw.Write(make([]byte, dataSectionSeparatorSize))
+	_, _ = bw.Write(w.data.buf)
+	_, _ = bw.Write(metadataStartMarker)
+	_, _ = bw.Write(metadata.buf)
+
+	// bufio keeps the first write error and returns it from Flush.
+	if err := bw.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,319 @@
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncoder_RoundTrip(t *testing.T) {
+	values := []any{
+		"",
+		strings.Repeat("a", 30),
+		strings.Repeat("b", 300),
+		strings.Repeat("c", 70000),
+		37.5,
+		float32(1.5),
+		[]byte{1, 2, 3},
+		uint64(0),
+		uint64(1) << 56,
+		int32(-2),
+		true,
+		false,
+		big.NewInt(1 << 40),
+		[]any{"x", uint64(7)},
+		map[string]any{"en": "Brazil", "nested": map[string]any{"code": "BR"}},
+	}
+
+	for _, value := range values {
+		e := encoder{}
+		if err := e.encode(value); err != nil {
+			t.Fatalf("encode(%T) error = %v", value, err)
+		}
+
+		d := decoder{buf: e.buf}
+		got, next, err := d.decode(0, 0)
+		if err != nil {
+			t.Fatalf("decode(%T) error = %v", value, err)
+		}
+		if next != uint(len(e.buf)) {
+			t.Errorf("decode(%T) consumed %d of %d bytes", value, next, len(e.buf))
+		}
+		if b, ok := value.(*big.Int); ok {
+			if b.Cmp(got.(*big.Int)) != 0 {
+				t.Errorf("decode() = %v, want %v", got, b)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, value) {
+			t.Errorf("decode() = %#v, want %#v", got, value)
+		}
+	}
+}
+
+func TestEncoder_StringPointers(t *testing.T) {
+	name := "United States of America"
+	e := encoder{pointers: true}
+	if err := e.encode([]any{name, name}); err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+
+	if n := bytes.Count(e.buf, []byte(name)); n != 1 {
+		t.Errorf("string stored %d times, want 1", n)
+	}
+
+	d := decoder{buf: e.buf}
+	got, _, err := d.decode(0, 0)
+	if err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+	if want := []any{name, name}; !reflect.DeepEqual(got, want) {
+		t.Errorf("decode() = %v, want %v", got, want)
+	}
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	w := NewWriter(WriterOptions{
+		DatabaseType: "Test-City",
+		Description:  map[string]string{"en": "test database"},
+		Languages:    []string{"en"},
+		BuildEpoch:   1700000000,
+	})
+
+	brisbane := cityRecord("AU", "Australia", "Brisbane")
+	inserts := []struct {
+		cidr   string
+		record any
+	}{
+		{"1.0.0.0/24", brisbane},
+		{"1.0.1.0/24", brisbane},
+		{"8.8.8.0/24", cityRecord("US", "United States", "Mountain View")},
+		{"2001:db8::/32", cityRecord("BR", "Brazil", "Sao Paulo")},
+	}
+	for _, in := range inserts {
+		if err := w.Insert(netip.MustParsePrefix(in.cidr), in.record); err != nil {
+			t.Fatalf("Insert(%s) error = %v", in.cidr, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo() = %d, wrote %d bytes", n, buf.Len())
+	}
+	if c := bytes.Count(buf.Bytes(), []byte("Brisbane")); c != 1 {
+		t.Errorf("duplicate record stored %d times, want 1", c)
+	}
+
+	reader, err := FromBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("FromBytes() error = %v", err)
+	}
+
+	md := reader.Metadata
+	if md.DatabaseType != "Test-City" || md.IPVersion != 6 || md.RecordSize != 24 ||
+		md.BuildEpoch != 1700000000 || md.Description["en"] != "test database" ||
+		!reflect.DeepEqual(md.Languages, []string{"en"}) {
+		t.Errorf("Metadata = %+v", md)
+	}
+
+	tests := []struct {
+		ip          string
+		wantCity    string
+		wantNetwork string
+	}{
+		{ip: "1.0.0.7", wantCity: "Brisbane", wantNetwork: "1.0.0.0/24"},
+		{ip: "1.0.1.255", wantCity: "Brisbane", wantNetwork: "1.0.1.0/24"},
+		{ip: "8.8.8.8", wantCity: "Mountain View", wantNetwork: "8.8.8.0/24"},
+		{ip: "2001:db8::1", wantCity: "Sao Paulo", wantNetwork: "2001:db8::/32"},
+		{ip: "9.9.9.9"},
+		{ip: "2001:db9::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			result, err := reader.Lookup(netip.MustParseAddr(tt.ip))
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if result.Found != (tt.wantCity != "") {
+				t.Fatalf("Lookup() found = %v", result.Found)
+			}
+			if !result.Found {
+				return
+			}
+
+			city := result.Record.(map[string]any)["city"].(map[string]any)["names"].(map[string]any)["en"]
+			if city != tt.wantCity {
+				t.Errorf("city = %v, want %v", city, tt.wantCity)
+			}
+			if result.Network.String() != tt.wantNetwork {
+				t.Errorf("network = %v, want %v", result.Network, tt.wantNetwork)
+			}
+		})
+	}
+}
+
+func TestWriter_Insert_Overlap(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+	}{
+		{name: "same network", first: "8.8.8.0/24", second: "8.8.8.0/24"},
+		{name: "more specific", first: "8.8.0.0/16", second: "8.8.8.0/24"},
+		{name: "less specific", first: "8.8.8.0/24", second: "8.8.0.0/16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWriter(WriterOptions{})
+			if err := w.Insert(netip.MustParsePrefix(tt.first), "a"); err != nil {
+				t.Fatalf("Insert(%s) error = %v", tt.first, err)
+			}
+			err := w.Insert(netip.MustParsePrefix(tt.second), "b")
+			if !errors.Is(err, ErrNetworkOverlap) {
+				t.Errorf("Insert(%s) error = %v, want ErrNetworkOverlap", tt.second, err)
+			}
+		})
+	}
+}
+
+// TestWriter_IPv4Aliases walks the written search tree by hand, as
+// libmaxminddb does, instead of through Reader, which unmaps addresses
+// before looking them up.
+func TestWriter_IPv4Aliases(t *testing.T) {
+	w := NewWriter(WriterOptions{BuildEpoch: 1700000000})
+	if err := w.Insert(netip.MustParsePrefix("1.2.3.0/24"), "AU"); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := w.Insert(netip.MustParsePrefix("2001:db8::/32"), "BR"); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	db := buf.Bytes()
+
+	// The metadata map stores node_count as a uint32 (type 6) and
+	// record_size as a uint16 (type 5), each a control byte holding the type
+	// and byte length followed by the big-endian value.
+	metadata := db[bytes.LastIndex(db, []byte("\xAB\xCD\xEFMaxMind.com"))+14:]
+	uintField := func(key string, typ byte) uint {
+		t.Helper()
+		i := bytes.Index(metadata, []byte(key))
+		if i < 0 {
+			t.Fatalf("metadata has no %s", key)
+		}
+		control := metadata[i+len(key)]
+		if control>>5 != typ {
+			t.Fatalf("%s has type %d, want %d", key, control>>5, typ)
+		}
+		var v uint
+		for _, b := range metadata[i+len(key)+1 : i+len(key)+1+int(control&0x1f)] {
+			v = v<<8 | uint(b)
+		}
+		return v
+	}
+	nodeCount := uintField("node_count", 6)
+	if recordSize := uintField("record_size", 5); recordSize != 24 {
+		t.Fatalf("record_size = %d, want 24", recordSize)
+	}
+
+	// lookup follows the bits of ip through 24-bit records: six bytes per
+	// node, left record first. It returns the data the record points to, nil
+	// for an empty record, and the depth reached.
+	lookup := func(ip string) ([]byte, int) {
+		raw := netip.MustParseAddr(ip).As16()
+		node, depth := uint(0), 0
+		for ; node < nodeCount; depth++ {
+			if depth == 128 {
+				t.Fatalf("lookup(%s): search tree deeper than 128 bits", ip)
+			}
+			b := db[node*6 : node*6+6]
+			if raw[depth>>3]>>(7-uint(depth&7))&1 == 1 {
+				b = b[3:]
+			}
+			node = uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		if node == nodeCount {
+			return nil, depth
+		}
+		// Data pointers count from the start of the 16-byte separator.
+		return db[nodeCount*6+(node-nodeCount):], depth
+	}
+
+	tests := []struct {
+		ip        string
+		want      string
+		wantDepth int
+	}{
+		{ip: "::1.2.3.4", want: "AU", wantDepth: 120},
+		{ip: "::ffff:1.2.3.4", want: "AU", wantDepth: 120},
+		{ip: "2002:102:304::1", want: "AU", wantDepth: 40},
+		{ip: "2001:db8::1", want: "BR", wantDepth: 32},
+		{ip: "::ffff:1.2.4.1"},
+		{ip: "2002:102:404::"},
+	}
+	for _, tt := range tests {
+		data, depth := lookup(tt.ip)
+		if tt.want == "" {
+			if data != nil {
+				t.Errorf("lookup(%s) found a record, want none", tt.ip)
+			}
+			continue
+		}
+		// A two-byte UTF-8 string: type 2 in the top bits, length 2.
+		if data == nil || !bytes.HasPrefix(data, append([]byte{0x42}, tt.want...)) {
+			t.Errorf("lookup(%s) = % x, want the string %q", tt.ip, data[:min(len(data), 3)], tt.want)
+		}
+		if depth != tt.wantDepth {
+			t.Errorf("lookup(%s) depth = %d, want %d", tt.ip, depth, tt.wantDepth)
+		}
+	}
+}
+
+// A network already inserted in an alias space is kept, and writing twice
+// gives the same file.
+func TestWriter_IPv4Aliases_Existing(t *testing.T) {
+	w := NewWriter(WriterOptions{BuildEpoch: 1700000000})
+	if err := w.Insert(netip.MustParsePrefix("1.2.3.0/24"), "AU"); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if err := w.Insert(netip.MustParsePrefix("2002:aaaa::/32"), "6to4 relay"); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	var first, second bytes.Buffer
+	if _, err := w.WriteTo(&first); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if _, err := w.WriteTo(&second); err != nil {
+		t.Fatalf("second WriteTo() error = %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("second WriteTo() wrote a different file")
+	}
+
+	reader, err := FromBytes(first.Bytes())
+	if err != nil {
+		t.Fatalf("FromBytes() error = %v", err)
+	}
+	for ip, want := range map[string]any{"2002:aaaa::1": "6to4 relay", "2002:102:304::": nil} {
+		result, err := reader.Lookup(netip.MustParseAddr(ip))
+		if err != nil {
+			t.Fatalf("Lookup(%s) error = %v", ip, err)
+		}
+		if result.Record != want {
+			t.Errorf("Lookup(%s) = %v, want %v", ip, result.Record, want)
+		}
+	}
+}